@@ -0,0 +1,347 @@
+// Command snmpctl queries an SNMP agent through snmp.SnmpClient.
+//
+//	snmpctl get -c public 10.0.0.1 sysDescr.0 sysUpTime.0
+//	snmpctl walk -v 1 -c public 10.0.0.1 system
+//	snmpctl table -mib-dir ./mibs -o table 10.0.0.1 ifTable
+//	snmpctl set -c private 10.0.0.1 sysContact.0 noc@example.com
+//	snmpctl translate -mib-dir ./mibs IF-MIB::ifDescr.5 1.3.6.1.2.1.1.3.0
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"snmp-test/snmp"
+	"snmp-test/snmp/parse"
+	"snmp-test/snmp/scraper"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const usage = `usage: snmpctl <command> [flags] <target> <name>...
+
+commands:
+  get        read instances
+  getnext    read the instance following each name
+  walk       walk subtrees with GetNext
+  bulkwalk   walk subtrees with GetBulk (GetNext for -v 1)
+  table      read a table, one row per index
+  set        write name value pairs
+  translate  convert between names and numeric OIDs (no target)
+
+run "snmpctl <command> -h" for the flags of a command.
+`
+
+// errUsage is returned for bad invocations; the usage has been printed.
+var errUsage = errors.New("usage error")
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	err := run(ctx, os.Args[1:], os.Stdout, os.Stderr)
+	stop()
+	if err != nil {
+		if !errors.Is(err, errUsage) {
+			fmt.Fprintf(os.Stderr, "snmpctl: %v\n", err)
+		}
+		os.Exit(1)
+	}
+}
+
+// options are the flags shared by all commands.
+type options struct {
+	version        string
+	community      string
+	port           uint
+	transport      string
+	timeout        time.Duration
+	retries        int
+	maxRepetitions uint
+	maxOIDs        int
+
+	secLevel  string
+	secName   string
+	authProto string
+	authPass  string
+	privProto string
+	privPass  string
+
+	mibDirs string
+	modules string
+	format  string
+}
+
+func (o *options) register(fs *flag.FlagSet) {
+	fs.StringVar(&o.version, "v", "2c", "snmp version: 1|2c|3")
+	fs.StringVar(&o.community, "c", "public", "community for v1 and v2c")
+	fs.UintVar(&o.port, "p", 161, "agent port")
+	fs.StringVar(&o.transport, "transport", "", "udp|udp4|udp6|tcp|tcp4|tcp6|unix, default udp; unix takes a socket path as target")
+	fs.DurationVar(&o.timeout, "timeout", 5*time.Second, "timeout of one request")
+	fs.IntVar(&o.retries, "retries", 1, "retries per request")
+	fs.UintVar(&o.maxRepetitions, "max-repetitions", 0, "GetBulk max-repetitions, 0 for the gosnmp default")
+	fs.IntVar(&o.maxOIDs, "max-oids", 0, "varbinds per request, 0 for the gosnmp default")
+
+	fs.StringVar(&o.secLevel, "l", "noAuthNoPriv", "v3 security level: noAuthNoPriv|authNoPriv|authPriv")
+	fs.StringVar(&o.secName, "u", "", "v3 security name")
+	fs.StringVar(&o.authProto, "a", "", "v3 auth protocol: MD5|SHA|SHA-224|SHA-256|SHA-384|SHA-512")
+	fs.StringVar(&o.authPass, "A", "", "v3 auth passphrase")
+	fs.StringVar(&o.privProto, "x", "", "v3 privacy protocol: DES|AES|AES192|AES256|AES256C")
+	fs.StringVar(&o.privPass, "X", "", "v3 privacy passphrase")
+
+	fs.StringVar(&o.mibDirs, "mib-dir", "", "MIB directories to load, separated by "+string(filepath.ListSeparator))
+	fs.StringVar(&o.modules, "modules", "", "comma separated modules preferred for ambiguous names")
+	fs.StringVar(&o.format, "o", formatText, "output format: text|json|csv|table")
+}
+
+// clientConfig builds the scraper config for target, which may carry a
+// ":port" suffix overriding -p.
+func (o *options) clientConfig(ctx context.Context, target string) (*scraper.ClientConfig, error) {
+	config := &scraper.ClientConfig{
+		Target:                   target,
+		Port:                     uint16(o.port),
+		Transport:                o.transport,
+		Community:                o.community,
+		SecLevel:                 o.secLevel,
+		SecName:                  o.secName,
+		AuthenticationProtocol:   o.authProto,
+		AuthenticationPassphrase: o.authPass,
+		PrivacyProtocol:          o.privProto,
+		PrivacyPassphrase:        o.privPass,
+		Timeout:                  o.timeout,
+		Retries:                  o.retries,
+		MaxRepetitions:           uint32(o.maxRepetitions),
+		MaxOIDs:                  o.maxOIDs,
+		Context:                  ctx,
+	}
+
+	switch strings.ToLower(o.version) {
+	case "1", scraper.Version1:
+		config.Version = scraper.Version1
+	case "2c", scraper.Versionv2c:
+		config.Version = scraper.Versionv2c
+	case "3", scraper.Version3:
+		config.Version = scraper.Version3
+	default:
+		return nil, fmt.Errorf("invalid version %s, support (1|2c|3)", o.version)
+	}
+
+	if o.transport == scraper.TransportUnix {
+		return config, nil
+	}
+	if host, port := splitTarget(target); port != 0 {
+		config.Target, config.Port = host, port
+	}
+	return config, nil
+}
+
+func splitTarget(target string) (string, uint16) {
+	host, port, err := net.SplitHostPort(target)
+	if err != nil {
+		return target, 0
+	}
+	p, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return target, 0
+	}
+	return host, uint16(p)
+}
+
+func (o *options) loadMibs() {
+	if o.mibDirs != "" {
+		for _, dir := range filepath.SplitList(o.mibDirs) {
+			parse.LoadMibFromDir(dir)
+		}
+	}
+	if o.modules != "" {
+		parse.SetModulePreference(strings.Split(o.modules, ",")...)
+	}
+}
+
+// command runs one subcommand; args are the positional arguments after the
+// target, or all of them for commands without a target.
+type command struct {
+	summary  string
+	args     string
+	noTarget bool
+	minArgs  int
+	run      func(ctx context.Context, client snmp.SnmpClient, args []string) (*output, error)
+}
+
+var commands = map[string]*command{
+	"get":       {summary: "read instances", args: "<name>...", minArgs: 1, run: runGet},
+	"getnext":   {summary: "read the instance following each name", args: "<name>...", minArgs: 1, run: runGetNext},
+	"walk":      {summary: "walk subtrees with GetNext", args: "<name>...", minArgs: 1, run: runWalk},
+	"bulkwalk":  {summary: "walk subtrees with GetBulk", args: "<name>...", minArgs: 1, run: runBulkWalk},
+	"table":     {summary: "read a table, one row per index", args: "<table>", minArgs: 1, run: runTable},
+	"set":       {summary: "write name value pairs", args: "<name> <value> [<name> <value>]...", minArgs: 2, run: runSet},
+	"translate": {summary: "convert between names and numeric OIDs", args: "<name|oid>...", minArgs: 1, noTarget: true},
+}
+
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "-help" || args[0] == "help" {
+		fmt.Fprint(stderr, usage)
+		return errUsage
+	}
+	name := args[0]
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(stderr, "unknown command %q\n\n%s", name, usage)
+		return errUsage
+	}
+
+	opts := &options{}
+	fs := flag.NewFlagSet("snmpctl "+name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	opts.register(fs)
+	fs.Usage = func() {
+		if cmd.noTarget {
+			fmt.Fprintf(stderr, "usage: snmpctl %s [flags] %s\n\n%s\n\nflags:\n", name, cmd.args, cmd.summary)
+		} else {
+			fmt.Fprintf(stderr, "usage: snmpctl %s [flags] <target> %s\n\n%s\n\nflags:\n", name, cmd.args, cmd.summary)
+		}
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args[1:]); err != nil {
+		return errUsage
+	}
+
+	rest := fs.Args()
+	minArgs := cmd.minArgs
+	if !cmd.noTarget {
+		minArgs++
+	}
+	if len(rest) < minArgs {
+		fs.Usage()
+		return errUsage
+	}
+	if !isFormat(opts.format) {
+		return fmt.Errorf("invalid output format %s, support (text|json|csv|table)", opts.format)
+	}
+
+	opts.loadMibs()
+
+	var out *output
+	var err error
+	if cmd.noTarget {
+		out, err = runTranslate(rest)
+	} else {
+		var config *scraper.ClientConfig
+		if config, err = opts.clientConfig(ctx, rest[0]); err != nil {
+			return err
+		}
+		client := snmp.NewClient(config)
+		defer func() {
+			_ = client.Close()
+		}()
+		out, err = cmd.run(ctx, client, rest[1:])
+	}
+
+	// partial results are still worth printing
+	if out != nil {
+		if writeErr := out.write(stdout, opts.format); writeErr != nil && err == nil {
+			err = writeErr
+		}
+	}
+	return err
+}
+
+func runGet(ctx context.Context, client snmp.SnmpClient, names []string) (*output, error) {
+	values, err := client.GetValues(ctx, names...)
+	out := newVarbindOutput()
+	for _, name := range names {
+		if v, ok := values[name]; ok {
+			out.addValue(v)
+		}
+	}
+	return out, err
+}
+
+func runGetNext(ctx context.Context, client snmp.SnmpClient, names []string) (*output, error) {
+	values, err := client.GetNextValues(ctx, names...)
+	out := newVarbindOutput()
+	for _, v := range values {
+		out.addValue(v)
+	}
+	return out, err
+}
+
+func runWalk(ctx context.Context, client snmp.SnmpClient, names []string) (*output, error) {
+	return walk(ctx, names, client.WalkValues)
+}
+
+func runBulkWalk(ctx context.Context, client snmp.SnmpClient, names []string) (*output, error) {
+	return walk(ctx, names, client.BulkWalkValues)
+}
+
+func walk(ctx context.Context, names []string, fn func(ctx context.Context, name string) ([]*snmp.Value, error)) (*output, error) {
+	out := newVarbindOutput()
+	for _, name := range names {
+		values, err := fn(ctx, name)
+		for _, v := range values {
+			out.addValue(v)
+		}
+		if err != nil {
+			return out, err
+		}
+	}
+	return out, nil
+}
+
+func runTable(ctx context.Context, client snmp.SnmpClient, args []string) (*output, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("table takes one table name, got %d", len(args))
+	}
+	rows, err := client.GetBulkTableValues(ctx, args[0])
+	if err != nil {
+		return nil, err
+	}
+	return newTableOutput(rows), nil
+}
+
+func runSet(ctx context.Context, client snmp.SnmpClient, args []string) (*output, error) {
+	if len(args)%2 != 0 {
+		return nil, fmt.Errorf("set takes name value pairs, %s has no value", args[len(args)-1])
+	}
+
+	values := make(map[string]string, len(args)/2)
+	names := make([]string, 0, len(args)/2)
+	for i := 0; i < len(args); i += 2 {
+		values[args[i]] = args[i+1]
+		names = append(names, args[i])
+	}
+
+	var err error
+	if len(names) == 1 {
+		err = client.Set(ctx, names[0], "", values[names[0]])
+	} else {
+		err = client.SetNames(ctx, "", values)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// read back what the agent now holds, as snmpset does
+	return runGet(ctx, client, names)
+}
+
+func runTranslate(args []string) (*output, error) {
+	out := &output{header: []string{"input", "oid", "name", "module", "type", "access"}}
+	for _, arg := range args {
+		oid, err := parse.ToNumeric(arg)
+		if err != nil {
+			return out, err
+		}
+		row := []string{arg, oid, parse.Render(oid), "", "", ""}
+		if mib, _, ok := parse.LongestMatch(oid); ok {
+			row[3], row[4], row[5] = mib.Module, mib.Type, mib.Access
+		}
+		out.rows = append(out.rows, row)
+	}
+	return out, nil
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"github.com/gosnmp/gosnmp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"snmp-test/snmp"
+	"snmp-test/snmp/agent"
+	"snmp-test/snmp/parse"
+	"snmp-test/snmp/record"
+	"snmp-test/snmp/scraper"
+	"testing"
+)
+
+const (
+	testMibs = "../../snmp/testdata/mibs"
+	testWalk = "../../snmp/testdata/agent.walk"
+)
+
+// startAgent serves pdus on a local port and returns its address.
+func startAgent(t *testing.T, pdus []gosnmp.SnmpPDU) (*agent.Agent, string) {
+	t.Helper()
+	a, err := agent.Start(agent.Config{Data: pdus})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = a.Close()
+	})
+	return a, a.Addr().String()
+}
+
+// startTestAgent serves the walk the snmp package tests use.
+func startTestAgent(t *testing.T) (*agent.Agent, string) {
+	t.Helper()
+	pdus, err := record.LoadWalk(testWalk)
+	require.NoError(t, err)
+	return startAgent(t, pdus)
+}
+
+// runCLI runs snmpctl with the test MIBs loaded.
+func runCLI(args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	err := run(context.Background(), append(args[:1:1], append([]string{"-mib-dir", testMibs}, args[1:]...)...), &stdout, &stderr)
+	return stdout.String(), err
+}
+
+func TestClientConfig(t *testing.T) {
+	opts := &options{version: "1", community: "private", port: 161}
+	config, err := opts.clientConfig(context.Background(), "10.0.0.9:1161")
+	require.NoError(t, err)
+	assert.Equal(t, scraper.Version1, config.Version)
+	assert.Equal(t, "private", config.Community)
+	assert.Equal(t, "10.0.0.9", config.Target)
+	assert.Equal(t, uint16(1161), config.Port)
+
+	config, err = opts.clientConfig(context.Background(), "10.0.0.9")
+	require.NoError(t, err)
+	assert.Equal(t, uint16(161), config.Port)
+
+	config, err = opts.clientConfig(context.Background(), "[::1]:1161")
+	require.NoError(t, err)
+	assert.Equal(t, "::1", config.Target)
+
+	// a unix target is a socket path, colons and all
+	opts.transport = scraper.TransportUnix
+	config, err = opts.clientConfig(context.Background(), "/run/snmp/relay:161")
+	require.NoError(t, err)
+	assert.Equal(t, "/run/snmp/relay:161", config.Target)
+
+	opts.version = "4"
+	_, err = opts.clientConfig(context.Background(), "10.0.0.9")
+	assert.Error(t, err)
+}
+
+func TestGet(t *testing.T) {
+	_, target := startTestAgent(t)
+
+	for _, version := range []string{"1", "2c"} {
+		out, err := runCLI("get", "-v", version, "-c", "private", target, "testUpTime.0", "testDescr.0")
+		require.NoError(t, err, version)
+		assert.Equal(t, "testUpTime.0 = TimeTicks: 18295586\ntestDescr.0 = OctetString: CASA \"C100G\"\n", out, version)
+	}
+
+	// the instances found are printed along with the error
+	out, err := runCLI("get", "-c", "private", target, "testDescr.0", "testNodeName.0")
+	assert.Error(t, err)
+	assert.Equal(t, "testDescr.0 = OctetString: CASA \"C100G\"\n", out)
+}
+
+func TestWalk(t *testing.T) {
+	_, target := startTestAgent(t)
+
+	for _, cmd := range []string{"walk", "bulkwalk"} {
+		out, err := runCLI(cmd, "-c", "private", target, "testNodeName")
+		require.NoError(t, err, cmd)
+		assert.Equal(t, "testNodeName.0.23.16.43.105.88.10.0.0.1 = OctetString: node-a\n"+
+			"testNodeName.0.23.16.43.105.89.10.0.0.2 = OctetString: node-b\n", out, cmd)
+	}
+}
+
+func TestTable(t *testing.T) {
+	_, target := startTestAgent(t)
+
+	out, err := runCLI("table", "-o", "csv", "-c", "private", target, "testNodeTable")
+	require.NoError(t, err)
+
+	// columns by sub-identifier, rows by index
+	assert.Equal(t, "index,testNodeMac,testNodeAddr,testNodeName,testNodeInOctets,testNodeRowStatus\n"+
+		"0.23.16.43.105.88.10.0.0.1,00:17:10:2B:69:58,10.0.0.1,node-a,1000,active\n"+
+		"0.23.16.43.105.89.10.0.0.2,00:17:10:2B:69:59,10.0.0.2,node-b,20,notInService\n", out)
+
+	out, err = runCLI("table", "-o", "table", "-c", "private", target, "testNodeTable")
+	require.NoError(t, err)
+	assert.Contains(t, out, "index                       testNodeMac        testNodeAddr")
+}
+
+func TestSet(t *testing.T) {
+	a, target := startTestAgent(t)
+	descr, _ := parse.FindMib("testDescr")
+
+	// the value is read back from the agent
+	out, err := runCLI("set", "-o", "json", "-c", "private", target, "testDescr.0", "C100G rev 2")
+	require.NoError(t, err)
+	assert.Contains(t, out, `"value": "C100G rev 2"`)
+	assert.Contains(t, a.Data(), gosnmp.SnmpPDU{Name: "." + descr.OID + ".0", Type: gosnmp.OctetString, Value: []byte("C100G rev 2")})
+
+	out, err = runCLI("set", "-c", "private", target, "testDescr.0", "a", "testNodeName.0.23.16.43.105.88.10.0.0.1", "b")
+	require.NoError(t, err)
+	assert.Equal(t, "testDescr.0 = OctetString: a\ntestNodeName.0.23.16.43.105.88.10.0.0.1 = OctetString: b\n", out)
+
+	_, err = runCLI("set", "-c", "private", target, "testDescr.0", "a", "testUpTime.0")
+	assert.Error(t, err)
+}
+
+func TestTranslate(t *testing.T) {
+	out, err := runCLI("translate", "-o", "csv", "testNodeName.0.23.16.43.105.88.10.0.0.1")
+	require.NoError(t, err)
+
+	mib, _ := parse.FindMib("testNodeName")
+	assert.Equal(t, "input,oid,name,module,type,access\n"+
+		"testNodeName.0.23.16.43.105.88.10.0.0.1,"+mib.OID+".0.23.16.43.105.88.10.0.0.1,testNodeName.0.23.16.43.105.88.10.0.0.1,SNMP-TEST-MIB,DisplayString,"+mib.Access+"\n", out)
+}
+
+func TestUsage(t *testing.T) {
+	for _, args := range [][]string{{}, {"nope"}, {"get", "10.0.0.9"}, {"table"}} {
+		var stdout, stderr bytes.Buffer
+		err := run(context.Background(), args, &stdout, &stderr)
+		assert.ErrorIs(t, err, errUsage, args)
+		assert.NotEmpty(t, stderr.String(), args)
+	}
+
+	_, err := runCLI("get", "-o", "xml", "10.0.0.9", "testDescr.0")
+	assert.Error(t, err)
+}
+
+// lldpLocSysName lies outside the test MIBs, it stays unresolved whether or
+// not another test loaded them
+const lldpLocSysName = "1.0.8802.1.1.2.1.3.3.0"
+
+func TestNumericWithoutMibs(t *testing.T) {
+	_, target := startAgent(t, []gosnmp.SnmpPDU{
+		{Name: "." + lldpLocSysName, Type: gosnmp.OctetString, Value: []byte("c100g")},
+	})
+
+	for cmd, oid := range map[string]string{"get": lldpLocSysName, "walk": "1.0.8802.1.1.2.1.3", "bulkwalk": ".1.0.8802.1.1.2"} {
+		var stdout, stderr bytes.Buffer
+		err := run(context.Background(), []string{cmd, "-c", "private", target, oid}, &stdout, &stderr)
+		require.NoError(t, err, cmd)
+		assert.Equal(t, lldpLocSysName+" = OctetString: c100g\n", stdout.String(), cmd)
+	}
+
+	err := run(context.Background(), []string{"get", "-c", "private", target, "lldpLocSysName.0"}, &bytes.Buffer{}, &bytes.Buffer{})
+	assert.ErrorIs(t, err, snmp.ErrUnknownObject)
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"snmp-test/snmp"
+	"snmp-test/snmp/parse"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+const (
+	formatText  = "text"
+	formatJSON  = "json"
+	formatCSV   = "csv"
+	formatTable = "table"
+)
+
+func isFormat(format string) bool {
+	switch format {
+	case formatText, formatJSON, formatCSV, formatTable:
+		return true
+	}
+	return false
+}
+
+// output is the result of a command as named columns. text renders one row
+// for the text format; without it text is the same as the table format.
+type output struct {
+	header []string
+	rows   [][]string
+	text   func(row []string) string
+}
+
+func newVarbindOutput() *output {
+	return &output{
+		header: []string{"oid", "name", "type", "value"},
+		text: func(row []string) string {
+			return fmt.Sprintf("%s = %s: %s", row[1], row[2], row[3])
+		},
+	}
+}
+
+func (o *output) addValue(v *snmp.Value) {
+	name := parse.Render(v.OID)
+	if v.Object != nil {
+		name = v.Object.Name
+		if v.Index != "" {
+			name += "." + v.Index
+		}
+	}
+	o.rows = append(o.rows, []string{v.OID, name, v.Type.String(), v.String})
+}
+
+// newTableOutput renders rows in index order with one column per object,
+// ordered by sub-identifier. Not-accessible INDEX columns are filled in
+// from the decoded index.
+func newTableOutput(rows []snmp.Row) *output {
+	columns := make(map[string]*parse.MibObject)
+	for _, row := range rows {
+		for _, v := range row.Values {
+			if v.Object != nil {
+				columns[v.Object.Name] = v.Object
+			}
+		}
+		for _, iv := range row.IndexValues {
+			columns[iv.Object.Name] = iv.Object
+		}
+	}
+	objs := make([]*parse.MibObject, 0, len(columns))
+	for _, obj := range columns {
+		objs = append(objs, obj)
+	}
+	sort.Slice(objs, func(i, j int) bool { return snmp.CompareIndex(objs[i].OID, objs[j].OID) < 0 })
+
+	out := &output{header: []string{"index"}}
+	for _, obj := range objs {
+		out.header = append(out.header, obj.Name)
+	}
+
+	sort.Slice(rows, func(i, j int) bool { return snmp.CompareIndex(rows[i].Index, rows[j].Index) < 0 })
+	for _, row := range rows {
+		cells := make([]string, 0, len(out.header))
+		cells = append(cells, row.Index)
+		for _, obj := range objs {
+			cells = append(cells, cell(row, obj.Name))
+		}
+		out.rows = append(out.rows, cells)
+	}
+	return out
+}
+
+func cell(row snmp.Row, name string) string {
+	if v, ok := row.Values[name]; ok {
+		return v.String
+	}
+	for _, iv := range row.IndexValues {
+		if iv.Object.Name == name {
+			return iv.Value
+		}
+	}
+	return ""
+}
+
+func (o *output) write(w io.Writer, format string) error {
+	switch format {
+	case formatJSON:
+		return o.writeJSON(w)
+	case formatCSV:
+		return o.writeCSV(w)
+	case formatText:
+		if o.text != nil {
+			for _, row := range o.rows {
+				if _, err := fmt.Fprintln(w, o.text(row)); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	}
+	return o.writeTable(w)
+}
+
+func (o *output) writeJSON(w io.Writer) error {
+	objects := make([]map[string]string, 0, len(o.rows))
+	for _, row := range o.rows {
+		m := make(map[string]string, len(o.header))
+		for i, name := range o.header {
+			m[name] = row[i]
+		}
+		objects = append(objects, m)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(objects)
+}
+
+func (o *output) writeCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(o.header); err != nil {
+		return err
+	}
+	if err := cw.WriteAll(o.rows); err != nil {
+		return err
+	}
+	return cw.Error()
+}
+
+func (o *output) writeTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(o.header, "\t"))
+	for _, row := range o.rows {
+		cells := make([]string, len(row))
+		for i, c := range row {
+			// a tab or newline in a value would break the alignment
+			cells[i] = strings.NewReplacer("\t", " ", "\n", " ").Replace(c)
+		}
+		fmt.Fprintln(tw, strings.Join(cells, "\t"))
+	}
+	return tw.Flush()
+}
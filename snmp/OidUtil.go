@@ -1,47 +1,83 @@
 package snmp
 
 import (
-	"encoding/binary"
+	"errors"
 	"fmt"
 	"github.com/gosnmp/gosnmp"
 	gosmitypes "github.com/sleepinggenius2/gosmi/types"
+	"log/slog"
 	"snmp-test/snmp/parse"
 	"strconv"
 	"strings"
-	"time"
 )
 
-// /////////////////// used for test /////////////////
-
-func init() {
-	parse.LoadMibFromDir("/vob/xll/mibs")
-}
-
-func getMibObjByName(name string) *parse.MibObject {
-	mib, has := parse.FindMib(name)
+func getMibObjByOID(oid string) *parse.MibObject {
+	mib, has := parse.FindMib(oid)
 	if !has {
-		fmt.Println("failed to find: ", name)
+		slog.Debug("No mib object for OID", "oid", oid)
 	}
 	return mib
 }
 
-func getMibObjByOID(oid string) *parse.MibObject {
-	mib, has := parse.FindMib(oid)
-	if !has {
-		fmt.Println("failed to find: ", oid)
+// resolveObject accepts any form parse.Resolve does and returns the object
+// and the instance suffix typed after it, if any.
+func resolveObject(name string) (*parse.MibObject, string, error) {
+	mib, index, err := parse.Resolve(name)
+	if err != nil {
+		var ambiguous *parse.AmbiguousError
+		if errors.As(err, &ambiguous) {
+			return nil, "", fmt.Errorf("%w: %w", ErrUnknownObject, err)
+		}
+		return nil, "", unknownObject(name)
 	}
-	return mib
+	return mib, index, nil
 }
 
-/////////////////////////////////////////////////////////
+// resolveOID returns the OID to request for name. A numeric OID outside the
+// loaded MIBs is requested as is, with a nil object.
+func resolveOID(name string) (string, *parse.MibObject, error) {
+	mib, index, err := resolveObject(name)
+	if err == nil {
+		return AddIndex(mib.OID, index), mib, nil
+	}
+	if oid, numErr := parse.ToNumeric(name); numErr == nil && isNumericIndex(oid) {
+		return oid, nil, nil
+	}
+	return "", nil, err
+}
 
 const zeroIndex = ".0"
 
+// AddIndex appends an instance index to oid. index is either dotted
+// sub-identifiers or, for table columns, comma-separated human values of the
+// INDEX columns (e.g. "00:17:10:2B:69:58" or "ipv4,10.0.0.1") which are
+// encoded through the row's INDEX clause. An index that does not encode is
+// appended as is, see AddIndexErr.
 func AddIndex(oid, index string) string {
+	if ret, err := AddIndexErr(oid, index); err == nil {
+		return ret
+	}
+	return joinIndex(oid, index)
+}
+
+// AddIndexErr is AddIndex failing on a human index that the INDEX clause of
+// the column does not encode.
+func AddIndexErr(oid, index string) (string, error) {
 	if index == "" {
-		return oid
+		return oid, nil
+	}
+
+	if !isNumericIndex(index) {
+		encoded, err := encodeColumnIndex(oid, index)
+		if err != nil {
+			return "", fmt.Errorf("invalid index %s: %w", index, err)
+		}
+		index = encoded
 	}
+	return joinIndex(oid, index), nil
+}
 
+func joinIndex(oid, index string) string {
 	if strings.HasPrefix(index, ".") {
 		return fmt.Sprintf("%s%s", oid, index)
 	}
@@ -49,6 +85,18 @@ func AddIndex(oid, index string) string {
 	return fmt.Sprintf("%s.%s", oid, index)
 }
 
+func encodeColumnIndex(oid, index string) (string, error) {
+	column, rest, ok := parse.LongestMatch(oid)
+	if !ok || rest != "" {
+		return "", fmt.Errorf("%s is not a column", oid)
+	}
+	row, ok := parse.RowOf(column)
+	if !ok {
+		return "", fmt.Errorf("%s is not a column", oid)
+	}
+	return EncodeIndex(row, strings.Split(index, ",")...)
+}
+
 func GetIndex(parentOid, subOid string) string {
 	if !strings.HasPrefix(subOid, parentOid) {
 		return ""
@@ -65,9 +113,14 @@ func pduValueAsString(mib *parse.MibObject, pdu *gosnmp.SnmpPDU) string {
 	switch gosmitypes.BaseType(mib.SmiType) {
 	case gosmitypes.BaseTypeInteger32, gosmitypes.BaseTypeUnsigned32, gosmitypes.BaseTypeInteger64, gosmitypes.BaseTypeUnsigned64, gosmitypes.BaseTypeFloat32, gosmitypes.BaseTypeFloat64, gosmitypes.BaseTypeFloat128:
 		val := gosnmp.ToBigInt(pdu.Value)
+		if mib.DisplayHint != "" && val.IsInt64() {
+			if str, err := formatIntegerHint(mib.DisplayHint, val.Int64()); err == nil {
+				return str
+			}
+		}
 		return val.String()
 	case gosmitypes.BaseTypeOctetString:
-		return octetTypeAsString(mib.Type, pdu.Value)
+		return octetTypeAsString(mib, pdu.Value)
 	case gosmitypes.BaseTypeObjectIdentifier:
 		return pdu.Value.(string)[1:]
 	case gosmitypes.BaseTypeEnum:
@@ -83,13 +136,17 @@ func pduValueAsString(mib *parse.MibObject, pdu *gosnmp.SnmpPDU) string {
 	}
 }
 
-func octetTypeAsString(typ string, value interface{}) string {
+func octetTypeAsString(mib *parse.MibObject, value interface{}) string {
 	bytes, ok := value.([]byte)
 	if !ok {
+		// gosnmp decodes IpAddress to a dotted string
+		if str, ok := value.(string); ok {
+			return str
+		}
 		return ""
 	}
 
-	str := bytesOidsAsString(bytes, typ)
+	str := bytesOidsAsString(bytes, mib.Type, mib.DisplayHint)
 	return strings.ToValidUTF8(str, "�")
 }
 
@@ -101,30 +158,24 @@ func bytes2Ints(bytes []byte) []int {
 	return parts
 }
 
-func bytesOidsAsString(bytes []byte, typ string) string {
+// bytesOidsAsString renders an OCTET STRING. A few textual conventions get
+// dedicated formatting, everything else follows the DISPLAY-HINT, and
+// strings without one are shown as text if printable or as hex otherwise.
+func bytesOidsAsString(bytes []byte, typ, hint string) string {
 	indexOids := bytes2Ints(bytes)
 
 	switch typ {
 	case "MacAddress":
-		parts := make([]string, 6)
-		for i, o := range indexOids {
+		// SIZE (6) is not enforced by agents, EUI-64 and short values occur
+		parts := make([]string, len(bytes))
+		for i, o := range bytes {
 			parts[i] = fmt.Sprintf("%02X", o)
 		}
 		return strings.Join(parts, ":")
-	case "InetAddress", "InetAddressIPv4", "InetAddressIPv6":
-		return toIp(indexOids)
+	case "InetAddress", "InetAddressIPv4", "InetAddressIPv6", "InetAddressIPv4z", "InetAddressIPv6z", "InetAddressDNS":
+		return formatInetAddressGuess(typ, bytes)
 	case "TAddress":
-		return toTAddress(indexOids)
-	case "OctetString":
-		parts := make([]byte, len(indexOids))
-		for i, o := range indexOids {
-			parts[i] = byte(o)
-		}
-		if len(parts) == 0 {
-			return ""
-		}
-		//return fmt.Sprintf("0x%X", string(parts))
-		return string(parts)
+		return formatTAddressGuess(bytes)
 	case "DisplayString":
 		parts := make([]byte, len(indexOids))
 		for i, o := range indexOids {
@@ -132,53 +183,18 @@ func bytesOidsAsString(bytes []byte, typ string) string {
 		}
 		return string(parts)
 	case "DateAndTime":
-		return fmt.Sprintf("%d-%d-%d %d:%d:%d",
-			int(binary.BigEndian.Uint16(bytes[0:2])),
-			time.Month(bytes[2]),
-			int(bytes[3]),
-			int(bytes[4]),
-			int(bytes[5]),
-			int(bytes[6]),
-		)
-	default:
-		return string(bytes)
-	}
-}
-
-func toIp(ipArr []int) string {
-	switch len(ipArr) {
-	case 4:
-		return toIpv4(ipArr)
-	case 6:
-		return toIpv6(ipArr)
+		if str, err := formatDateAndTime(bytes); err == nil {
+			return str
+		}
+		return formatUnknownOctets(bytes)
 	default:
-		return ""
-	}
-}
-
-func toIpv4(ipArr []int) string {
-	parts := make([]string, 4)
-	for i, o := range ipArr {
-		parts[i] = strconv.Itoa(o)
-	}
-	return strings.Join(parts, ".")
-}
-
-func toIpv6(ipArr []int) string {
-	parts := make([]interface{}, 16)
-	for i, o := range ipArr {
-		parts[i] = o
+		if hint != "" {
+			if str, err := formatOctetHint(hint, bytes); err == nil {
+				return str
+			}
+		}
+		return formatUnknownOctets(bytes)
 	}
-	return fmt.Sprintf("%02X%02X:%02X%02X:%02X%02X:%02X%02X:%02X%02X:%02X%02X:%02X%02X:%02X%02X", parts...)
-}
-
-func toTAddress(indexOids []int) string {
-	ipArr, portArr := indexOids[:4], indexOids[4:]
-
-	ip := toIp(ipArr)
-	port := (portArr[0] << 8) + portArr[1]
-
-	return fmt.Sprintf("%s/%d", ip, port)
 }
 
 func enumAsString(valueName int, enumValues map[int]string) string {
@@ -190,26 +206,31 @@ func enumAsString(valueName int, enumValues map[int]string) string {
 }
 
 func bitsAsString(value interface{}, bitsValues map[int]string) string {
-	bytes, ok := value.([]byte)
-	if !ok {
-		return ""
-	}
+	return strings.Join(bitLabels(value, bitsValues), " ")
+}
 
-	var ret string
-	for k, v := range bitsValues {
-		bitOn := false
+// bitLabels lists the set bits of a BITS value in bit order, using the
+// MIB label or bit(N) for bits without one.
+func bitLabels(value interface{}, bitsValues map[int]string) []string {
+	var bytes []byte
+	switch v := value.(type) {
+	case []byte:
+		bytes = v
+	case string:
+		bytes = []byte(v)
+	}
 
+	labels := make([]string, 0)
+	for k := 0; k < len(bytes)*8; k++ {
 		// most significant byte most significant bit, then most significant byte 2nd most significant bit
-		if k < len(bytes)*8 {
-			if (bytes[k/8] & (128 >> (k % 8))) != 0 {
-				bitOn = true
-			}
+		if bytes[k/8]&(128>>(k%8)) == 0 {
+			continue
 		}
-
-		if bitOn {
-			ret += " " + v
+		if label, ok := bitsValues[k]; ok {
+			labels = append(labels, label)
+		} else {
+			labels = append(labels, fmt.Sprintf("bit(%d)", k))
 		}
 	}
-
-	return ret
+	return labels
 }
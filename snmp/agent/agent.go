@@ -0,0 +1,480 @@
+// Package agent is an in-process SNMP agent that serves a fixed data set,
+// so clients can be tested without a device.
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gosnmp/gosnmp"
+	"log/slog"
+	"net"
+	"snmp-test/snmp/record"
+	"snmp-test/snmp/scraper"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultEngineID is a text format (4) engine ID under the net-snmp
+// enterprise number.
+const defaultEngineID = "\x80\x00\x1f\x88\x04snmp-test"
+
+// maxMsgSize bounds responses to what fits in one UDP datagram.
+const maxMsgSize = 65507
+
+// Config describes the agent. One community pair serves v1 and v2c, one
+// USM user serves v3.
+type Config struct {
+	// listen address, default 127.0.0.1:0 (an ephemeral port)
+	Addr string
+
+	// Community grants read access, WriteCommunity read and write access.
+	// Community defaults to public, WriteCommunity to private.
+	Community      string
+	WriteCommunity string
+
+	// version 3, the fields mean what they do in scraper.ClientConfig
+	SecLevel                 string
+	SecName                  string
+	AuthenticationProtocol   string
+	AuthenticationPassphrase string
+	PrivacyProtocol          string
+	PrivacyPassphrase        string
+	// EngineID is the authoritative engine ID, default defaultEngineID.
+	EngineID string
+
+	// Data is the initial data set, e.g. from record.Load.
+	Data []gosnmp.SnmpPDU
+}
+
+// Agent answers GET, GETNEXT, GETBULK and SET requests on a UDP socket.
+type Agent struct {
+	config Config
+	store  *record.Dataset
+	conn   net.PacketConn
+
+	// decoder holds the USM state used to authenticate and decrypt v3
+	// requests; usm is the template of v3 responses.
+	decoder *gosnmp.GoSNMP
+	usm     *gosnmp.UsmSecurityParameters
+	flags   gosnmp.SnmpV3MsgFlags
+	started time.Time
+
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+}
+
+// Start listens on config.Addr and serves requests until Close.
+func Start(config Config) (*Agent, error) {
+	if config.Addr == "" {
+		config.Addr = "127.0.0.1:0"
+	}
+	if config.Community == "" {
+		config.Community = "public"
+	}
+	if config.WriteCommunity == "" {
+		config.WriteCommunity = "private"
+	}
+	if config.EngineID == "" {
+		config.EngineID = defaultEngineID
+	}
+
+	a := &Agent{config: config, started: time.Now()}
+	var err error
+	if a.store, err = record.NewDataset(config.Data); err != nil {
+		return nil, err
+	}
+	if err = a.initUSM(); err != nil {
+		return nil, err
+	}
+
+	if a.conn, err = net.ListenPacket("udp", config.Addr); err != nil {
+		return nil, err
+	}
+	a.wg.Add(1)
+	go a.serve()
+	return a, nil
+}
+
+func (a *Agent) initUSM() error {
+	a.usm = &gosnmp.UsmSecurityParameters{
+		AuthoritativeEngineID:    a.config.EngineID,
+		AuthoritativeEngineBoots: 1,
+		UserName:                 a.config.SecName,
+		AuthenticationProtocol:   gosnmp.NoAuth,
+		PrivacyProtocol:          gosnmp.NoPriv,
+	}
+
+	switch strings.ToLower(a.config.SecLevel) {
+	case "", "noauthnopriv":
+		a.flags = gosnmp.NoAuthNoPriv
+	case "authnopriv":
+		a.flags = gosnmp.AuthNoPriv
+	case "authpriv":
+		a.flags = gosnmp.AuthPriv
+	default:
+		return errors.New("invalid secLevel, support (noAuthNoPriv|authNoPriv|authPriv)")
+	}
+
+	if a.flags&gosnmp.AuthNoPriv != 0 {
+		auth, ok := authProtocols[strings.ToLower(a.config.AuthenticationProtocol)]
+		if !ok {
+			return fmt.Errorf("invalid authProtocol %s", a.config.AuthenticationProtocol)
+		}
+		a.usm.AuthenticationProtocol = auth
+		a.usm.AuthenticationPassphrase = a.config.AuthenticationPassphrase
+	}
+	if a.flags&gosnmp.AuthPriv == gosnmp.AuthPriv {
+		priv, ok := privProtocols[strings.ToLower(a.config.PrivacyProtocol)]
+		if !ok {
+			return fmt.Errorf("invalid privProtocol %s", a.config.PrivacyProtocol)
+		}
+		a.usm.PrivacyProtocol = priv
+		a.usm.PrivacyPassphrase = a.config.PrivacyPassphrase
+	}
+	if err := a.usm.InitSecurityKeys(); err != nil {
+		return err
+	}
+
+	a.decoder = &gosnmp.GoSNMP{
+		Version:            gosnmp.Version3,
+		SecurityModel:      gosnmp.UserSecurityModel,
+		MsgFlags:           a.flags,
+		SecurityParameters: a.usm,
+	}
+	return nil
+}
+
+var authProtocols = map[string]gosnmp.SnmpV3AuthProtocol{
+	"":        gosnmp.NoAuth,
+	"md5":     gosnmp.MD5,
+	"sha":     gosnmp.SHA,
+	"sha-224": gosnmp.SHA224,
+	"sha-256": gosnmp.SHA256,
+	"sha-384": gosnmp.SHA384,
+	"sha-512": gosnmp.SHA512,
+}
+
+var privProtocols = map[string]gosnmp.SnmpV3PrivProtocol{
+	"":        gosnmp.NoPriv,
+	"des":     gosnmp.DES,
+	"aes":     gosnmp.AES,
+	"aes192":  gosnmp.AES192,
+	"aes192c": gosnmp.AES192C,
+	"aes256":  gosnmp.AES256,
+	"aes256c": gosnmp.AES256C,
+}
+
+// Addr returns the address the agent listens on.
+func (a *Agent) Addr() *net.UDPAddr {
+	return a.conn.LocalAddr().(*net.UDPAddr)
+}
+
+// ClientConfig returns a client configuration for version that reaches
+// the agent with its credentials; v1 and v2c use the write community.
+func (a *Agent) ClientConfig(version string) *scraper.ClientConfig {
+	addr := a.Addr()
+	config := &scraper.ClientConfig{
+		Target:  addr.IP.String(),
+		Port:    uint16(addr.Port),
+		Version: version,
+		Timeout: time.Second,
+	}
+	if version == scraper.Version3 {
+		config.SecLevel = a.config.SecLevel
+		if config.SecLevel == "" {
+			config.SecLevel = "noAuthNoPriv"
+		}
+		config.SecName = a.config.SecName
+		config.AuthenticationProtocol = a.config.AuthenticationProtocol
+		config.AuthenticationPassphrase = a.config.AuthenticationPassphrase
+		config.PrivacyProtocol = a.config.PrivacyProtocol
+		config.PrivacyPassphrase = a.config.PrivacyPassphrase
+	} else {
+		config.Community = a.config.WriteCommunity
+	}
+	return config
+}
+
+// Set changes or adds instances behind the agent's back.
+func (a *Agent) Set(pdus ...gosnmp.SnmpPDU) error {
+	if err := a.store.Set(pdus); err != nil {
+		return fmt.Errorf("failed to set %s: %s", pdus[err.Index].Name, err.Status)
+	}
+	return nil
+}
+
+// Remove deletes every instance at or below oid.
+func (a *Agent) Remove(oid string) {
+	a.store.Remove(oid)
+}
+
+// Data returns the current data set in OID order.
+func (a *Agent) Data() []gosnmp.SnmpPDU {
+	return a.store.PDUs()
+}
+
+// Close stops the agent and waits for the serving goroutine.
+func (a *Agent) Close() error {
+	var err error
+	a.closeOnce.Do(func() {
+		err = a.conn.Close()
+		a.wg.Wait()
+	})
+	return err
+}
+
+func (a *Agent) serve() {
+	defer a.wg.Done()
+
+	buf := make([]byte, 65535)
+	for {
+		n, addr, err := a.conn.ReadFrom(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			slog.Debug("Agent read failed", "err", err)
+			continue
+		}
+
+		out := a.handle(buf[:n])
+		if out == nil {
+			continue
+		}
+		if _, err = a.conn.WriteTo(out, addr); err != nil {
+			slog.Debug("Agent write failed", "addr", addr, "err", err)
+		}
+	}
+}
+
+// handle decodes one message and returns the encoded answer, or nil to
+// drop it the way an agent does for bad credentials.
+func (a *Agent) handle(msg []byte) []byte {
+	req, err := a.decoder.UnmarshalTrap(msg, true)
+	if err != nil {
+		slog.Debug("Agent dropped undecodable request", "err", err)
+		return nil
+	}
+
+	var resp *gosnmp.SnmpPacket
+	if req.Version == gosnmp.Version3 {
+		resp = a.handleV3(req)
+	} else {
+		resp = a.handleCommunity(req)
+	}
+	if resp == nil {
+		return nil
+	}
+
+	out, err := resp.MarshalMsg()
+	if err != nil {
+		slog.Debug("Agent failed to encode response", "err", err)
+		return nil
+	}
+	return out
+}
+
+func (a *Agent) handleCommunity(req *gosnmp.SnmpPacket) *gosnmp.SnmpPacket {
+	write := req.Community == a.config.WriteCommunity
+	if !write && req.Community != a.config.Community {
+		slog.Debug("Agent dropped request with unknown community", "community", req.Community)
+		return nil
+	}
+
+	resp := a.respond(req, write)
+	if resp != nil {
+		resp.Version = req.Version
+		resp.Community = req.Community
+	}
+	return resp
+}
+
+func (a *Agent) handleV3(req *gosnmp.SnmpPacket) *gosnmp.SnmpPacket {
+	usp, ok := req.SecurityParameters.(*gosnmp.UsmSecurityParameters)
+	if !ok || req.SecurityModel != gosnmp.UserSecurityModel {
+		return nil
+	}
+
+	// discovery: tell the manager our engine ID, boots and time
+	if usp.AuthoritativeEngineID != a.config.EngineID {
+		return a.report(req, usmStatsUnknownEngineIDs)
+	}
+	if usp.UserName != a.config.SecName || req.MsgFlags&gosnmp.AuthPriv != a.flags {
+		slog.Debug("Agent dropped request with unknown user or security level", "user", usp.UserName)
+		return nil
+	}
+
+	resp := a.respond(req, true)
+	if resp == nil {
+		return nil
+	}
+	a.v3Header(req, resp, a.flags)
+	if err := a.usm.InitPacket(resp); err != nil {
+		slog.Debug("Agent failed to init v3 response", "err", err)
+		return nil
+	}
+	return resp
+}
+
+const usmStatsUnknownEngineIDs = ".1.3.6.1.6.3.15.1.1.4.0"
+
+func (a *Agent) report(req *gosnmp.SnmpPacket, oid string) *gosnmp.SnmpPacket {
+	resp := &gosnmp.SnmpPacket{
+		PDUType:   gosnmp.Report,
+		RequestID: req.RequestID,
+		Variables: []gosnmp.SnmpPDU{{Name: oid, Type: gosnmp.Counter32, Value: uint32(1)}},
+	}
+	a.v3Header(req, resp, gosnmp.NoAuthNoPriv)
+	return resp
+}
+
+// v3Header fills in the message header and security parameters of a
+// response to req.
+func (a *Agent) v3Header(req, resp *gosnmp.SnmpPacket, flags gosnmp.SnmpV3MsgFlags) {
+	usm := a.usm.Copy().(*gosnmp.UsmSecurityParameters)
+	usm.AuthoritativeEngineTime = uint32(time.Since(a.started).Seconds())
+	if flags == gosnmp.NoAuthNoPriv {
+		usm.UserName = ""
+		if reqUSM, ok := req.SecurityParameters.(*gosnmp.UsmSecurityParameters); ok {
+			usm.UserName = reqUSM.UserName
+		}
+	}
+
+	resp.Version = gosnmp.Version3
+	resp.MsgID = req.MsgID
+	resp.MsgFlags = flags
+	resp.SecurityModel = gosnmp.UserSecurityModel
+	resp.SecurityParameters = usm
+	resp.ContextEngineID = a.config.EngineID
+	resp.ContextName = req.ContextName
+}
+
+// respond runs the request against the store. v1 errors are reported as
+// noSuchName/badValue with the request varbinds, as RFC 1157 requires.
+func (a *Agent) respond(req *gosnmp.SnmpPacket, write bool) *gosnmp.SnmpPacket {
+	v1 := req.Version == gosnmp.Version1
+	resp := &gosnmp.SnmpPacket{PDUType: gosnmp.GetResponse, RequestID: req.RequestID}
+
+	switch req.PDUType {
+	case gosnmp.GetRequest:
+		for i, v := range req.Variables {
+			pdu := a.store.Get(v.Name)
+			if v1 && isException(pdu.Type) {
+				return errorResponse(resp, req, gosnmp.NoSuchName, i)
+			}
+			resp.Variables = append(resp.Variables, pdu)
+		}
+
+	case gosnmp.GetNextRequest:
+		for i, v := range req.Variables {
+			pdu, ok := a.store.Next(v.Name)
+			if v1 && !ok {
+				return errorResponse(resp, req, gosnmp.NoSuchName, i)
+			}
+			resp.Variables = append(resp.Variables, pdu)
+		}
+
+	case gosnmp.GetBulkRequest:
+		if v1 {
+			return nil
+		}
+		resp.Variables = a.bulk(req)
+
+	case gosnmp.SetRequest:
+		if !write {
+			status := gosnmp.NoAccess
+			if v1 {
+				status = gosnmp.NoSuchName
+			}
+			return errorResponse(resp, req, status, 0)
+		}
+		if err := a.store.Set(req.Variables); err != nil {
+			status := err.Status
+			if v1 {
+				status = gosnmp.BadValue
+			}
+			return errorResponse(resp, req, status, err.Index)
+		}
+		resp.Variables = req.Variables
+
+	default:
+		slog.Debug("Agent dropped unsupported pdu", "type", req.PDUType)
+		return nil
+	}
+	return resp
+}
+
+// bulk answers a GetBulk, trimming repetitions until the response fits in
+// one datagram.
+func (a *Agent) bulk(req *gosnmp.SnmpPacket) []gosnmp.SnmpPDU {
+	nonRepeaters := int(req.NonRepeaters)
+	if nonRepeaters > len(req.Variables) {
+		nonRepeaters = len(req.Variables)
+	}
+
+	var vars []gosnmp.SnmpPDU
+	for _, v := range req.Variables[:nonRepeaters] {
+		pdu, _ := a.store.Next(v.Name)
+		vars = append(vars, pdu)
+	}
+
+	repeaters := req.Variables[nonRepeaters:]
+	cursors := make([]string, len(repeaters))
+	for i, v := range repeaters {
+		cursors[i] = v.Name
+	}
+
+	size := 0
+	for _, v := range vars {
+		size += varbindSize(v)
+	}
+	for r := 0; r < int(req.MaxRepetitions) && len(cursors) > 0; r++ {
+		ended := true
+		round := make([]gosnmp.SnmpPDU, 0, len(cursors))
+		roundSize := 0
+		for i, cursor := range cursors {
+			pdu, ok := a.store.Next(cursor)
+			if ok {
+				cursors[i] = pdu.Name
+				ended = false
+			}
+			round = append(round, pdu)
+			roundSize += varbindSize(pdu)
+		}
+		if size+roundSize > maxMsgSize-512 && r > 0 {
+			break
+		}
+		vars = append(vars, round...)
+		size += roundSize
+		if ended {
+			break
+		}
+	}
+	return vars
+}
+
+// varbindSize estimates the encoded size of pdu generously.
+func varbindSize(pdu gosnmp.SnmpPDU) int {
+	n := len(pdu.Name) + 16
+	switch v := pdu.Value.(type) {
+	case []byte:
+		n += len(v)
+	case string:
+		n += len(v)
+	default:
+		n += 9
+	}
+	return n
+}
+
+func errorResponse(resp, req *gosnmp.SnmpPacket, status gosnmp.SNMPError, index int) *gosnmp.SnmpPacket {
+	resp.Error = status
+	resp.ErrorIndex = uint8(index + 1)
+	resp.Variables = req.Variables
+	return resp
+}
+
+func isException(typ gosnmp.Asn1BER) bool {
+	return typ == gosnmp.NoSuchObject || typ == gosnmp.NoSuchInstance || typ == gosnmp.EndOfMibView
+}
@@ -0,0 +1,197 @@
+package agent
+
+import (
+	"github.com/gosnmp/gosnmp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"snmp-test/snmp/record"
+	"snmp-test/snmp/scraper"
+	"testing"
+	"time"
+)
+
+const (
+	testDescr  = ".1.3.6.1.4.1.99999.1.1.0"
+	testUpTime = ".1.3.6.1.4.1.99999.1.2.0"
+	nodeName   = ".1.3.6.1.4.1.99999.2.1.3"
+	lastOID    = ".1.3.6.1.4.1.99999.3.1.6.1.4.10.0.0.1"
+)
+
+func startAgent(t *testing.T, config Config) *Agent {
+	t.Helper()
+	data, err := record.LoadWalk("../testdata/agent.walk")
+	require.NoError(t, err)
+	config.Data = data
+
+	a, err := Start(config)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = a.Close()
+	})
+	return a
+}
+
+func connect(t *testing.T, config *scraper.ClientConfig) *scraper.GoSNMPWrapper {
+	t.Helper()
+	client, err := scraper.NewGoSNMP(config)
+	require.NoError(t, err)
+	require.NoError(t, client.Connect())
+	t.Cleanup(func() {
+		_ = client.Close()
+	})
+	return client
+}
+
+func TestAgent_V2c(t *testing.T) {
+	a := startAgent(t, Config{})
+	client := connect(t, a.ClientConfig(scraper.Versionv2c))
+
+	packet, err := client.Get([]string{testDescr, nodeName + ".9", ".1.3.6.1.4.1.99998.1.0", nodeName})
+	require.NoError(t, err)
+	require.Len(t, packet.Variables, 4)
+	assert.Equal(t, []byte(`CASA "C100G"`), packet.Variables[0].Value)
+	assert.Equal(t, gosnmp.NoSuchInstance, packet.Variables[1].Type)
+	assert.Equal(t, gosnmp.NoSuchObject, packet.Variables[2].Type)
+	assert.Equal(t, gosnmp.NoSuchInstance, packet.Variables[3].Type)
+
+	packet, err = client.GetNext([]string{nodeName, lastOID})
+	require.NoError(t, err)
+	assert.Equal(t, nodeName+".0.23.16.43.105.88.10.0.0.1", packet.Variables[0].Name)
+	assert.Equal(t, gosnmp.EndOfMibView, packet.Variables[1].Type)
+
+	pdus, err := client.WalkAll(".1.3.6.1.4.1.99999.2")
+	require.NoError(t, err)
+	assert.Len(t, pdus, 6)
+
+	n := 0
+	require.NoError(t, client.Walk(".1.3.6.1.4.1.99999", func(gosnmp.SnmpPDU) error {
+		n++
+		return nil
+	}))
+	assert.Equal(t, len(a.Data()), n)
+}
+
+func TestAgent_GetBulk(t *testing.T) {
+	a := startAgent(t, Config{})
+	client := &gosnmp.GoSNMP{
+		Target:    "127.0.0.1",
+		Port:      uint16(a.Addr().Port),
+		Community: "public",
+		Version:   gosnmp.Version2c,
+		Timeout:   time.Second,
+	}
+	require.NoError(t, client.Connect())
+	defer client.Conn.Close()
+
+	packet, err := client.GetBulk([]string{testDescr, nodeName, lastOID}, 1, 3)
+	require.NoError(t, err)
+
+	// one non-repeater, then three rounds of two repeaters; the second
+	// repeater is at the end of the view from the start
+	require.Len(t, packet.Variables, 7)
+	assert.Equal(t, testUpTime, packet.Variables[0].Name)
+	assert.Equal(t, nodeName+".0.23.16.43.105.88.10.0.0.1", packet.Variables[1].Name)
+	assert.Equal(t, gosnmp.EndOfMibView, packet.Variables[2].Type)
+	assert.Equal(t, nodeName+".0.23.16.43.105.89.10.0.0.2", packet.Variables[3].Name)
+	assert.Equal(t, ".1.3.6.1.4.1.99999.2.1.4.0.23.16.43.105.88.10.0.0.1", packet.Variables[5].Name)
+
+	// all repeaters at the end stop the repetitions early
+	packet, err = client.GetBulk([]string{lastOID}, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, packet.Variables, 1)
+	assert.Equal(t, gosnmp.EndOfMibView, packet.Variables[0].Type)
+}
+
+func TestAgent_Set(t *testing.T) {
+	a := startAgent(t, Config{})
+	client := connect(t, a.ClientConfig(scraper.Versionv2c))
+
+	packet, err := client.Set([]gosnmp.SnmpPDU{
+		{Name: testDescr, Type: gosnmp.OctetString, Value: "C100G-130"},
+		{Name: nodeName + ".0.23.16.43.105.90.10.0.0.3", Type: gosnmp.OctetString, Value: "node-c"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, gosnmp.NoError, packet.Error)
+
+	packet, err = client.Get([]string{testDescr, nodeName + ".0.23.16.43.105.90.10.0.0.3"})
+	require.NoError(t, err)
+	assert.Equal(t, []byte("C100G-130"), packet.Variables[0].Value)
+	assert.Equal(t, []byte("node-c"), packet.Variables[1].Value)
+
+	// a wrong type anywhere rejects the whole request
+	packet, err = client.Set([]gosnmp.SnmpPDU{
+		{Name: testDescr, Type: gosnmp.OctetString, Value: "changed"},
+		{Name: testUpTime, Type: gosnmp.Integer, Value: 1},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, gosnmp.WrongType, packet.Error)
+	assert.Equal(t, uint8(2), packet.ErrorIndex)
+	packet, err = client.Get([]string{testDescr})
+	require.NoError(t, err)
+	assert.Equal(t, []byte("C100G-130"), packet.Variables[0].Value)
+
+	// the read community may not write
+	config := a.ClientConfig(scraper.Versionv2c)
+	config.Community = "public"
+	packet, err = connect(t, config).Set([]gosnmp.SnmpPDU{{Name: testDescr, Type: gosnmp.OctetString, Value: "x"}})
+	require.NoError(t, err)
+	assert.Equal(t, gosnmp.NoAccess, packet.Error)
+}
+
+func TestAgent_V1(t *testing.T) {
+	a := startAgent(t, Config{})
+	client := connect(t, a.ClientConfig(scraper.Version1))
+
+	packet, err := client.Get([]string{testDescr, nodeName + ".9"})
+	require.NoError(t, err)
+	assert.Equal(t, gosnmp.NoSuchName, packet.Error)
+	assert.Equal(t, uint8(2), packet.ErrorIndex)
+
+	packet, err = client.GetNext([]string{lastOID})
+	require.NoError(t, err)
+	assert.Equal(t, gosnmp.NoSuchName, packet.Error)
+
+	pdus, err := client.WalkAll(".1.3.6.1.4.1.99999.1")
+	require.NoError(t, err)
+	assert.Len(t, pdus, 6)
+}
+
+func TestAgent_V3(t *testing.T) {
+	a := startAgent(t, Config{
+		SecLevel:                 "authPriv",
+		SecName:                  "admin",
+		AuthenticationProtocol:   "SHA",
+		AuthenticationPassphrase: "authpassword",
+		PrivacyProtocol:          "AES",
+		PrivacyPassphrase:        "privpassword",
+	})
+	client := connect(t, a.ClientConfig(scraper.Version3))
+
+	packet, err := client.Get([]string{testDescr})
+	require.NoError(t, err)
+	assert.Equal(t, []byte(`CASA "C100G"`), packet.Variables[0].Value)
+
+	pdus, err := client.WalkAll(".1.3.6.1.4.1.99999.2")
+	require.NoError(t, err)
+	assert.Len(t, pdus, 6)
+
+	// a wrong passphrase is dropped like on a real agent
+	config := a.ClientConfig(scraper.Version3)
+	config.AuthenticationPassphrase = "wrongpassword"
+	config.Timeout = 100 * time.Millisecond
+	config.Retries = 0
+	_, err = connect(t, config).Get([]string{testDescr})
+	var timeout *scraper.TimeoutError
+	assert.ErrorAs(t, err, &timeout)
+}
+
+func TestAgent_UnknownCommunity(t *testing.T) {
+	a := startAgent(t, Config{})
+	config := a.ClientConfig(scraper.Versionv2c)
+	config.Community = "nope"
+	config.Timeout = 100 * time.Millisecond
+	config.Retries = 0
+
+	_, err := connect(t, config).Get([]string{testDescr})
+	assert.Error(t, err)
+}
@@ -0,0 +1,106 @@
+package snmp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+)
+
+// dateAndTimeHint is the DISPLAY-HINT of SNMPv2-TC DateAndTime.
+const dateAndTimeHint = "2d-1d-1d,1d:1d:1d.1d,1a1d:1d"
+
+// DecodeDateAndTime decodes an 8 or 11 octet SNMPv2-TC DateAndTime. The
+// 8 octet form carries no UTC offset, its zone is unknown; the wall clock
+// is returned with time.UTC as a placeholder.
+func DecodeDateAndTime(b []byte) (time.Time, error) {
+	if len(b) != 8 && len(b) != 11 {
+		return time.Time{}, fmt.Errorf("invalid DateAndTime: want 8 or 11 octets, got %d", len(b))
+	}
+
+	year := int(binary.BigEndian.Uint16(b[0:2]))
+	month, day, hour, minute, sec, deci := int(b[2]), int(b[3]), int(b[4]), int(b[5]), int(b[6]), int(b[7])
+	switch {
+	case month < 1 || month > 12:
+		return time.Time{}, fmt.Errorf("invalid DateAndTime: month %d", month)
+	case day < 1 || day > 31:
+		return time.Time{}, fmt.Errorf("invalid DateAndTime: day %d", day)
+	case hour > 23 || minute > 59 || sec > 60:
+		return time.Time{}, fmt.Errorf("invalid DateAndTime: time %d:%d:%d", hour, minute, sec)
+	case deci > 9:
+		return time.Time{}, fmt.Errorf("invalid DateAndTime: deci-seconds %d", deci)
+	}
+
+	loc := time.UTC
+	if len(b) == 11 {
+		sign := 1
+		switch b[8] {
+		case '+':
+		case '-':
+			sign = -1
+		default:
+			return time.Time{}, fmt.Errorf("invalid DateAndTime: direction %q", b[8])
+		}
+		if b[9] > 14 || b[10] > 59 {
+			return time.Time{}, fmt.Errorf("invalid DateAndTime: offset %d:%d", b[9], b[10])
+		}
+		offset := sign * (int(b[9])*3600 + int(b[10])*60)
+		if offset != 0 {
+			loc = time.FixedZone("", offset)
+		}
+	}
+
+	// a leap second (sec 60) is folded into the next minute by time.Date
+	return time.Date(year, time.Month(month), day, hour, minute, sec, deci*int(100*time.Millisecond), loc), nil
+}
+
+// EncodeDateAndTime encodes t as an 11 octet DateAndTime in t's zone,
+// truncating to deci-seconds.
+func EncodeDateAndTime(t time.Time) []byte {
+	b := make([]byte, 11)
+	binary.BigEndian.PutUint16(b[0:2], uint16(t.Year()))
+	b[2], b[3], b[4] = byte(t.Month()), byte(t.Day()), byte(t.Hour())
+	b[5], b[6], b[7] = byte(t.Minute()), byte(t.Second()), byte(t.Nanosecond()/int(100*time.Millisecond))
+
+	_, offset := t.Zone()
+	b[8] = '+'
+	if offset < 0 {
+		b[8] = '-'
+		offset = -offset
+	}
+	b[9], b[10] = byte(offset/3600), byte(offset%3600/60)
+	return b
+}
+
+// ParseDateAndTime accepts RFC 3339, the DateAndTime DISPLAY-HINT form
+// ("2024-5-7,10:24:11.0,+8:0") or "2024-5-7 10:24:11" and returns the
+// DateAndTime octets. The last form has no offset and yields 8 octets.
+func ParseDateAndTime(s string) ([]byte, error) {
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return EncodeDateAndTime(t), nil
+	}
+	if t, err := time.Parse("2006-1-2 15:4:5", s); err == nil {
+		return EncodeDateAndTime(t)[:8], nil
+	}
+
+	b, err := parseOctetHintValue(dateAndTimeHint, s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid date and time %q", s)
+	}
+	if _, err := DecodeDateAndTime(b); err != nil {
+		return nil, fmt.Errorf("invalid date and time %q: %w", s, err)
+	}
+	return b, nil
+}
+
+// formatDateAndTime renders b as RFC 3339, or for the 8 octet form without
+// a zone suffix in the offset-less form ParseDateAndTime accepts.
+func formatDateAndTime(b []byte) (string, error) {
+	t, err := DecodeDateAndTime(b)
+	if err != nil {
+		return "", err
+	}
+	if len(b) == 8 {
+		return t.Format("2006-01-02 15:04:05.999999999"), nil
+	}
+	return t.Format(time.RFC3339Nano), nil
+}
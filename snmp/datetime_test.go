@@ -0,0 +1,86 @@
+package snmp
+
+import (
+	"github.com/gosnmp/gosnmp"
+	"github.com/stretchr/testify/assert"
+	"snmp-test/snmp/parse"
+	"testing"
+	"time"
+)
+
+func TestDecodeDateAndTime(t *testing.T) {
+	got, err := DecodeDateAndTime([]byte{0x07, 0xe8, 5, 7, 10, 24, 11, 3, '+', 8, 0})
+	assert.NoError(t, err)
+	assert.Equal(t, "2024-05-07T10:24:11.3+08:00", got.Format(time.RFC3339Nano))
+	assert.True(t, got.Equal(time.Date(2024, 5, 7, 2, 24, 11, 300*int(time.Millisecond), time.UTC)))
+
+	got, err = DecodeDateAndTime([]byte{0x07, 0xe8, 12, 31, 23, 5, 9, 0, '-', 5, 30})
+	assert.NoError(t, err)
+	assert.Equal(t, "2024-12-31T23:05:09-05:30", got.Format(time.RFC3339))
+
+	got, err = DecodeDateAndTime([]byte{0x07, 0xe8, 5, 7, 10, 24, 11, 0})
+	assert.NoError(t, err)
+	assert.Equal(t, time.UTC, got.Location())
+
+	for _, b := range [][]byte{
+		nil,
+		{0x07, 0xe8, 5, 7, 10, 24},
+		{0x07, 0xe8, 5, 7, 10, 24, 11, 0, '+', 8},
+		{0x07, 0xe8, 13, 7, 10, 24, 11, 0},
+		{0x07, 0xe8, 5, 7, 10, 24, 11, 10},
+		{0x07, 0xe8, 5, 7, 10, 24, 11, 0, '*', 8, 0},
+	} {
+		_, err := DecodeDateAndTime(b)
+		assert.Error(t, err, "%v", b)
+	}
+}
+
+func TestParseDateAndTime(t *testing.T) {
+	want := []byte{0x07, 0xe8, 5, 7, 10, 24, 11, 5, '+', 8, 0}
+	for _, s := range []string{"2024-05-07T10:24:11.5+08:00", "2024-5-7,10:24:11.5,+8:0"} {
+		b, err := ParseDateAndTime(s)
+		assert.NoError(t, err, s)
+		assert.Equal(t, want, b, s)
+	}
+
+	b, err := ParseDateAndTime("2024-5-7 10:24:11")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0x07, 0xe8, 5, 7, 10, 24, 11, 0}, b)
+
+	// the 8 octet form has no zone and is rendered without one
+	for _, b := range [][]byte{{0x07, 0xe8, 5, 7, 10, 24, 11, 0}, {0x07, 0xe8, 5, 7, 10, 24, 11, 5}} {
+		s, err := formatDateAndTime(b)
+		assert.NoError(t, err)
+		assert.NotContains(t, s, "Z")
+		round, err := ParseDateAndTime(s)
+		assert.NoError(t, err, s)
+		assert.Equal(t, b, round, s)
+	}
+	s, _ := formatDateAndTime([]byte{0x07, 0xe8, 5, 7, 10, 24, 11, 5})
+	assert.Equal(t, "2024-05-07 10:24:11.5", s)
+
+	_, err = ParseDateAndTime("yesterday")
+	assert.Error(t, err)
+	_, err = ParseDateAndTime("2024-13-7,10:24:11.5,+8:0")
+	assert.Error(t, err)
+}
+
+func TestValueTime(t *testing.T) {
+	loadTestMibs(t)
+	mib, _ := parse.FindMib("testLastChanged")
+
+	pdu := &gosnmp.SnmpPDU{Name: ".1.2", Type: gosnmp.OctetString, Value: []byte{0x07, 0xe8, 5, 7, 10, 24, 11, 0, '+', 8, 0}}
+	v := newValue(mib, "0", pdu)
+	assert.Equal(t, "2024-05-07T10:24:11+08:00", v.String)
+	ts, err := v.Time()
+	assert.NoError(t, err)
+	_, offset := ts.Zone()
+	assert.Equal(t, 8*3600, offset)
+
+	// malformed lengths are reported, not panicked on
+	pdu.Value = []byte{0x07, 0xe8, 5}
+	v = newValue(mib, "0", pdu)
+	assert.Equal(t, "07 E8 05", v.String)
+	_, err = v.Time()
+	assert.Error(t, err)
+}
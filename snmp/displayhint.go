@@ -0,0 +1,357 @@
+package snmp
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// octetHintSpec is one octet-format specification of an RFC 2579
+// DISPLAY-HINT such as "1x:" or "*1d.".
+type octetHintSpec struct {
+	repeat     bool
+	length     int
+	format     byte
+	separator  byte
+	terminator byte
+}
+
+// parseOctetHint splits an OCTET STRING DISPLAY-HINT into its specs.
+func parseOctetHint(hint string) ([]octetHintSpec, error) {
+	var specs []octetHintSpec
+	for i := 0; i < len(hint); {
+		var spec octetHintSpec
+		if hint[i] == '*' {
+			spec.repeat = true
+			i++
+		}
+
+		start := i
+		for i < len(hint) && isDigit(hint[i]) {
+			i++
+		}
+		if start == i {
+			return nil, fmt.Errorf("invalid display hint %q: missing length", hint)
+		}
+		spec.length, _ = strconv.Atoi(hint[start:i])
+
+		if i >= len(hint) {
+			return nil, fmt.Errorf("invalid display hint %q: missing format", hint)
+		}
+		spec.format = hint[i]
+		switch spec.format {
+		case 'd', 'x', 'o', 'a', 't':
+		default:
+			return nil, fmt.Errorf("invalid display hint %q: unknown format %q", hint, spec.format)
+		}
+		i++
+
+		if i < len(hint) && !isDigit(hint[i]) && hint[i] != '*' {
+			spec.separator = hint[i]
+			i++
+			if spec.repeat && i < len(hint) && !isDigit(hint[i]) && hint[i] != '*' {
+				spec.terminator = hint[i]
+				i++
+			}
+		}
+		specs = append(specs, spec)
+	}
+	if len(specs) == 0 {
+		return nil, errors.New("empty display hint")
+	}
+	return specs, nil
+}
+
+// formatOctetHint renders b according to an OCTET STRING DISPLAY-HINT. The
+// last spec is applied repeatedly until b is exhausted.
+func formatOctetHint(hint string, b []byte) (string, error) {
+	specs, err := parseOctetHint(hint)
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	for i := 0; len(b) > 0; {
+		spec := specs[i]
+		if i < len(specs)-1 {
+			i++
+		}
+
+		count := 1
+		if spec.repeat {
+			count = int(b[0])
+			b = b[1:]
+		}
+
+		for n := 0; n < count && len(b) > 0; n++ {
+			take := spec.length
+			if take > len(b) {
+				take = len(b)
+			}
+			sb.WriteString(formatOctets(spec.format, b[:take]))
+			b = b[take:]
+
+			if len(b) == 0 {
+				break
+			}
+			last := n == count-1
+			if spec.separator != 0 && !(last && spec.terminator != 0) {
+				sb.WriteByte(spec.separator)
+			}
+		}
+		if spec.repeat && spec.terminator != 0 && len(b) > 0 {
+			sb.WriteByte(spec.terminator)
+		}
+	}
+	return sb.String(), nil
+}
+
+func formatOctets(format byte, b []byte) string {
+	switch format {
+	case 'a', 't':
+		return string(b)
+	}
+
+	// a field may be wider than 8 octets, e.g. "16d"
+	n := new(big.Int).SetBytes(b)
+	switch format {
+	case 'x':
+		return fmt.Sprintf("%0*x", 2*len(b), n)
+	case 'o':
+		return n.Text(8)
+	default:
+		return n.String()
+	}
+}
+
+// parseOctetHintValue is the inverse of formatOctetHint: it reads s as
+// rendered by hint and returns the octets it stands for.
+func parseOctetHintValue(hint, s string) ([]byte, error) {
+	specs, err := parseOctetHint(hint)
+	if err != nil {
+		return nil, err
+	}
+
+	var b []byte
+	for i := 0; len(s) > 0; {
+		spec := specs[i]
+		if i < len(specs)-1 {
+			i++
+		}
+
+		if !spec.repeat {
+			octets, rest, err := parseOctets(spec, s)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value %q for display hint %q: %w", s, hint, err)
+			}
+			b = append(b, octets...)
+			s = rest
+			if spec.separator != 0 && len(s) > 0 && s[0] == spec.separator {
+				s = s[1:]
+			}
+			continue
+		}
+
+		var group []byte
+		count := 0
+		for len(s) > 0 {
+			octets, rest, err := parseOctets(spec, s)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value %q for display hint %q: %w", s, hint, err)
+			}
+			group = append(group, octets...)
+			s = rest
+			count++
+			if len(s) == 0 {
+				break
+			}
+			if spec.terminator != 0 && s[0] == spec.terminator {
+				s = s[1:]
+				break
+			}
+			if spec.separator == 0 || s[0] != spec.separator {
+				break
+			}
+			s = s[1:]
+		}
+		if count > 255 {
+			return nil, fmt.Errorf("invalid value for display hint %q: %d repeats do not fit in one octet", hint, count)
+		}
+		b = append(b, byte(count))
+		b = append(b, group...)
+	}
+	return b, nil
+}
+
+// parseOctets reads one field of spec from the front of s and returns its
+// octets and the unread input.
+func parseOctets(spec octetHintSpec, s string) ([]byte, string, error) {
+	switch spec.format {
+	case 'a', 't':
+		n := 0
+		for n < len(s) && n < spec.length && s[n] != spec.separator && s[n] != spec.terminator {
+			n++
+		}
+		if n == 0 {
+			return nil, s, errors.New("empty field")
+		}
+		return []byte(s[:n]), s[n:], nil
+	}
+
+	base, maxDigits := 10, len(s)
+	switch spec.format {
+	case 'x':
+		base, maxDigits = 16, 2*spec.length
+	case 'o':
+		base = 8
+	}
+	n := 0
+	for n < len(s) && n < maxDigits && isBaseDigit(s[n], base) {
+		n++
+	}
+	if n == 0 {
+		return nil, s, fmt.Errorf("expected a base %d number at %q", base, s)
+	}
+	v, ok := new(big.Int).SetString(s[:n], base)
+	if !ok {
+		return nil, s, fmt.Errorf("invalid base %d number %q", base, s[:n])
+	}
+
+	length := spec.length
+	if spec.format == 'x' {
+		// a short trailing hex field stands for fewer octets
+		length = (n + 1) / 2
+	}
+	if v.BitLen() > 8*length {
+		return nil, s, fmt.Errorf("%s does not fit in %d octets", s[:n], length)
+	}
+	return v.FillBytes(make([]byte, length)), s[n:], nil
+}
+
+func isBaseDigit(c byte, base int) bool {
+	switch base {
+	case 16:
+		return isDigit(c) || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+	case 8:
+		return c >= '0' && c <= '7'
+	default:
+		return isDigit(c)
+	}
+}
+
+// formatIntegerHint renders v according to an INTEGER DISPLAY-HINT: "x",
+// "o", "b", "d" or "d-N" for N implied decimal places.
+func formatIntegerHint(hint string, v int64) (string, error) {
+	if hint == "" {
+		return "", errors.New("empty display hint")
+	}
+
+	switch hint[0] {
+	case 'x':
+		return strconv.FormatInt(v, 16), nil
+	case 'o':
+		return strconv.FormatInt(v, 8), nil
+	case 'b':
+		return strconv.FormatInt(v, 2), nil
+	case 'd':
+	default:
+		return "", fmt.Errorf("invalid display hint %q", hint)
+	}
+
+	if hint == "d" {
+		return strconv.FormatInt(v, 10), nil
+	}
+	if len(hint) < 3 || hint[1] != '-' {
+		return "", fmt.Errorf("invalid display hint %q", hint)
+	}
+	places, err := strconv.Atoi(hint[2:])
+	if err != nil || places < 0 {
+		return "", fmt.Errorf("invalid display hint %q", hint)
+	}
+
+	sign := ""
+	if v < 0 {
+		sign = "-"
+	}
+	digits := strings.TrimPrefix(strconv.FormatInt(v, 10), "-")
+	if places == 0 {
+		return sign + digits, nil
+	}
+	if len(digits) <= places {
+		digits = strings.Repeat("0", places-len(digits)+1) + digits
+	}
+	return sign + digits[:len(digits)-places] + "." + digits[len(digits)-places:], nil
+}
+
+// parseIntegerHint is the inverse of formatIntegerHint.
+func parseIntegerHint(hint, s string) (int64, error) {
+	if hint == "" {
+		return 0, errors.New("empty display hint")
+	}
+
+	switch hint[0] {
+	case 'x':
+		return strconv.ParseInt(s, 16, 64)
+	case 'o':
+		return strconv.ParseInt(s, 8, 64)
+	case 'b':
+		return strconv.ParseInt(s, 2, 64)
+	case 'd':
+	default:
+		return 0, fmt.Errorf("invalid display hint %q", hint)
+	}
+
+	if hint == "d" {
+		return strconv.ParseInt(s, 10, 64)
+	}
+	if len(hint) < 3 || hint[1] != '-' {
+		return 0, fmt.Errorf("invalid display hint %q", hint)
+	}
+	places, err := strconv.Atoi(hint[2:])
+	if err != nil || places < 0 {
+		return 0, fmt.Errorf("invalid display hint %q", hint)
+	}
+
+	whole, frac, _ := strings.Cut(s, ".")
+	if len(frac) > places {
+		return 0, fmt.Errorf("%q has more than %d decimal places", s, places)
+	}
+	if whole == "" || whole == "-" || strings.HasPrefix(frac, "-") || strings.HasPrefix(frac, "+") {
+		return 0, fmt.Errorf("invalid decimal %q", s)
+	}
+	return strconv.ParseInt(whole+frac+strings.Repeat("0", places-len(frac)), 10, 64)
+}
+
+// formatUnknownOctets shows printable text as is and anything else as hex.
+func formatUnknownOctets(b []byte) string {
+	if isPrintable(b) {
+		return string(b)
+	}
+	parts := make([]string, len(b))
+	for i, o := range b {
+		parts[i] = fmt.Sprintf("%02X", o)
+	}
+	return strings.Join(parts, " ")
+}
+
+func isPrintable(b []byte) bool {
+	if !utf8.Valid(b) {
+		return false
+	}
+	for _, r := range string(b) {
+		if r < 0x20 && r != '\t' && r != '\n' && r != '\r' {
+			return false
+		}
+		if r == 0x7f {
+			return false
+		}
+	}
+	return true
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
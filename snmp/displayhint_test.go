@@ -0,0 +1,177 @@
+package snmp
+
+import (
+	"bytes"
+	"github.com/gosnmp/gosnmp"
+	gosmitypes "github.com/sleepinggenius2/gosmi/types"
+	"github.com/stretchr/testify/assert"
+	"snmp-test/snmp/parse"
+	"strings"
+	"testing"
+)
+
+func TestFormatOctetHint(t *testing.T) {
+	for _, tc := range []struct {
+		hint string
+		in   []byte
+		want string
+	}{
+		{"1x:", []byte{0x00, 0x17, 0x10, 0x2b, 0x69, 0x58}, "00:17:10:2b:69:58"},
+		{"255a", []byte("C100G-130"), "C100G-130"},
+		{"1d.1d.1d.1d/1d", []byte{10, 0, 0, 1, 24}, "10.0.0.1/24"},
+		{"2x:", []byte{0xfe, 0x80, 0, 0, 0, 1}, "fe80:0000:0001"},
+		{"2d-1d-1d,1d:1d:1d.1d", []byte{0x07, 0xe8, 5, 7, 10, 24, 11, 0}, "2024-5-7,10:24:11.0"},
+		{"2d-1d-1d,1d:1d:1d.1d,1a1d:1d", []byte{0x07, 0xe8, 5, 7, 10, 24, 11, 0, '+', 8, 0}, "2024-5-7,10:24:11.0,+8:0"},
+		{"*1d./1d", []byte{3, 1, 2, 3, 9}, "1.2.3/9"},
+		{"1o", []byte{8, 9}, "1011"},
+		// fields wider than a uint64
+		{"16d", bytes.Repeat([]byte{0xff}, 16), "340282366920938463463374607431768211455"},
+		{"10x", []byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, "00010203040506070809"},
+		{"9o", []byte{1, 0, 0, 0, 0, 0, 0, 0, 0}, "2000000000000000000000"},
+	} {
+		got, err := formatOctetHint(tc.hint, tc.in)
+		assert.NoError(t, err, tc.hint)
+		assert.Equal(t, tc.want, got, tc.hint)
+	}
+
+	_, err := formatOctetHint("1q", []byte{1})
+	assert.Error(t, err)
+}
+
+func TestFormatIntegerHint(t *testing.T) {
+	for _, tc := range []struct {
+		hint string
+		in   int64
+		want string
+	}{
+		{"d", 1234, "1234"},
+		{"d-2", 1234, "12.34"},
+		{"d-2", 5, "0.05"},
+		{"d-1", -15, "-1.5"},
+		{"x", 255, "ff"},
+		{"o", 8, "10"},
+		{"b", 5, "101"},
+	} {
+		got, err := formatIntegerHint(tc.hint, tc.in)
+		assert.NoError(t, err, tc.hint)
+		assert.Equal(t, tc.want, got, tc.hint)
+	}
+}
+
+func TestPduValueAsString_DisplayHint(t *testing.T) {
+	loadTestMibs(t)
+
+	phys, _ := parse.FindMib("testPhysAddress")
+	assert.Equal(t, "1x:", phys.DisplayHint)
+	assert.Equal(t, "0a:0b", pduValueAsString(phys, &gosnmp.SnmpPDU{Type: gosnmp.OctetString, Value: []byte{10, 11}}))
+
+	temp, _ := parse.FindMib("testTemperature")
+	assert.Equal(t, "21.5", pduValueAsString(temp, &gosnmp.SnmpPDU{Type: gosnmp.Integer, Value: 215}))
+
+	blob, _ := parse.FindMib("testBlob")
+	assert.Equal(t, "00 FF 10", pduValueAsString(blob, &gosnmp.SnmpPDU{Type: gosnmp.OctetString, Value: []byte{0, 0xff, 0x10}}))
+	assert.Equal(t, "text", pduValueAsString(blob, &gosnmp.SnmpPDU{Type: gosnmp.OctetString, Value: []byte("text")}))
+
+	// a MacAddress of other than 6 octets is rendered in full
+	mac := &parse.MibObject{Name: "mac", Type: "MacAddress", SmiType: int(gosmitypes.BaseTypeOctetString)}
+	assert.Equal(t, "00:17:10:FF:FE:2B:69:58", pduValueAsString(mac, &gosnmp.SnmpPDU{Type: gosnmp.OctetString, Value: []byte{0x00, 0x17, 0x10, 0xff, 0xfe, 0x2b, 0x69, 0x58}}))
+	assert.Equal(t, "00:17", pduValueAsString(mac, &gosnmp.SnmpPDU{Type: gosnmp.OctetString, Value: []byte{0x00, 0x17}}))
+}
+
+func TestParseOctetHintValue_RoundTrip(t *testing.T) {
+	for _, tc := range []struct {
+		hint string
+		in   []byte
+	}{
+		{"1x:", []byte{0x00, 0x17, 0x10, 0x2b, 0x69, 0x58}},
+		{"1x", []byte{0x0a, 0x0b}},
+		{"2x:", []byte{0xfe, 0x80, 0, 0, 0, 1}},
+		{"2x:", []byte{0xfe, 0x80, 0x01}},
+		{"255a", []byte("C100G-130")},
+		{"1d.1d.1d.1d/1d", []byte{10, 0, 0, 1, 24}},
+		{"2d-1d-1d,1d:1d:1d.1d", []byte{0x07, 0xe8, 5, 7, 10, 24, 11, 0}},
+		{"2d-1d-1d,1d:1d:1d.1d,1a1d:1d", []byte{0x07, 0xe8, 5, 7, 10, 24, 11, 0, '-', 5, 30}},
+		{"*1d./1d", []byte{3, 1, 2, 3, 9}},
+		{"1o.", []byte{8, 9, 255}},
+		{"4d", []byte{0, 1, 0, 0}},
+		{"16d", bytes.Repeat([]byte{0xff}, 16)},
+		{"12x:", []byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11}},
+		{"9o", []byte{1, 0, 0, 0, 0, 0, 0, 0, 7}},
+	} {
+		str, err := formatOctetHint(tc.hint, tc.in)
+		assert.NoError(t, err, tc.hint)
+
+		got, err := parseOctetHintValue(tc.hint, str)
+		assert.NoError(t, err, tc.hint)
+		assert.Equal(t, tc.in, got, "%s: %s", tc.hint, str)
+	}
+
+	for _, tc := range []struct{ hint, in string }{
+		{"1d.", "256"},
+		{"1x:", "0g"},
+		{"1d.1d", "1-2"},
+		{"9d", "4722366482869645213696"},
+	} {
+		_, err := parseOctetHintValue(tc.hint, tc.in)
+		assert.Error(t, err, "%s: %s", tc.hint, tc.in)
+	}
+}
+
+func TestParseIntegerHint_RoundTrip(t *testing.T) {
+	for _, hint := range []string{"d", "d-1", "d-2", "x", "o", "b"} {
+		for _, v := range []int64{0, 5, -15, 1234, 2147483647} {
+			str, err := formatIntegerHint(hint, v)
+			assert.NoError(t, err, hint)
+
+			got, err := parseIntegerHint(hint, str)
+			assert.NoError(t, err, "%s: %s", hint, str)
+			assert.Equal(t, v, got, "%s: %s", hint, str)
+		}
+	}
+
+	v, err := parseIntegerHint("d-2", "1.5")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(150), v)
+	_, err = parseIntegerHint("d-1", "1.25")
+	assert.Error(t, err)
+}
+
+func TestEncodeOctets(t *testing.T) {
+	loadTestMibs(t)
+
+	for _, tc := range []struct {
+		name  string
+		value string
+		want  []byte
+	}{
+		{"testPhysAddress", "0a:0b", []byte{10, 11}},
+		{"testNodeMac", "00:17:10:2B:69:58", []byte{0x00, 0x17, 0x10, 0x2b, 0x69, 0x58}},
+		{"testNodeAddr", "10.0.0.1", []byte{10, 0, 0, 1}},
+		{"testDescr", "C100G-130", []byte("C100G-130")},
+		{"testLastChanged", "2024-5-7,10:24:11.0,+8:0", []byte{0x07, 0xe8, 5, 7, 10, 24, 11, 0, '+', 8, 0}},
+		{"testBlob", "text", []byte("text")},
+	} {
+		mib, ok := parse.FindMib(tc.name)
+		assert.True(t, ok, tc.name)
+
+		got, err := EncodeOctets(mib, tc.value)
+		assert.NoError(t, err, tc.name)
+		assert.Equal(t, tc.want, got, tc.name)
+
+		typ, value, err := encodeValue(mib, tc.value)
+		assert.NoError(t, err, tc.name)
+		if mib.Type != "DateAndTime" {
+			pdu := &gosnmp.SnmpPDU{Type: typ, Value: value}
+			assert.Equal(t, strings.ToLower(tc.value), strings.ToLower(pduValueAsString(mib, pdu)), tc.name)
+		}
+	}
+
+	ipv6 := &parse.MibObject{Name: "addr", Type: "InetAddressIPv6", SmiType: int(gosmitypes.BaseTypeOctetString)}
+	got, err := EncodeOctets(ipv6, "fe80::1")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0xfe, 0x80, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1}, got)
+
+	mac, _ := parse.FindMib("testNodeMac")
+	_, err = EncodeOctets(mac, "00:17:10")
+	assert.Error(t, err)
+}
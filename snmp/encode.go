@@ -0,0 +1,196 @@
+package snmp
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gosnmp/gosnmp"
+	gosmitypes "github.com/sleepinggenius2/gosmi/types"
+	"math"
+	"net"
+	"snmp-test/snmp/parse"
+	"strconv"
+	"strings"
+)
+
+// encodeValue turns a human value into the varbind type and value expected
+// by gosnmp for mib. It is the inverse of pduValueAsString.
+func encodeValue(mib *parse.MibObject, value string) (gosnmp.Asn1BER, interface{}, error) {
+	switch gosmitypes.BaseType(mib.SmiType) {
+	case gosmitypes.BaseTypeInteger32:
+		var i int64
+		var err error
+		if mib.DisplayHint != "" {
+			i, err = parseIntegerHint(mib.DisplayHint, value)
+		} else {
+			i, err = strconv.ParseInt(value, 10, 32)
+		}
+		if err != nil {
+			return 0, nil, fmt.Errorf("invalid integer %q: %w", value, err)
+		}
+		if i < math.MinInt32 || i > math.MaxInt32 {
+			return 0, nil, fmt.Errorf("integer %q out of range", value)
+		}
+		return gosnmp.Integer, int(i), nil
+	case gosmitypes.BaseTypeUnsigned32:
+		u, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			return 0, nil, fmt.Errorf("invalid unsigned integer %q: %w", value, err)
+		}
+		switch appType(mib) {
+		case "Counter32", "Counter":
+			return gosnmp.Counter32, uint32(u), nil
+		case "TimeTicks":
+			return gosnmp.TimeTicks, uint32(u), nil
+		default:
+			return gosnmp.Gauge32, uint32(u), nil
+		}
+	case gosmitypes.BaseTypeUnsigned64, gosmitypes.BaseTypeInteger64:
+		u, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return 0, nil, fmt.Errorf("invalid unsigned integer %q: %w", value, err)
+		}
+		return gosnmp.Counter64, u, nil
+	case gosmitypes.BaseTypeEnum:
+		i, err := enumFromString(value, mib.Syntax)
+		if err != nil {
+			return 0, nil, err
+		}
+		return gosnmp.Integer, i, nil
+	case gosmitypes.BaseTypeBits:
+		b, err := bitsFromString(value, mib.Syntax)
+		if err != nil {
+			return 0, nil, err
+		}
+		return gosnmp.OctetString, b, nil
+	case gosmitypes.BaseTypeObjectIdentifier:
+		// the forms reads accept: names, MODULE::name, instances, numeric
+		oid, _, err := resolveOID(value)
+		if err != nil {
+			return 0, nil, fmt.Errorf("invalid object identifier %q: %w", value, err)
+		}
+		return gosnmp.ObjectIdentifier, "." + oid, nil
+	case gosmitypes.BaseTypeOctetString:
+		b, err := EncodeOctets(mib, value)
+		if err != nil {
+			return 0, nil, err
+		}
+		if appType(mib) == "IpAddress" {
+			return gosnmp.IPAddress, net.IP(b).String(), nil
+		}
+		return gosnmp.OctetString, b, nil
+	default:
+		return 0, nil, fmt.Errorf("unsupported type %s for %s", gosmitypes.BaseType(mib.SmiType), mib.Name)
+	}
+}
+
+// appType is the application type mib is built on, which picks the varbind
+// type; objects made up without one go by their type name.
+func appType(mib *parse.MibObject) string {
+	if mib.AppType != "" {
+		return mib.AppType
+	}
+	return mib.Type
+}
+
+// EncodeOctets turns a human value of an OCTET STRING object into the
+// octets its textual convention defines, the inverse of how the value is
+// rendered: well-known TCs are parsed by type, others through their
+// DISPLAY-HINT, and strings without one are taken as is.
+func EncodeOctets(mib *parse.MibObject, value string) ([]byte, error) {
+	typ := mib.Type
+	if appType(mib) == "IpAddress" {
+		typ = "IpAddress"
+	}
+	b, err := octetsFromString(typ, mib.DisplayHint, value)
+	if err != nil {
+		return nil, err
+	}
+	if mib.Size > 0 && len(b) != mib.Size {
+		return nil, fmt.Errorf("invalid value %q for %s: want %d octets, got %d", value, mib.Name, mib.Size, len(b))
+	}
+	return b, nil
+}
+
+func octetsFromString(typ, hint, value string) ([]byte, error) {
+	switch typ {
+	case "IpAddress":
+		ip := net.ParseIP(value).To4()
+		if ip == nil {
+			return nil, fmt.Errorf("invalid ipv4 address %q", value)
+		}
+		return ip, nil
+	case "MacAddress":
+		mac, err := net.ParseMAC(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid mac address %q: %w", value, err)
+		}
+		return mac, nil
+	case "InetAddress", "InetAddressIPv4", "InetAddressIPv6", "InetAddressIPv4z", "InetAddressIPv6z", "InetAddressDNS", "Ipv6Address":
+		return parseInetAddress(typ, value)
+	case "TAddress":
+		return parseTAddress(value)
+	case "DateAndTime":
+		return ParseDateAndTime(value)
+	case "DisplayString":
+		return []byte(value), nil
+	}
+
+	if hint == "" {
+		return []byte(value), nil
+	}
+	return parseOctetHintValue(hint, value)
+}
+
+func enumFromString(value string, enumValues map[int]string) (int, error) {
+	for k, v := range enumValues {
+		if v == value {
+			return k, nil
+		}
+	}
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid enum value %q", value)
+	}
+	return i, nil
+}
+
+func bitsFromString(value string, bitsValues map[int]string) ([]byte, error) {
+	return encodeBits(strings.Fields(strings.ReplaceAll(value, ",", " ")), bitsValues)
+}
+
+// EncodeBits turns a list of bit labels of a BITS object into its octets.
+// Labels may also be bit numbers or bit(N) as produced for unnamed bits.
+func EncodeBits(mib *parse.MibObject, labels []string) ([]byte, error) {
+	if gosmitypes.BaseType(mib.SmiType) != gosmitypes.BaseTypeBits {
+		return nil, fmt.Errorf("%s is not a BITS object", mib.Name)
+	}
+	return encodeBits(labels, mib.Syntax)
+}
+
+func encodeBits(labels []string, bitsValues map[int]string) ([]byte, error) {
+	var bits []int
+	maxBit := -1
+	for _, label := range labels {
+		label = strings.TrimSpace(label)
+		if label == "" {
+			continue
+		}
+		if n, ok := strings.CutPrefix(label, "bit("); ok && strings.HasSuffix(n, ")") {
+			label = strings.TrimSuffix(n, ")")
+		}
+		bit, err := enumFromString(label, bitsValues)
+		if err != nil || bit < 0 {
+			return nil, errors.New("invalid bit label " + strconv.Quote(label))
+		}
+		bits = append(bits, bit)
+		if bit > maxBit {
+			maxBit = bit
+		}
+	}
+
+	b := make([]byte, maxBit/8+1)
+	for _, bit := range bits {
+		b[bit/8] |= 128 >> (bit % 8)
+	}
+	return b, nil
+}
@@ -0,0 +1,114 @@
+package snmp
+
+import (
+	"github.com/gosnmp/gosnmp"
+	gosmitypes "github.com/sleepinggenius2/gosmi/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"snmp-test/snmp/parse"
+	"testing"
+)
+
+func TestEncodeValue(t *testing.T) {
+	status := &parse.MibObject{
+		Name:    "ifAdminStatus",
+		SmiType: int(gosmitypes.BaseTypeEnum),
+		Syntax:  map[int]string{1: "up", 2: "down", 3: "testing"},
+	}
+	mac := &parse.MibObject{Name: "mac", Type: "MacAddress", SmiType: int(gosmitypes.BaseTypeOctetString)}
+	date := &parse.MibObject{Name: "date", Type: "DateAndTime", SmiType: int(gosmitypes.BaseTypeOctetString)}
+	ip := &parse.MibObject{Name: "ip", Type: "IpAddress", SmiType: int(gosmitypes.BaseTypeOctetString)}
+
+	for _, tc := range []struct {
+		mib   *parse.MibObject
+		value string
+		typ   gosnmp.Asn1BER
+	}{
+		{status, "down", gosnmp.Integer},
+		{mac, "00:17:10:2B:69:58", gosnmp.OctetString},
+		{date, "2024-05-07T10:24:11.5+08:00", gosnmp.OctetString},
+		{ip, "10.0.0.1", gosnmp.IPAddress},
+	} {
+		typ, value, err := encodeValue(tc.mib, tc.value)
+		assert.NoError(t, err, tc.value)
+		assert.Equal(t, tc.typ, typ, tc.value)
+
+		pdu := gosnmp.SnmpPDU{Type: typ, Value: value}
+		assert.Equal(t, tc.value, pduValueAsString(tc.mib, &pdu))
+	}
+
+	_, _, err := encodeValue(status, "sideways")
+	assert.Error(t, err)
+}
+
+func TestEncodeValue_TextualConvention(t *testing.T) {
+	loadTestMibs(t)
+
+	// the varbind type comes from the application type under the TC
+	for name, want := range map[string]gosnmp.Asn1BER{
+		"testUpTime":    gosnmp.TimeTicks,
+		"testLastReset": gosnmp.TimeTicks,
+		"testDrops":     gosnmp.Counter32,
+	} {
+		mib, ok := parse.FindMib(name)
+		require.True(t, ok, name)
+		typ, value, err := encodeValue(mib, "100")
+		require.NoError(t, err, name)
+		assert.Equal(t, want, typ, name)
+		assert.Equal(t, uint32(100), value, name)
+	}
+}
+
+func TestEncodeValue_ObjectIdentifier(t *testing.T) {
+	loadTestMibs(t)
+	pointer := &parse.MibObject{Name: "pointer", SmiType: int(gosmitypes.BaseTypeObjectIdentifier)}
+	descr, _ := parse.FindMib("testDescr")
+
+	for value, want := range map[string]string{
+		"testDescr":                "." + descr.OID,
+		"SNMP-TEST-MIB::testDescr": "." + descr.OID,
+		"testDescr.0":              "." + descr.OID + ".0",
+		"1.0.8802.1.1.2":           ".1.0.8802.1.1.2",
+		".1.0.8802.1.1.2":          ".1.0.8802.1.1.2",
+	} {
+		typ, got, err := encodeValue(pointer, value)
+		require.NoError(t, err, value)
+		assert.Equal(t, gosnmp.ObjectIdentifier, typ, value)
+		assert.Equal(t, want, got, value)
+	}
+
+	_, _, err := encodeValue(pointer, "noSuchName")
+	assert.Error(t, err)
+}
+
+func TestBits(t *testing.T) {
+	flags := &parse.MibObject{
+		Name:    "flags",
+		SmiType: int(gosmitypes.BaseTypeBits),
+		Syntax:  map[int]string{0: "up", 1: "broadcast", 3: "loopback", 9: "multicast"},
+	}
+
+	b := []byte{0xd0, 0x60}
+	want := []string{"up", "broadcast", "loopback", "multicast", "bit(10)"}
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, want, bitLabels(b, flags.Syntax), "order is stable")
+	}
+
+	pdu := &gosnmp.SnmpPDU{Name: ".1.2.0", Type: gosnmp.OctetString, Value: b}
+	assert.Equal(t, "up broadcast loopback multicast bit(10)", pduValueAsString(flags, pdu))
+	assert.Equal(t, want, newValue(flags, "0", pdu).Bits)
+
+	encoded, err := EncodeBits(flags, want)
+	assert.NoError(t, err)
+	assert.Equal(t, b, encoded)
+
+	_, value, err := encodeValue(flags, "multicast, up")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0x80, 0x40}, value)
+
+	_, err = EncodeBits(flags, []string{"sideways"})
+	assert.Error(t, err)
+
+	assert.Equal(t, "", pduValueAsString(flags, &gosnmp.SnmpPDU{Type: gosnmp.OctetString, Value: []byte{0}}))
+	assert.Equal(t, []string{"up"}, bitLabels("\x80", flags.Syntax))
+}
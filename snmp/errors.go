@@ -0,0 +1,127 @@
+package snmp
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gosnmp/gosnmp"
+	"snmp-test/snmp/scraper"
+	"sort"
+	"strings"
+)
+
+// ErrNotWritable is returned before sending a SET to an object whose
+// MAX-ACCESS is not read-write or read-create.
+var ErrNotWritable = errors.New("object is not writable")
+
+// PacketError is a non-zero error-status in a response PDU. Index is the
+// 1-based error-index reported by the agent and OID the varbind it points at,
+// if any.
+type PacketError struct {
+	Status gosnmp.SNMPError
+	Index  int
+	OID    string
+}
+
+func (e *PacketError) Error() string {
+	if e.OID != "" {
+		return fmt.Sprintf("packet error %s at index %d (%s)", e.Status, e.Index, e.OID)
+	}
+	return fmt.Sprintf("packet error %s at index %d", e.Status, e.Index)
+}
+
+func newPacketError(packet *gosnmp.SnmpPacket, oids []string) *PacketError {
+	e := &PacketError{Status: packet.Error, Index: int(packet.ErrorIndex)}
+	if e.Index > 0 && e.Index <= len(oids) {
+		e.OID = oids[e.Index-1]
+	}
+	return e
+}
+
+// ErrUnknownObject is returned when a name or OID is not in the loaded MIBs.
+var ErrUnknownObject = errors.New("unknown mib object")
+
+// ErrNoSuchObject and ErrNoSuchInstance mark varbinds the agent answered with
+// noSuchObject/noSuchInstance (or noSuchName for SNMPv1), i.e. the device is
+// up but does not implement the object or instance.
+var (
+	ErrNoSuchObject   = errors.New("no such object")
+	ErrNoSuchInstance = errors.New("no such instance")
+)
+
+// TimeoutError is returned when the target does not answer; see
+// scraper.TimeoutError.
+type TimeoutError = scraper.TimeoutError
+
+// PartialResultError is returned alongside the values that could be read
+// when some names or indexes failed. Failures is keyed like the results: by
+// the name as given for GetNames/GetValues and GetBulkByNames, by index for
+// GetNameByIndexes/GetValuesByIndexes and table rows.
+type PartialResultError struct {
+	Failures map[string]error
+}
+
+func (e *PartialResultError) Error() string {
+	keys := e.keys()
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		parts = append(parts, fmt.Sprintf("%s: %v", k, e.Failures[k]))
+	}
+	return fmt.Sprintf("partial result, %d failed: %s", len(keys), strings.Join(parts, "; "))
+}
+
+// Unwrap lets errors.Is and errors.As look at every failure.
+func (e *PartialResultError) Unwrap() []error {
+	keys := e.keys()
+	errs := make([]error, 0, len(keys))
+	for _, k := range keys {
+		errs = append(errs, e.Failures[k])
+	}
+	return errs
+}
+
+func (e *PartialResultError) keys() []string {
+	keys := make([]string, 0, len(e.Failures))
+	for k := range e.Failures {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (e *PartialResultError) add(key string, err error) {
+	if e.Failures == nil {
+		e.Failures = make(map[string]error)
+	}
+	e.Failures[key] = err
+}
+
+// rekey re-keys the failures of a lower level call with key, keeping the
+// ones key does not know.
+func (e *PartialResultError) rekey(key func(string) (string, bool)) {
+	failures := e.Failures
+	e.Failures = nil
+	for k, err := range failures {
+		if name, ok := key(k); ok {
+			k = name
+		}
+		e.add(k, err)
+	}
+}
+
+// orNil returns e as an error, or nil if nothing failed.
+func (e *PartialResultError) orNil() error {
+	if e == nil || len(e.Failures) == 0 {
+		return nil
+	}
+	return e
+}
+
+// isPartial reports whether err still comes with usable results.
+func isPartial(err error) bool {
+	var pe *PartialResultError
+	return errors.As(err, &pe)
+}
+
+func unknownObject(name string) error {
+	return fmt.Errorf("%w: %s", ErrUnknownObject, name)
+}
@@ -0,0 +1,28 @@
+package snmp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/gosnmp/gosnmp"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestPartialResultError(t *testing.T) {
+	partial := &PartialResultError{}
+	assert.NoError(t, partial.orNil())
+
+	partial.add("1.3.6.1.2.1.1.9.0", fmt.Errorf("%w: 1.3.6.1.2.1.1.9.0", ErrNoSuchInstance))
+	partial.add("1.3.6.1.2.1.1.8.0", &PacketError{Status: gosnmp.GenErr, Index: 1})
+	err := fmt.Errorf("get: %w", partial.orNil())
+
+	assert.True(t, errors.Is(err, ErrNoSuchInstance))
+	assert.False(t, errors.Is(err, ErrNoSuchObject))
+	var pe *PacketError
+	assert.True(t, errors.As(err, &pe))
+	assert.Equal(t, gosnmp.GenErr, pe.Status)
+	assert.True(t, isPartial(err))
+	assert.False(t, isTransportError(context.Background(), err))
+	assert.True(t, isTransportError(context.Background(), &TimeoutError{Target: "192.0.2.1"}))
+}
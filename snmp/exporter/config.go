@@ -0,0 +1,116 @@
+package exporter
+
+import (
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"os"
+	"snmp-test/snmp/parse"
+	"snmp-test/snmp/scraper"
+	"time"
+)
+
+// Config is the YAML configuration of the exporter:
+//
+//	client:
+//	  version: snmpv2c
+//	  community: public
+//	  timeout: 5s
+//	modules:
+//	  casa:
+//	    walk: [sysUpTime, ifTable, casaModuleTable]
+type Config struct {
+	Client  ClientConfig       `yaml:"client"`
+	Modules map[string]*Module `yaml:"modules"`
+}
+
+// ClientConfig holds the scraper.ClientConfig fields shared by all targets.
+type ClientConfig struct {
+	Port           uint16        `yaml:"port"`
+	Transport      string        `yaml:"transport"`
+	Version        string        `yaml:"version"`
+	Community      string        `yaml:"community"`
+	Timeout        time.Duration `yaml:"timeout"`
+	Retries        int           `yaml:"retries"`
+	MaxRepetitions uint32        `yaml:"max_repetitions"`
+
+	SecLevel                 string `yaml:"security_level"`
+	SecName                  string `yaml:"username"`
+	AuthenticationProtocol   string `yaml:"auth_protocol"`
+	AuthenticationPassphrase string `yaml:"auth_password"`
+	PrivacyProtocol          string `yaml:"priv_protocol"`
+	PrivacyPassphrase        string `yaml:"priv_password"`
+}
+
+// Module is a named set of objects collected together.
+type Module struct {
+	// Walk lists the scalars, tables and columns to collect by MIB name.
+	Walk []string `yaml:"walk"`
+	// Prefix is prepended to metric names, default "snmp".
+	Prefix string `yaml:"prefix"`
+}
+
+// LoadConfig reads and validates a YAML configuration file.
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseConfig(data)
+}
+
+// ParseConfig parses a YAML configuration and checks that every walked
+// object is known to the loaded MIBs.
+func ParseConfig(data []byte) (*Config, error) {
+	config := &Config{}
+	if err := yaml.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("invalid exporter config: %w", err)
+	}
+	if config.Client.Version == "" {
+		config.Client.Version = scraper.Versionv2c
+	}
+	if config.Client.Timeout == 0 {
+		config.Client.Timeout = 5 * time.Second
+	}
+
+	for name, module := range config.Modules {
+		if module == nil || len(module.Walk) == 0 {
+			return nil, fmt.Errorf("module %s walks nothing", name)
+		}
+		if module.Prefix == "" {
+			module.Prefix = "snmp"
+		}
+		for _, object := range module.Walk {
+			if _, ok := parse.FindMib(object); !ok {
+				return nil, fmt.Errorf("module %s: unknown object %s", name, object)
+			}
+		}
+	}
+	return config, nil
+}
+
+// clientConfig returns the client configuration for target, a host with an
+// optional port.
+func (c *ClientConfig) clientConfig(target string, port uint16) *scraper.ClientConfig {
+	if port == 0 {
+		port = c.Port
+	}
+	if port == 0 {
+		port = 161
+	}
+	return &scraper.ClientConfig{
+		Target:                   target,
+		Port:                     port,
+		Transport:                c.Transport,
+		Version:                  c.Version,
+		Community:                c.Community,
+		SecLevel:                 c.SecLevel,
+		SecName:                  c.SecName,
+		AuthenticationProtocol:   c.AuthenticationProtocol,
+		AuthenticationPassphrase: c.AuthenticationPassphrase,
+		PrivacyProtocol:          c.PrivacyProtocol,
+		PrivacyPassphrase:        c.PrivacyPassphrase,
+		Timeout:                  c.Timeout,
+		Retries:                  c.Retries,
+		MaxRepetitions:           c.MaxRepetitions,
+	}
+}
@@ -0,0 +1,308 @@
+package exporter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	gosmitypes "github.com/sleepinggenius2/gosmi/types"
+	"log/slog"
+	"math/big"
+	"net"
+	"net/http"
+	"snmp-test/snmp"
+	"snmp-test/snmp/parse"
+	"snmp-test/snmp/scraper"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const contentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Exporter serves /metrics?target=...&module=... in the Prometheus text
+// format, collecting the module's objects from target on every scrape.
+type Exporter struct {
+	config    *Config
+	newClient func(config *scraper.ClientConfig) snmp.SnmpClient
+}
+
+// Option configures an Exporter.
+type Option func(e *Exporter)
+
+// WithClientFactory replaces snmp.NewClient, e.g. to point the exporter at
+// a simulated agent.
+func WithClientFactory(newClient func(config *scraper.ClientConfig) snmp.SnmpClient) Option {
+	return func(e *Exporter) {
+		e.newClient = newClient
+	}
+}
+
+// New returns an exporter for config.
+func New(config *Config, opts ...Option) *Exporter {
+	e := &Exporter{config: config, newClient: func(c *scraper.ClientConfig) snmp.SnmpClient {
+		return snmp.NewClient(c)
+	}}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+// Handler returns a mux serving the exporter on /metrics.
+func (e *Exporter) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", e)
+	return mux
+}
+
+func (e *Exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	target := query.Get("target")
+	if target == "" {
+		http.Error(w, "target parameter is missing", http.StatusBadRequest)
+		return
+	}
+	moduleName := query.Get("module")
+	module, ok := e.config.Modules[moduleName]
+	if !ok {
+		http.Error(w, fmt.Sprintf("unknown module %q", moduleName), http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	if s := r.Header.Get("X-Prometheus-Scrape-Timeout-Seconds"); s != "" {
+		if seconds, err := strconv.ParseFloat(s, 64); err == nil && seconds > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, time.Duration(seconds*float64(time.Second)))
+			defer cancel()
+		}
+	}
+
+	fs, err := e.Collect(ctx, target, module)
+	if err != nil && len(fs.order) == 0 {
+		slog.Warn("Scrape failed", "target", target, "module", moduleName, "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	if err := fs.write(w); err != nil {
+		slog.Debug("Failed to write metrics", "target", target, "err", err)
+	}
+}
+
+// Collect scrapes module from target. It returns whatever was collected
+// along with the errors of the objects that failed. A partial scrape still
+// succeeds; snmp_scrape_object_success tells which objects failed.
+func (e *Exporter) Collect(ctx context.Context, target string, module *Module) (*families, error) {
+	host, port := splitTarget(target)
+	client := e.newClient(e.config.Client.clientConfig(host, port))
+	defer client.Close()
+
+	st := time.Now()
+	fs := newFamilies()
+	var errs []error
+	objectSuccess := make([]float64, len(module.Walk))
+	for i, name := range module.Walk {
+		objectSuccess[i] = 1
+		if err := collectObject(ctx, client, module.Prefix, name, fs); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			objectSuccess[i] = 0
+		}
+	}
+
+	err := errors.Join(errs...)
+	if len(errs) == len(module.Walk) {
+		// nothing answered, report the failure instead of an empty page
+		return newFamilies(), err
+	}
+	if err != nil {
+		slog.Debug("Partial scrape", "target", target, "err", err)
+	}
+
+	fs.add("snmp_scrape_duration_seconds", "Time the SNMP scrape took.", "gauge", nil, time.Since(st).Seconds())
+	fs.add("snmp_scrape_success", "Whether the scrape collected data, see snmp_scrape_object_success for what is missing.", "gauge", nil, 1)
+	for i, name := range module.Walk {
+		fs.add("snmp_scrape_object_success", "Whether every instance of the object was collected.", "gauge",
+			[]label{{name: "object", value: name}}, objectSuccess[i])
+	}
+	return fs, err
+}
+
+func splitTarget(target string) (string, uint16) {
+	host, port, err := net.SplitHostPort(target)
+	if err != nil {
+		return target, 0
+	}
+	p, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return target, 0
+	}
+	return host, uint16(p)
+}
+
+func collectObject(ctx context.Context, client snmp.SnmpClient, prefix, name string, fs *families) error {
+	mib, ok := parse.FindMib(name)
+	if !ok {
+		return fmt.Errorf("%w: %s", snmp.ErrUnknownObject, name)
+	}
+
+	switch mib.Kind {
+	case "Scalar":
+		v, err := client.GetValue(ctx, name)
+		if err != nil {
+			return err
+		}
+		addValue(fs, prefix, v, nil)
+	case "Table", "Row":
+		rows, err := client.GetBulkTableValues(ctx, name)
+		if len(rows) == 0 && err != nil {
+			return err
+		}
+		addRows(fs, prefix, mib, rows)
+		return err
+	case "Column":
+		values, err := client.GetBulkValues(ctx, name)
+		if err != nil {
+			return err
+		}
+		indexes := make([]string, 0, len(values))
+		for index := range values {
+			indexes = append(indexes, index)
+		}
+		sortIndexes(indexes)
+		for _, index := range indexes {
+			addValue(fs, prefix, values[index], []label{{name: "index", value: index}})
+		}
+	default:
+		return fmt.Errorf("cannot export %s of kind %s", name, mib.Kind)
+	}
+	return nil
+}
+
+// addRows exports a table: index components and string columns become
+// labels of the numeric and enum columns, and of an _info metric per row.
+// An accessible string INDEX column is labelled once, from its value.
+func addRows(fs *families, prefix string, table *parse.MibObject, rows []snmp.Row) {
+	sort.Slice(rows, func(i, j int) bool { return snmp.CompareIndex(rows[i].Index, rows[j].Index) < 0 })
+
+	for _, row := range rows {
+		var labels []label
+		if len(row.IndexValues) > 0 {
+			for _, iv := range row.IndexValues {
+				if v, ok := row.Values[iv.Object.Name]; ok && isLabel(v) {
+					continue
+				}
+				labels = append(labels, label{name: sanitizeName(iv.Object.Name), value: iv.Value})
+			}
+		} else {
+			labels = append(labels, label{name: "index", value: row.Index})
+		}
+
+		var metrics []*snmp.Value
+		var info *parse.MibObject
+		for _, v := range row.Values {
+			if isLabel(v) {
+				labels = append(labels, label{name: sanitizeName(v.Object.Name), value: v.String})
+				info = v.Object
+			} else {
+				metrics = append(metrics, v)
+			}
+		}
+		labels = sortLabels(labels)
+
+		sort.Slice(metrics, func(i, j int) bool { return metrics[i].Object.Name < metrics[j].Object.Name })
+		for _, v := range metrics {
+			addValue(fs, prefix, v, labels)
+		}
+		if info != nil {
+			entry, ok := parse.RowOf(info)
+			if !ok {
+				entry = table
+			}
+			fs.add(metricName(prefix, entry.Name)+"_info", help(entry), "gauge", labels, 1)
+		}
+	}
+}
+
+// addValue exports one value: numbers as counters or gauges, enums as a
+// state set with one sample per state, and strings as an _info metric.
+func addValue(fs *families, prefix string, v *snmp.Value, labels []label) {
+	obj := v.Object
+	if obj == nil {
+		return
+	}
+	name := metricName(prefix, obj.Name)
+
+	switch {
+	case gosmitypes.BaseType(obj.SmiType) == gosmitypes.BaseTypeEnum && len(obj.Syntax) > 0 && v.Number != nil:
+		states := make([]int, 0, len(obj.Syntax)+1)
+		for n := range obj.Syntax {
+			states = append(states, n)
+		}
+		if _, ok := obj.Syntax[v.EnumNumber]; !ok {
+			states = append(states, v.EnumNumber)
+		}
+		sort.Ints(states)
+		// an enum INDEX column already labels its row with the same name
+		stateLabel := sanitizeName(obj.Name)
+		for _, l := range labels {
+			if l.name == stateLabel {
+				stateLabel += "_state"
+				break
+			}
+		}
+		for _, n := range states {
+			state := obj.Syntax[n]
+			if state == "" {
+				state = strconv.Itoa(n)
+			}
+			value := 0.0
+			if n == v.EnumNumber {
+				value = 1
+			}
+			fs.add(name, help(obj), "gauge", withLabel(labels, stateLabel, state), value)
+		}
+	case v.Number != nil:
+		f, _ := new(big.Float).SetInt(v.Number).Float64()
+		typ := "gauge"
+		if isCounter(obj) {
+			typ = "counter"
+		}
+		fs.add(name, help(obj), typ, labels, f)
+	default:
+		fs.add(name+"_info", help(obj), "gauge", withLabel(labels, sanitizeName(obj.Name), v.String), 1)
+	}
+}
+
+// isLabel reports whether a column is exported as a label of its row.
+func isLabel(v *snmp.Value) bool {
+	return v.Object != nil && v.Number == nil
+}
+
+func isCounter(obj *parse.MibObject) bool {
+	switch gosmitypes.BaseType(obj.SmiType) {
+	case gosmitypes.BaseTypeUnsigned32, gosmitypes.BaseTypeUnsigned64:
+		return strings.Contains(obj.Type, "Counter")
+	}
+	return false
+}
+
+func withLabel(labels []label, name, value string) []label {
+	ret := make([]label, 0, len(labels)+1)
+	ret = append(ret, labels...)
+	return sortLabels(append(ret, label{name: name, value: value}))
+}
+
+func metricName(prefix, name string) string {
+	return sanitizeName(prefix + "_" + name)
+}
+
+func help(obj *parse.MibObject) string {
+	return fmt.Sprintf("%s::%s (%s)", obj.Module, obj.Name, obj.OID)
+}
+
+func sortIndexes(indexes []string) {
+	sort.Slice(indexes, func(i, j int) bool { return snmp.CompareIndex(indexes[i], indexes[j]) < 0 })
+}
@@ -0,0 +1,227 @@
+package exporter
+
+import (
+	"context"
+	"github.com/gosnmp/gosnmp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"snmp-test/snmp"
+	"snmp-test/snmp/agent"
+	"snmp-test/snmp/parse"
+	"snmp-test/snmp/record"
+	"snmp-test/snmp/scraper"
+	"strings"
+	"sync"
+	"testing"
+)
+
+var loadOnce sync.Once
+
+func loadTestMibs(t *testing.T) {
+	t.Helper()
+	loadOnce.Do(func() {
+		parse.LoadMibFromDir("../testdata/mibs")
+	})
+}
+
+func value(t *testing.T, name string, number int64, str string) *snmp.Value {
+	mib, ok := parse.FindMib(name)
+	require.True(t, ok, name)
+	v := &snmp.Value{Object: mib, String: str}
+	if str == "" {
+		v.Number = big.NewInt(number)
+		v.EnumNumber = int(number)
+	}
+	return v
+}
+
+// fakeClient answers from fixed values.
+type fakeClient struct {
+	snmp.SnmpClient
+	t *testing.T
+}
+
+func (f *fakeClient) GetValue(ctx context.Context, name string) (*snmp.Value, error) {
+	switch name {
+	case "testDescr":
+		return value(f.t, name, 0, `CASA "C100G"`), nil
+	case "testUpTime":
+		return value(f.t, name, 18295586, ""), nil
+	}
+	return nil, snmp.ErrNoSuchObject
+}
+
+func (f *fakeClient) GetBulkTableValues(ctx context.Context, name string) ([]snmp.Row, error) {
+	entry, _ := parse.FindMib("testNodeEntry")
+	row := func(index, mac, addr, nodeName string, octets, status int64) snmp.Row {
+		indexValues, err := snmp.DecodeIndex(entry, index)
+		require.NoError(f.t, err)
+		values := map[string]*snmp.Value{
+			"testNodeName":      value(f.t, "testNodeName", 0, nodeName),
+			"testNodeInOctets":  value(f.t, "testNodeInOctets", octets, ""),
+			"testNodeRowStatus": value(f.t, "testNodeRowStatus", status, ""),
+		}
+		if mac != "" {
+			values["testNodeMac"] = value(f.t, "testNodeMac", 0, mac)
+		}
+		if addr != "" {
+			values["testNodeAddr"] = value(f.t, "testNodeAddr", 0, addr)
+		}
+		return snmp.Row{Index: index, IndexValues: indexValues, Values: values}
+	}
+	return []snmp.Row{
+		row("0.23.16.43.105.89.10.0.0.2", "", "", "node-b", 20, 2),
+		// an accessible INDEX column walked along with the others
+		row("0.23.16.43.105.88.10.0.0.1", "", "10.0.0.1", "node-a", 1000, 1),
+	}, nil
+}
+
+func (f *fakeClient) Close() error {
+	return nil
+}
+
+const testConfig = `
+client:
+  version: snmpv2c
+  community: public
+modules:
+  test:
+    walk: [testDescr, testUpTime, testNodeTable]
+`
+
+func TestExporter(t *testing.T) {
+	loadTestMibs(t)
+	config, err := ParseConfig([]byte(testConfig))
+	require.NoError(t, err)
+
+	var got *scraper.ClientConfig
+	e := New(config, WithClientFactory(func(c *scraper.ClientConfig) snmp.SnmpClient {
+		got = c
+		return &fakeClient{t: t}
+	}))
+	srv := httptest.NewServer(e.Handler())
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/metrics?target=10.0.0.9:1161&module=test")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, contentType, resp.Header.Get("Content-Type"))
+	assert.Equal(t, "10.0.0.9", got.Target)
+	assert.Equal(t, uint16(1161), got.Port)
+	assert.Equal(t, "public", got.Community)
+
+	text := string(body)
+	for _, line := range []string{
+		"# TYPE snmp_testDescr_info gauge",
+		`snmp_testDescr_info{testDescr="CASA \"C100G\""} 1`,
+		"# TYPE snmp_testUpTime gauge",
+		"snmp_testUpTime 18295586",
+		"# TYPE snmp_testNodeInOctets counter",
+		`snmp_testNodeInOctets{testNodeAddr="10.0.0.1",testNodeMac="00:17:10:2B:69:58",testNodeName="node-a"} 1000`,
+		`snmp_testNodeRowStatus{testNodeAddr="10.0.0.2",testNodeMac="00:17:10:2B:69:59",testNodeName="node-b",testNodeRowStatus="active"} 0`,
+		`snmp_testNodeRowStatus{testNodeAddr="10.0.0.2",testNodeMac="00:17:10:2B:69:59",testNodeName="node-b",testNodeRowStatus="notInService"} 1`,
+		`snmp_testNodeEntry_info{testNodeAddr="10.0.0.1",testNodeMac="00:17:10:2B:69:58",testNodeName="node-a"} 1`,
+		"snmp_scrape_success 1",
+	} {
+		assert.Contains(t, text, line+"\n")
+	}
+	// rows come out in index order
+	assert.Less(t, strings.Index(text, `testNodeName="node-a"`), strings.Index(text, `testNodeName="node-b"`))
+	// an index column also walked as a value is labelled once
+	for _, line := range strings.Split(text, "\n") {
+		assert.LessOrEqual(t, strings.Count(line, "testNodeAddr="), 1, line)
+	}
+}
+
+func TestAddValue_EnumIndex(t *testing.T) {
+	loadTestMibs(t)
+	fs := newFamilies()
+	labels := []label{{name: "testNodeRowStatus", value: "active"}}
+	addValue(fs, "snmp", value(t, "testNodeRowStatus", 2, ""), labels)
+
+	var b strings.Builder
+	require.NoError(t, fs.write(&b))
+	assert.Contains(t, b.String(), `snmp_testNodeRowStatus{testNodeRowStatus="active",testNodeRowStatus_state="notInService"} 1`+"\n")
+}
+
+func TestExporter_Agent(t *testing.T) {
+	loadTestMibs(t)
+	pdus, err := record.LoadWalk("../testdata/agent.walk")
+	require.NoError(t, err)
+	temperature, _ := parse.FindMib("testTemperature")
+	addr, _ := parse.FindMib("testNodeAddr")
+	data, err := record.NewDataset(pdus)
+	require.NoError(t, err)
+	// testTemperature is missing, the not-accessible testNodeAddr is served
+	// all the same
+	data.Remove(temperature.OID)
+	require.NoError(t, data.Add(gosnmp.SnmpPDU{Name: addr.OID + ".0.23.16.43.105.88.10.0.0.1", Type: gosnmp.IPAddress, Value: "10.0.0.1"}))
+
+	a, err := agent.Start(agent.Config{Data: data.PDUs()})
+	require.NoError(t, err)
+	defer a.Close()
+
+	config, err := ParseConfig([]byte(`
+client:
+  community: public
+modules:
+  test:
+    walk: [testUpTime, testTemperature, testNodeTable]
+`))
+	require.NoError(t, err)
+	srv := httptest.NewServer(New(config).Handler())
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/metrics?module=test&target=" + a.Addr().String())
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	text := string(body)
+	for _, line := range []string{
+		"# TYPE snmp_testUpTime gauge",
+		"snmp_testUpTime 18295586",
+		"# TYPE snmp_testNodeInOctets counter",
+		`snmp_testNodeInOctets{testNodeAddr="10.0.0.1",testNodeMac="00:17:10:2B:69:58",testNodeName="node-a"} 1000`,
+		`snmp_testNodeInOctets{testNodeAddr="10.0.0.2",testNodeMac="00:17:10:2B:69:59",testNodeName="node-b"} 20`,
+		`snmp_testNodeRowStatus{testNodeAddr="10.0.0.2",testNodeMac="00:17:10:2B:69:59",testNodeName="node-b",testNodeRowStatus="notInService"} 1`,
+		`snmp_testNodeEntry_info{testNodeAddr="10.0.0.1",testNodeMac="00:17:10:2B:69:58",testNodeName="node-a"} 1`,
+		// the missing object does not fail the scrape
+		"snmp_scrape_success 1",
+		`snmp_scrape_object_success{object="testUpTime"} 1`,
+		`snmp_scrape_object_success{object="testTemperature"} 0`,
+		`snmp_scrape_object_success{object="testNodeTable"} 1`,
+	} {
+		assert.Contains(t, text, line+"\n")
+	}
+	assert.NotContains(t, text, "snmp_testTemperature")
+	for _, line := range strings.Split(text, "\n") {
+		assert.LessOrEqual(t, strings.Count(line, "testNodeAddr="), 1, line)
+	}
+}
+
+func TestExporter_BadRequest(t *testing.T) {
+	loadTestMibs(t)
+	config, err := ParseConfig([]byte(testConfig))
+	require.NoError(t, err)
+	e := New(config, WithClientFactory(func(c *scraper.ClientConfig) snmp.SnmpClient {
+		return &fakeClient{t: t}
+	}))
+
+	for _, url := range []string{"/metrics?module=test", "/metrics?target=r1&module=nope"} {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, url, nil))
+		assert.Equal(t, http.StatusBadRequest, rec.Code, url)
+	}
+
+	_, err = ParseConfig([]byte("modules:\n  bad:\n    walk: [noSuchObject]\n"))
+	assert.Error(t, err)
+}
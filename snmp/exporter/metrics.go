@@ -0,0 +1,115 @@
+package exporter
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// family is one metric family of the text exposition format.
+type family struct {
+	name    string
+	help    string
+	typ     string
+	samples []sample
+}
+
+type sample struct {
+	labels []label
+	value  float64
+}
+
+type label struct {
+	name, value string
+}
+
+// families collects metric families in the order they are first seen.
+type families struct {
+	order  []*family
+	byName map[string]*family
+}
+
+func newFamilies() *families {
+	return &families{byName: make(map[string]*family)}
+}
+
+func (fs *families) add(name, help, typ string, labels []label, value float64) {
+	f, ok := fs.byName[name]
+	if !ok {
+		f = &family{name: name, help: help, typ: typ}
+		fs.byName[name] = f
+		fs.order = append(fs.order, f)
+	}
+	f.samples = append(f.samples, sample{labels: labels, value: value})
+}
+
+// write renders the families in the Prometheus text format 0.0.4.
+func (fs *families) write(w io.Writer) error {
+	var sb strings.Builder
+	for _, f := range fs.order {
+		fmt.Fprintf(&sb, "# HELP %s %s\n", f.name, escapeHelp(f.help))
+		fmt.Fprintf(&sb, "# TYPE %s %s\n", f.name, f.typ)
+		for _, s := range f.samples {
+			sb.WriteString(f.name)
+			if len(s.labels) > 0 {
+				sb.WriteByte('{')
+				for i, l := range s.labels {
+					if i > 0 {
+						sb.WriteByte(',')
+					}
+					fmt.Fprintf(&sb, "%s=\"%s\"", l.name, escapeLabel(l.value))
+				}
+				sb.WriteByte('}')
+			}
+			sb.WriteByte(' ')
+			sb.WriteString(formatFloat(s.value))
+			sb.WriteByte('\n')
+		}
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+var helpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+var labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+// sanitizeName maps a MIB name to a valid metric or label name.
+func sanitizeName(name string) string {
+	b := []byte(name)
+	for i, c := range b {
+		valid := c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (i > 0 && c >= '0' && c <= '9')
+		if !valid {
+			b[i] = '_'
+		}
+	}
+	return string(b)
+}
+
+// sortLabels orders labels by name so samples of a family line up.
+func sortLabels(labels []label) []label {
+	sort.SliceStable(labels, func(i, j int) bool { return labels[i].name < labels[j].name })
+	return labels
+}
@@ -0,0 +1,346 @@
+// Package fault wraps a scraper and makes it misbehave like a device on a
+// flaky link: requests time out or lose their response, responses carry
+// error statuses, walks end early or return OIDs twice or out of order, and
+// everything takes longer.
+package fault
+
+import (
+	"context"
+	"errors"
+	"github.com/gosnmp/gosnmp"
+	"math"
+	"math/rand"
+	"snmp-test/snmp/scraper"
+	"sync"
+	"time"
+)
+
+// errInjected is wrapped by the timeouts the scraper makes up.
+var errInjected = errors.New("request timeout (injected)")
+
+// Latency draws the delay added to a request.
+type Latency func(r *rand.Rand) time.Duration
+
+// Constant always delays by d.
+func Constant(d time.Duration) Latency {
+	return func(*rand.Rand) time.Duration {
+		return d
+	}
+}
+
+// Uniform delays by a duration in [min, max).
+func Uniform(min, max time.Duration) Latency {
+	return func(r *rand.Rand) time.Duration {
+		if max <= min {
+			return min
+		}
+		return min + time.Duration(r.Int63n(int64(max-min)))
+	}
+}
+
+// Normal delays by a normally distributed duration, negative draws are 0.
+func Normal(mean, stddev time.Duration) Latency {
+	return func(r *rand.Rand) time.Duration {
+		return clamp(float64(mean) + r.NormFloat64()*float64(stddev))
+	}
+}
+
+// Exponential delays by an exponentially distributed duration, a few
+// requests are much slower than the mean.
+func Exponential(mean time.Duration) Latency {
+	return func(r *rand.Rand) time.Duration {
+		return clamp(r.ExpFloat64() * float64(mean))
+	}
+}
+
+func clamp(d float64) time.Duration {
+	if d < 0 {
+		return 0
+	}
+	if d > math.MaxInt64 {
+		return math.MaxInt64
+	}
+	return time.Duration(d)
+}
+
+// Config sets how often each fault happens. Rates are probabilities in
+// [0, 1]; Timeout, Drop and PacketError are drawn once per call, the walk
+// faults once per call or per PDU as noted.
+type Config struct {
+	// Timeout fails a request before it reaches the device, Drop loses the
+	// response after the device handled it, so a set is applied. Both
+	// return a *scraper.TimeoutError after TimeoutAfter.
+	Timeout      float64
+	Drop         float64
+	TimeoutAfter time.Duration
+
+	// PacketError answers a request with one of PacketErrors, default
+	// tooBig, genErr and noSuchName. A walk hit by it ends early without
+	// an error, which is what gosnmp does when a response carries one.
+	PacketError  float64
+	PacketErrors []gosnmp.SNMPError
+
+	// Truncate ends a walk after a random number of PDUs.
+	Truncate float64
+	// Duplicate repeats a PDU of a walk, Reorder swaps it with the next one;
+	// both are drawn per PDU.
+	Duplicate float64
+	Reorder   float64
+
+	// Latency, if set, delays every call.
+	Latency Latency
+}
+
+// Option configures a Scraper.
+type Option func(s *Scraper)
+
+// WithRand sets the source of all random decisions.
+func WithRand(r *rand.Rand) Option {
+	return func(s *Scraper) {
+		s.rand = r
+	}
+}
+
+// WithSeed makes the faults reproducible: the same seed and the same
+// sequence of calls inject the same faults.
+func WithSeed(seed int64) Option {
+	return WithRand(rand.New(rand.NewSource(seed)))
+}
+
+// Scraper injects faults into the calls to the scraper it wraps.
+type Scraper struct {
+	scraper.SNMPScraper
+	config Config
+
+	// c receives the options passed to the inner scraper so the request
+	// context is known
+	c *gosnmp.GoSNMP
+
+	mu   sync.Mutex
+	rand *rand.Rand
+}
+
+var _ scraper.SNMPScraper = (*Scraper)(nil)
+
+// New wraps inner.
+func New(inner scraper.SNMPScraper, config Config, opts ...Option) *Scraper {
+	if len(config.PacketErrors) == 0 {
+		config.PacketErrors = []gosnmp.SNMPError{gosnmp.TooBig, gosnmp.GenErr, gosnmp.NoSuchName}
+	}
+	s := &Scraper{SNMPScraper: inner, config: config, c: &gosnmp.GoSNMP{}}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.rand == nil {
+		s.rand = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	return s
+}
+
+func (s *Scraper) SetOptions(fns ...func(snmp *gosnmp.GoSNMP)) {
+	s.SNMPScraper.SetOptions(fns...)
+	for _, fn := range fns {
+		fn(s.c)
+	}
+}
+
+func (s *Scraper) Get(oids []string) (*gosnmp.SnmpPacket, error) {
+	return s.request(oids, "getting from", func() (*gosnmp.SnmpPacket, error) {
+		return s.SNMPScraper.Get(oids)
+	})
+}
+
+func (s *Scraper) GetNext(oids []string) (*gosnmp.SnmpPacket, error) {
+	return s.request(oids, "getting next from", func() (*gosnmp.SnmpPacket, error) {
+		return s.SNMPScraper.GetNext(oids)
+	})
+}
+
+func (s *Scraper) GetBulk(oids []string, nonRepeaters uint8, maxRepetitions uint32) (*gosnmp.SnmpPacket, error) {
+	return s.request(oids, "getting bulk from", func() (*gosnmp.SnmpPacket, error) {
+		return s.SNMPScraper.GetBulk(oids, nonRepeaters, maxRepetitions)
+	})
+}
+
+func (s *Scraper) Set(pdus []gosnmp.SnmpPDU) (*gosnmp.SnmpPacket, error) {
+	oids := make([]string, len(pdus))
+	for i, pdu := range pdus {
+		oids[i] = pdu.Name
+	}
+	return s.request(oids, "setting on", func() (*gosnmp.SnmpPacket, error) {
+		return s.SNMPScraper.Set(pdus)
+	})
+}
+
+// request runs one request on oids through the faults.
+func (s *Scraper) request(oids []string, op string, do func() (*gosnmp.SnmpPacket, error)) (*gosnmp.SnmpPacket, error) {
+	f := s.draw(len(oids))
+	if err := s.before(f, op); err != nil {
+		return nil, err
+	}
+	if f.status != gosnmp.NoError {
+		return s.packetError(f, oids), nil
+	}
+	packet, err := do()
+	if err == nil && f.drop {
+		return nil, s.timeout(f, op)
+	}
+	return packet, err
+}
+
+func (s *Scraper) WalkAll(oid string) ([]gosnmp.SnmpPDU, error) {
+	f := s.draw(0)
+	if err := s.before(f, "walking"); err != nil {
+		return nil, err
+	}
+	pdus, err := s.SNMPScraper.WalkAll(oid)
+	if err != nil {
+		return pdus, err
+	}
+	if f.drop {
+		return nil, s.timeout(f, "walking")
+	}
+	return mangle(f, s.config, pdus), nil
+}
+
+// Walk collects the subtree before handing it to fn, so that it can be
+// truncated and reordered like the result of WalkAll. It does not stream:
+// the latency is paid once before the first PDU and a truncated walk ends
+// early without fn having seen a partial response arrive.
+func (s *Scraper) Walk(oid string, fn gosnmp.WalkFunc) error {
+	pdus, err := s.WalkAll(oid)
+	if err != nil {
+		return err
+	}
+	for _, pdu := range pdus {
+		if err := fn(pdu); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// faults are the decisions for one call.
+type faults struct {
+	timeout bool
+	drop    bool
+	status  gosnmp.SNMPError
+	index   int
+	latency time.Duration
+	// walkSeed drives the per-PDU faults of a walk.
+	walkSeed int64
+}
+
+// draw decides the faults of a call on n varbinds up front, before the
+// inner scraper is asked. The per-PDU walk faults come from a source of
+// their own seeded here, so the length of a walk does not shift the faults
+// of later calls.
+func (s *Scraper) draw(n int) faults {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var f faults
+	f.timeout = hit(s.rand, s.config.Timeout)
+	f.drop = hit(s.rand, s.config.Drop)
+	if hit(s.rand, s.config.PacketError) {
+		f.status = s.config.PacketErrors[s.rand.Intn(len(s.config.PacketErrors))]
+		if f.status != gosnmp.TooBig && n > 0 {
+			f.index = 1 + s.rand.Intn(n)
+		}
+	}
+	if s.config.Latency != nil {
+		f.latency = s.config.Latency(s.rand)
+	}
+	f.walkSeed = s.rand.Int63()
+	return f
+}
+
+func hit(r *rand.Rand, rate float64) bool {
+	return rate > 0 && r.Float64() < rate
+}
+
+// before waits out the latency and an injected timeout.
+func (s *Scraper) before(f faults, op string) error {
+	if err := s.sleep(f.latency); err != nil {
+		return err
+	}
+	if f.timeout {
+		return s.timeout(f, op)
+	}
+	return nil
+}
+
+func (s *Scraper) timeout(f faults, op string) error {
+	if err := s.sleep(s.config.TimeoutAfter); err != nil {
+		return err
+	}
+	return &scraper.TimeoutError{Target: s.target(), Op: op, Duration: f.latency + s.config.TimeoutAfter, Err: errInjected}
+}
+
+// sleep waits for d or until the request context is done.
+func (s *Scraper) sleep(d time.Duration) error {
+	ctx := s.c.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *Scraper) target() string {
+	if t, ok := s.SNMPScraper.(interface{ Target() string }); ok {
+		return t.Target()
+	}
+	return s.c.Target
+}
+
+// packetError is the response of an agent refusing the request with
+// f.status; the varbinds are echoed as the request had them.
+func (s *Scraper) packetError(f faults, oids []string) *gosnmp.SnmpPacket {
+	vars := make([]gosnmp.SnmpPDU, len(oids))
+	for i, oid := range oids {
+		vars[i] = gosnmp.SnmpPDU{Name: oid, Type: gosnmp.Null}
+	}
+	return &gosnmp.SnmpPacket{
+		Version:    s.c.Version,
+		PDUType:    gosnmp.GetResponse,
+		Error:      f.status,
+		ErrorIndex: uint8(f.index),
+		Variables:  vars,
+	}
+}
+
+// mangle truncates, duplicates and reorders the PDUs of a walk; a packet
+// error in f truncates it for sure.
+func mangle(f faults, config Config, pdus []gosnmp.SnmpPDU) []gosnmp.SnmpPDU {
+	r := rand.New(rand.NewSource(f.walkSeed))
+	if (f.status != gosnmp.NoError || hit(r, config.Truncate)) && len(pdus) > 0 {
+		pdus = pdus[:r.Intn(len(pdus))]
+	}
+	if config.Duplicate <= 0 && config.Reorder <= 0 {
+		return pdus
+	}
+
+	out := make([]gosnmp.SnmpPDU, 0, len(pdus))
+	for _, pdu := range pdus {
+		out = append(out, pdu)
+		if hit(r, config.Duplicate) {
+			out = append(out, pdu)
+		}
+	}
+	for i := 0; i+1 < len(out); i++ {
+		if hit(r, config.Reorder) {
+			out[i], out[i+1] = out[i+1], out[i]
+		}
+	}
+	return out
+}
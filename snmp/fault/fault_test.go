@@ -0,0 +1,167 @@
+package fault
+
+import (
+	"context"
+	"github.com/gosnmp/gosnmp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"math/rand"
+	"snmp-test/snmp/agent"
+	"snmp-test/snmp/record"
+	"snmp-test/snmp/scraper"
+	"testing"
+	"time"
+)
+
+const (
+	testDescr = ".1.3.6.1.4.1.99999.1.1.0"
+	testNode  = ".1.3.6.1.4.1.99999.2"
+)
+
+func replay(t *testing.T) *record.Replay {
+	t.Helper()
+	r, err := record.LoadReplay("../testdata/agent.walk")
+	require.NoError(t, err)
+	return r
+}
+
+func TestScraper_PassThrough(t *testing.T) {
+	s := New(replay(t), Config{})
+
+	packet, err := s.Get([]string{testDescr})
+	require.NoError(t, err)
+	assert.Equal(t, []byte(`CASA "C100G"`), packet.Variables[0].Value)
+
+	pdus, err := s.WalkAll(testNode)
+	require.NoError(t, err)
+	assert.Len(t, pdus, 6)
+}
+
+func TestScraper_Timeout(t *testing.T) {
+	inner := replay(t)
+	s := New(inner, Config{Timeout: 1, TimeoutAfter: 10 * time.Millisecond})
+
+	st := time.Now()
+	_, err := s.Get([]string{testDescr})
+	var timeout *scraper.TimeoutError
+	require.ErrorAs(t, err, &timeout)
+	assert.GreaterOrEqual(t, time.Since(st), 10*time.Millisecond)
+
+	_, err = s.WalkAll(testNode)
+	assert.ErrorAs(t, err, &timeout)
+
+	// a dropped response still reaches the device
+	s = New(inner, Config{Drop: 1})
+	_, err = s.Set([]gosnmp.SnmpPDU{{Name: testDescr, Type: gosnmp.OctetString, Value: "C100G-130"}})
+	assert.ErrorAs(t, err, &timeout)
+	assert.Equal(t, []byte("C100G-130"), inner.Data().Get(testDescr).Value)
+}
+
+func TestScraper_PacketError(t *testing.T) {
+	s := New(replay(t), Config{PacketError: 1, PacketErrors: []gosnmp.SNMPError{gosnmp.NoSuchName}})
+
+	packet, err := s.Get([]string{testDescr, testNode + ".1.3.0.23.16.43.105.88.10.0.0.1"})
+	require.NoError(t, err)
+	assert.Equal(t, gosnmp.NoSuchName, packet.Error)
+	assert.Contains(t, []uint8{1, 2}, packet.ErrorIndex)
+
+	packet, err = s.Set([]gosnmp.SnmpPDU{{Name: testDescr, Type: gosnmp.OctetString, Value: "x"}})
+	require.NoError(t, err)
+	assert.Equal(t, gosnmp.NoSuchName, packet.Error)
+	assert.Equal(t, uint8(1), packet.ErrorIndex)
+
+	// like gosnmp, a walk ends quietly at an error status
+	pdus, err := s.WalkAll(testNode)
+	require.NoError(t, err)
+	assert.Less(t, len(pdus), 6)
+}
+
+func TestScraper_Walk(t *testing.T) {
+	pdus, err := New(replay(t), Config{Truncate: 1}).WalkAll(testNode)
+	require.NoError(t, err)
+	assert.Less(t, len(pdus), 6)
+
+	pdus, err = New(replay(t), Config{Duplicate: 1}).WalkAll(testNode)
+	require.NoError(t, err)
+	require.Len(t, pdus, 12)
+	assert.Equal(t, pdus[0], pdus[1])
+
+	pdus, err = New(replay(t), Config{Reorder: 1}).WalkAll(testNode)
+	require.NoError(t, err)
+	require.Len(t, pdus, 6)
+	assert.Equal(t, testNode+".1.3.0.23.16.43.105.89.10.0.0.2", pdus[0].Name)
+}
+
+func TestScraper_Seed(t *testing.T) {
+	config := Config{Timeout: 0.2, PacketError: 0.2, Truncate: 0.3, Duplicate: 0.1, Reorder: 0.1}
+	run := func(seed int64) []interface{} {
+		s := New(replay(t), config, WithSeed(seed))
+		var outcomes []interface{}
+		for i := 0; i < 20; i++ {
+			packet, err := s.Get([]string{testDescr})
+			outcomes = append(outcomes, packet, err != nil)
+			pdus, err := s.WalkAll(testNode)
+			outcomes = append(outcomes, pdus, err != nil)
+		}
+		return outcomes
+	}
+	assert.Equal(t, run(1), run(1))
+	assert.NotEqual(t, run(1), run(2))
+
+	// how long a walk is does not shift the faults of the calls after it
+	gets := func(subtree string) []bool {
+		s := New(replay(t), config, WithSeed(1))
+		var failed []bool
+		for i := 0; i < 20; i++ {
+			_, err := s.WalkAll(subtree)
+			failed = append(failed, err != nil)
+			packet, err := s.Get([]string{testDescr})
+			failed = append(failed, err != nil || packet.Error != gosnmp.NoError)
+		}
+		return failed
+	}
+	assert.Equal(t, gets(testNode), gets(testNode+".1.3"))
+}
+
+func TestScraper_Latency(t *testing.T) {
+	s := New(replay(t), Config{Latency: Constant(20 * time.Millisecond)})
+	st := time.Now()
+	_, err := s.Get([]string{testDescr})
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, time.Since(st), 20*time.Millisecond)
+
+	// the request context cuts the delay short
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+	s = New(replay(t), Config{Latency: Constant(time.Minute)})
+	s.SetOptions(scraper.WithContext(ctx))
+	_, err = s.Get([]string{testDescr})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	r := rand.New(rand.NewSource(1))
+	for _, latency := range []Latency{Uniform(time.Millisecond, 2*time.Millisecond), Normal(time.Millisecond, time.Second), Exponential(time.Millisecond)} {
+		for i := 0; i < 100; i++ {
+			assert.GreaterOrEqual(t, latency(r), time.Duration(0))
+		}
+	}
+	assert.Less(t, Uniform(time.Millisecond, 2*time.Millisecond)(r), 2*time.Millisecond)
+}
+
+func TestScraper_GoSNMP(t *testing.T) {
+	data, err := record.LoadWalk("../testdata/agent.walk")
+	require.NoError(t, err)
+	a, err := agent.Start(agent.Config{Data: data})
+	require.NoError(t, err)
+	defer a.Close()
+
+	inner, err := scraper.NewGoSNMP(a.ClientConfig(scraper.Versionv2c))
+	require.NoError(t, err)
+	s := New(inner, Config{Timeout: 1})
+	require.NoError(t, s.Connect())
+	defer s.Close()
+
+	_, err = s.Get([]string{testDescr})
+	var timeout *scraper.TimeoutError
+	require.ErrorAs(t, err, &timeout)
+	assert.Equal(t, "127.0.0.1", timeout.Target)
+}
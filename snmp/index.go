@@ -0,0 +1,265 @@
+package snmp
+
+import (
+	"errors"
+	"fmt"
+	gosmitypes "github.com/sleepinggenius2/gosmi/types"
+	"net"
+	"snmp-test/snmp/parse"
+	"strconv"
+	"strings"
+)
+
+// IndexValue is one decoded component of a table instance index.
+type IndexValue struct {
+	// Object is the INDEX column the component belongs to.
+	Object *parse.MibObject
+	// Value is the component rendered like a column value of that type.
+	Value string
+	// OID is the part of the instance index the component was decoded from.
+	OID string
+}
+
+// DecodeIndex splits the instance index of row into the typed components of
+// its INDEX clause.
+func DecodeIndex(row *parse.MibObject, index string) ([]IndexValue, error) {
+	objs, ok := parse.IndexObjects(row)
+	if !ok {
+		return nil, fmt.Errorf("no index clause for %s", row.Name)
+	}
+	subIds, err := parseSubIds(index)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([]IndexValue, 0, len(objs))
+	for i, obj := range objs {
+		implied := row.Implied && i == len(objs)-1
+		n, value, err := decodeIndexPart(obj, subIds, implied)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode %s from index %s: %w", obj.Name, index, err)
+		}
+		if obj.Type == "InetAddress" {
+			value = indexInetAddress(obj, values, subIds[:n], implied, value)
+		}
+		values = append(values, IndexValue{Object: obj, Value: value, OID: joinSubIds(subIds[:n])})
+		subIds = subIds[n:]
+	}
+	if len(subIds) > 0 {
+		return nil, fmt.Errorf("index %s of %s has %d trailing sub-identifiers", index, row.Name, len(subIds))
+	}
+	return values, nil
+}
+
+// EncodeIndex builds the instance index of row from one human value per
+// INDEX column; it is the inverse of DecodeIndex.
+func EncodeIndex(row *parse.MibObject, values ...string) (string, error) {
+	objs, ok := parse.IndexObjects(row)
+	if !ok {
+		return "", fmt.Errorf("no index clause for %s", row.Name)
+	}
+	if len(values) != len(objs) {
+		return "", fmt.Errorf("%s needs %d index values, got %d", row.Name, len(objs), len(values))
+	}
+
+	var subIds []uint32
+	for i, obj := range objs {
+		implied := row.Implied && i == len(objs)-1
+		part, err := encodeIndexPart(obj, values[i], implied)
+		if err != nil {
+			return "", fmt.Errorf("failed to encode %s index %q: %w", obj.Name, values[i], err)
+		}
+		subIds = append(subIds, part...)
+	}
+	return joinSubIds(subIds), nil
+}
+
+// indexInetAddress renders an InetAddress index component using the
+// InetAddressType decoded before it, keeping value if there is none.
+func indexInetAddress(obj *parse.MibObject, decoded []IndexValue, subIds []uint32, implied bool, value string) string {
+	sibling, ok := siblingOfType(obj, "InetAddressType")
+	if !ok {
+		return value
+	}
+	addrType, ok := siblingEnum(sibling, nil, decoded)
+	if !ok {
+		return value
+	}
+
+	if obj.Size == 0 && !implied {
+		subIds = subIds[1:]
+	}
+	b := make([]byte, len(subIds))
+	for i, id := range subIds {
+		b[i] = byte(id)
+	}
+	if str, err := FormatInetAddress(addrType, b); err == nil {
+		return str
+	}
+	return value
+}
+
+func decodeIndexPart(obj *parse.MibObject, subIds []uint32, implied bool) (int, string, error) {
+	switch gosmitypes.BaseType(obj.SmiType) {
+	case gosmitypes.BaseTypeInteger32, gosmitypes.BaseTypeUnsigned32:
+		if len(subIds) < 1 {
+			return 0, "", errors.New("index too short")
+		}
+		return 1, strconv.FormatUint(uint64(subIds[0]), 10), nil
+	case gosmitypes.BaseTypeEnum:
+		if len(subIds) < 1 {
+			return 0, "", errors.New("index too short")
+		}
+		return 1, enumAsString(int(subIds[0]), obj.Syntax), nil
+	case gosmitypes.BaseTypeOctetString, gosmitypes.BaseTypeBits:
+		start, n, err := indexLength(obj.Size, subIds, implied)
+		if err != nil {
+			return 0, "", err
+		}
+		b := make([]byte, n)
+		for i, id := range subIds[start : start+n] {
+			if id > 255 {
+				return 0, "", fmt.Errorf("sub-identifier %d is not an octet", id)
+			}
+			b[i] = byte(id)
+		}
+		if obj.Type == "IpAddress" {
+			return start + n, net.IP(b).String(), nil
+		}
+		return start + n, octetTypeAsString(obj, b), nil
+	case gosmitypes.BaseTypeObjectIdentifier:
+		start, n, err := indexLength(0, subIds, implied)
+		if err != nil {
+			return 0, "", err
+		}
+		return start + n, joinSubIds(subIds[start : start+n]), nil
+	default:
+		return 0, "", fmt.Errorf("unsupported index type %s", gosmitypes.BaseType(obj.SmiType))
+	}
+}
+
+// indexLength returns where the value starts and how many sub-identifiers
+// it spans: fixed size, the rest for IMPLIED, or a leading length otherwise.
+func indexLength(size int, subIds []uint32, implied bool) (int, int, error) {
+	start, n := 0, size
+	switch {
+	case size > 0:
+	case implied:
+		n = len(subIds)
+	default:
+		if len(subIds) < 1 {
+			return 0, 0, errors.New("index too short")
+		}
+		start, n = 1, int(subIds[0])
+	}
+	if start+n > len(subIds) {
+		return 0, 0, errors.New("index too short")
+	}
+	return start, n, nil
+}
+
+func encodeIndexPart(obj *parse.MibObject, value string, implied bool) ([]uint32, error) {
+	switch gosmitypes.BaseType(obj.SmiType) {
+	case gosmitypes.BaseTypeInteger32, gosmitypes.BaseTypeUnsigned32:
+		u, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			return nil, err
+		}
+		return []uint32{uint32(u)}, nil
+	case gosmitypes.BaseTypeEnum:
+		i, err := enumFromString(value, obj.Syntax)
+		if err != nil {
+			return nil, err
+		}
+		return []uint32{uint32(i)}, nil
+	case gosmitypes.BaseTypeOctetString, gosmitypes.BaseTypeBits:
+		b, err := EncodeOctets(obj, value)
+		if err != nil {
+			return nil, err
+		}
+		subIds := make([]uint32, 0, len(b)+1)
+		if obj.Size == 0 && !implied {
+			subIds = append(subIds, uint32(len(b)))
+		}
+		for _, o := range b {
+			subIds = append(subIds, uint32(o))
+		}
+		return subIds, nil
+	case gosmitypes.BaseTypeObjectIdentifier:
+		oid, err := parse.ToNumeric(value)
+		if err != nil {
+			return nil, err
+		}
+		ids, err := parseSubIds(oid)
+		if err != nil {
+			return nil, err
+		}
+		if !implied {
+			ids = append([]uint32{uint32(len(ids))}, ids...)
+		}
+		return ids, nil
+	default:
+		return nil, fmt.Errorf("unsupported index type %s", gosmitypes.BaseType(obj.SmiType))
+	}
+}
+
+func parseSubIds(oid string) ([]uint32, error) {
+	oid = strings.TrimPrefix(oid, ".")
+	if oid == "" {
+		return nil, nil
+	}
+	parts := strings.Split(oid, ".")
+	ids := make([]uint32, len(parts))
+	for i, part := range parts {
+		id, err := strconv.ParseUint(part, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid sub-identifier %q in %s", part, oid)
+		}
+		ids[i] = uint32(id)
+	}
+	return ids, nil
+}
+
+// CompareIndex orders dotted indexes or OIDs by sub-identifier, the order
+// of a walk, and returns -1, 0 or +1. Parts that are not numbers compare as
+// strings.
+func CompareIndex(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		x, errX := strconv.ParseUint(as[i], 10, 32)
+		y, errY := strconv.ParseUint(bs[i], 10, 32)
+		if errX != nil || errY != nil {
+			if c := strings.Compare(as[i], bs[i]); c != 0 {
+				return c
+			}
+			continue
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	switch {
+	case len(as) < len(bs):
+		return -1
+	case len(as) > len(bs):
+		return 1
+	}
+	return 0
+}
+
+func joinSubIds(ids []uint32) string {
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = strconv.FormatUint(uint64(id), 10)
+	}
+	return strings.Join(parts, ".")
+}
+
+// isNumericIndex reports whether index is already dotted sub-identifiers.
+func isNumericIndex(index string) bool {
+	_, err := parseSubIds(index)
+	return err == nil
+}
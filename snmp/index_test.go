@@ -0,0 +1,63 @@
+package snmp
+
+import (
+	"github.com/stretchr/testify/assert"
+	"snmp-test/snmp/parse"
+	"testing"
+)
+
+func TestDecodeIndex(t *testing.T) {
+	loadTestMibs(t)
+	entry, _ := parse.FindMib("testNodeEntry")
+
+	values, err := DecodeIndex(entry, "0.23.16.43.105.88.10.0.0.1")
+	assert.NoError(t, err)
+	assert.Len(t, values, 2)
+	assert.Equal(t, "testNodeMac", values[0].Object.Name)
+	assert.Equal(t, "00:17:10:2B:69:58", values[0].Value)
+	assert.Equal(t, "10.0.0.1", values[1].Value)
+
+	index, err := EncodeIndex(entry, "00:17:10:2B:69:58", "10.0.0.1")
+	assert.NoError(t, err)
+	assert.Equal(t, "0.23.16.43.105.88.10.0.0.1", index)
+
+	name, _ := parse.FindMib("testNodeName")
+	assert.Equal(t, name.OID+".0.23.16.43.105.88.10.0.0.1", AddIndex(name.OID, "00:17:10:2B:69:58,10.0.0.1"))
+	assert.Equal(t, name.OID+".5", AddIndex(name.OID, "5"))
+	_, err = AddIndexErr(name.OID, "00:17:10:2B:69,10.0.0.1")
+	assert.ErrorContains(t, err, "invalid index 00:17:10:2B:69,10.0.0.1")
+
+	_, err = DecodeIndex(entry, "0.23.16.43.105.88.10.0.0")
+	assert.Error(t, err)
+	_, err = DecodeIndex(entry, "0.23.16.43.105.88.10.0.0.1.7")
+	assert.Error(t, err)
+}
+
+func TestRowsAsStrings_IndexColumns(t *testing.T) {
+	loadTestMibs(t)
+	entry, _ := parse.FindMib("testNodeEntry")
+
+	rows := rowsAsStrings([]Row{{
+		Index:       "0.23.16.43.105.88.10.0.0.1",
+		IndexValues: decodeRowIndex(entry, "0.23.16.43.105.88.10.0.0.1"),
+		Values:      map[string]*Value{},
+	}})
+	assert.Equal(t, "00:17:10:2B:69:58", rows[0]["testNodeMac"])
+	assert.Equal(t, "10.0.0.1", rows[0]["testNodeAddr"])
+}
+
+func TestCompareIndex(t *testing.T) {
+	for _, c := range []struct {
+		a, b string
+		want int
+	}{
+		{"1.2", "1.10", -1},
+		{"1.10.1", "1.2", 1},
+		{"1.2", "1.2.0", -1},
+		{"1.3.6", "1.3.6", 0},
+		{"4294967295", "4294967294", 1},
+		{"eth0.1", "eth1.0", -1},
+	} {
+		assert.Equal(t, c.want, CompareIndex(c.a, c.b), "%s %s", c.a, c.b)
+	}
+}
@@ -0,0 +1,360 @@
+package snmp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net/netip"
+	"snmp-test/snmp/parse"
+	"strconv"
+	"strings"
+)
+
+// InetAddressType values of INET-ADDRESS-MIB.
+const (
+	InetAddressTypeUnknown = 0
+	InetAddressTypeIPv4    = 1
+	InetAddressTypeIPv6    = 2
+	InetAddressTypeIPv4z   = 3
+	InetAddressTypeIPv6z   = 4
+	InetAddressTypeDNS     = 16
+)
+
+// Transport domains of SNMPv2-TM and TRANSPORT-ADDRESS-MIB understood by
+// FormatTAddress.
+const (
+	SnmpUDPDomain  = "1.3.6.1.6.1.1"
+	DomainUDPIPv4  = "1.3.6.1.2.1.100.1.1"
+	DomainUDPIPv6  = "1.3.6.1.2.1.100.1.2"
+	DomainUDPIPv4z = "1.3.6.1.2.1.100.1.3"
+	DomainUDPIPv6z = "1.3.6.1.2.1.100.1.4"
+	DomainTCPIPv4  = "1.3.6.1.2.1.100.1.5"
+	DomainTCPIPv6  = "1.3.6.1.2.1.100.1.6"
+	DomainTCPIPv4z = "1.3.6.1.2.1.100.1.7"
+	DomainTCPIPv6z = "1.3.6.1.2.1.100.1.8"
+	DomainUDPDNS   = "1.3.6.1.2.1.100.1.14"
+	DomainTCPDNS   = "1.3.6.1.2.1.100.1.15"
+)
+
+// domainAddressType maps a transport domain to the address form of its
+// TAddress.
+var domainAddressType = map[string]int{
+	SnmpUDPDomain:  InetAddressTypeIPv4,
+	DomainUDPIPv4:  InetAddressTypeIPv4,
+	DomainUDPIPv6:  InetAddressTypeIPv6,
+	DomainUDPIPv4z: InetAddressTypeIPv4z,
+	DomainUDPIPv6z: InetAddressTypeIPv6z,
+	DomainTCPIPv4:  InetAddressTypeIPv4,
+	DomainTCPIPv6:  InetAddressTypeIPv6,
+	DomainTCPIPv4z: InetAddressTypeIPv4z,
+	DomainTCPIPv6z: InetAddressTypeIPv6z,
+	DomainUDPDNS:   InetAddressTypeDNS,
+	DomainTCPDNS:   InetAddressTypeDNS,
+}
+
+// FormatInetAddress renders an InetAddress according to its InetAddressType.
+// IPv6 addresses are compressed as in RFC 5952, zones are appended as %N.
+func FormatInetAddress(addrType int, b []byte) (string, error) {
+	switch addrType {
+	case InetAddressTypeUnknown:
+		if len(b) == 0 {
+			return "", nil
+		}
+		return formatUnknownOctets(b), nil
+	case InetAddressTypeIPv4:
+		if len(b) != 4 {
+			return "", fmt.Errorf("invalid ipv4 address: want 4 octets, got %d", len(b))
+		}
+		return netip.AddrFrom4([4]byte(b)).String(), nil
+	case InetAddressTypeIPv6:
+		if len(b) != 16 {
+			return "", fmt.Errorf("invalid ipv6 address: want 16 octets, got %d", len(b))
+		}
+		return netip.AddrFrom16([16]byte(b)).String(), nil
+	case InetAddressTypeIPv4z:
+		if len(b) != 8 {
+			return "", fmt.Errorf("invalid ipv4z address: want 8 octets, got %d", len(b))
+		}
+		return fmt.Sprintf("%s%%%d", netip.AddrFrom4([4]byte(b[:4])), binary.BigEndian.Uint32(b[4:])), nil
+	case InetAddressTypeIPv6z:
+		if len(b) != 20 {
+			return "", fmt.Errorf("invalid ipv6z address: want 20 octets, got %d", len(b))
+		}
+		return fmt.Sprintf("%s%%%d", netip.AddrFrom16([16]byte(b[:16])), binary.BigEndian.Uint32(b[16:])), nil
+	case InetAddressTypeDNS:
+		return string(b), nil
+	default:
+		return "", fmt.Errorf("unsupported InetAddressType %d", addrType)
+	}
+}
+
+// inetAddressTypeOf returns the InetAddressType implied by an address TC, or
+// one guessed from the length of b for the untyped InetAddress.
+func inetAddressTypeOf(typ string, b []byte) int {
+	switch typ {
+	case "InetAddressIPv4":
+		return InetAddressTypeIPv4
+	case "InetAddressIPv6", "Ipv6Address":
+		return InetAddressTypeIPv6
+	case "InetAddressIPv4z":
+		return InetAddressTypeIPv4z
+	case "InetAddressIPv6z":
+		return InetAddressTypeIPv6z
+	case "InetAddressDNS":
+		return InetAddressTypeDNS
+	}
+
+	switch len(b) {
+	case 4:
+		return InetAddressTypeIPv4
+	case 16:
+		return InetAddressTypeIPv6
+	case 8:
+		return InetAddressTypeIPv4z
+	case 20:
+		return InetAddressTypeIPv6z
+	}
+	if len(b) > 0 && isPrintable(b) {
+		return InetAddressTypeDNS
+	}
+	return InetAddressTypeUnknown
+}
+
+// formatInetAddressGuess renders an address whose InetAddressType is not
+// known, falling back to hex for anything that does not decode.
+func formatInetAddressGuess(typ string, b []byte) string {
+	if str, err := FormatInetAddress(inetAddressTypeOf(typ, b), b); err == nil {
+		return str
+	}
+	return formatUnknownOctets(b)
+}
+
+// parseInetAddress is the inverse of FormatInetAddress. Anything that is
+// not an IP address is taken as a DNS name unless typ requires an IP.
+func parseInetAddress(typ, value string) ([]byte, error) {
+	if value == "" {
+		return []byte{}, nil
+	}
+
+	host, zone, hasZone := strings.Cut(value, "%")
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		switch typ {
+		case "InetAddress", "InetAddressDNS":
+			return []byte(value), nil
+		}
+		return nil, fmt.Errorf("invalid ip address %q", value)
+	}
+	b := addr.AsSlice()
+	switch typ {
+	case "InetAddressIPv4", "InetAddressIPv4z":
+		if !addr.Is4() {
+			return nil, fmt.Errorf("invalid ipv4 address %q", value)
+		}
+	case "InetAddressIPv6", "InetAddressIPv6z", "Ipv6Address":
+		a16 := addr.As16()
+		b = a16[:]
+	}
+
+	if !hasZone {
+		return b, nil
+	}
+	zoneIndex, err := strconv.ParseUint(zone, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid zone index in %q", value)
+	}
+	return binary.BigEndian.AppendUint32(b, uint32(zoneIndex)), nil
+}
+
+// FormatTAddress renders a TAddress of the given transport domain (a
+// numeric OID). snmpUDPDomain addresses keep the "a.b.c.d/port" form of
+// SnmpUDPAddress, the TRANSPORT-ADDRESS-MIB domains use "host:port" with
+// IPv6 hosts in brackets. An empty domain guesses the form from the length.
+func FormatTAddress(domain string, b []byte) (string, error) {
+	domain = strings.TrimPrefix(domain, ".")
+	addrType, ok := domainAddressType[domain]
+	if !ok {
+		if domain != "" {
+			return "", fmt.Errorf("unsupported transport domain %s", domain)
+		}
+		addrType = tAddressTypeOf(b)
+		domain = SnmpUDPDomain
+	}
+
+	if addrType == InetAddressTypeDNS {
+		return string(b), nil
+	}
+	if addrType == InetAddressTypeUnknown || len(b) < 2 {
+		return "", fmt.Errorf("invalid transport address: %d octets", len(b))
+	}
+	host, err := FormatInetAddress(addrType, b[:len(b)-2])
+	if err != nil {
+		return "", err
+	}
+	port := binary.BigEndian.Uint16(b[len(b)-2:])
+
+	switch {
+	case domain == SnmpUDPDomain && addrType == InetAddressTypeIPv4:
+		return fmt.Sprintf("%s/%d", host, port), nil
+	case addrType == InetAddressTypeIPv6 || addrType == InetAddressTypeIPv6z:
+		return fmt.Sprintf("[%s]:%d", host, port), nil
+	default:
+		return fmt.Sprintf("%s:%d", host, port), nil
+	}
+}
+
+func tAddressTypeOf(b []byte) int {
+	switch len(b) {
+	case 6:
+		return InetAddressTypeIPv4
+	case 18:
+		return InetAddressTypeIPv6
+	case 10:
+		return InetAddressTypeIPv4z
+	case 22:
+		return InetAddressTypeIPv6z
+	}
+	if isPrintable(b) {
+		return InetAddressTypeDNS
+	}
+	return InetAddressTypeUnknown
+}
+
+// formatTAddressGuess renders a TAddress whose domain is not known.
+func formatTAddressGuess(b []byte) string {
+	if str, err := FormatTAddress("", b); err == nil {
+		return str
+	}
+	return formatUnknownOctets(b)
+}
+
+// parseTAddress is the inverse of FormatTAddress. It accepts "ip/port",
+// "ip:port", "[ipv6]:port", zoned hosts and, failing those, a DNS name with
+// port which is kept as text.
+func parseTAddress(value string) ([]byte, error) {
+	host, port, err := splitTAddress(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid transport address %q: %w", value, err)
+	}
+
+	if _, err := netip.ParseAddr(strings.SplitN(host, "%", 2)[0]); err != nil {
+		return []byte(value), nil
+	}
+	b, err := parseInetAddress("InetAddressIPv4z", host)
+	if err != nil {
+		if b, err = parseInetAddress("InetAddressIPv6z", host); err != nil {
+			return nil, fmt.Errorf("invalid transport address %q: %w", value, err)
+		}
+	}
+	return binary.BigEndian.AppendUint16(b, port), nil
+}
+
+func splitTAddress(value string) (string, uint16, error) {
+	var host, port string
+	if strings.HasPrefix(value, "[") {
+		end := strings.Index(value, "]:")
+		if end < 0 {
+			return "", 0, fmt.Errorf("missing port")
+		}
+		host, port = value[1:end], value[end+2:]
+	} else {
+		i := strings.LastIndexAny(value, "/:")
+		if i < 0 {
+			return "", 0, fmt.Errorf("missing port")
+		}
+		host, port = value[:i], value[i+1:]
+	}
+
+	p, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid port %q", port)
+	}
+	return host, uint16(p), nil
+}
+
+// siblingOfType finds the column of type typ that qualifies obj within its
+// row, such as the InetAddressType of an InetAddress. If the row has several
+// the one sharing the longest name prefix with obj wins.
+func siblingOfType(obj *parse.MibObject, typ string) (*parse.MibObject, bool) {
+	row, ok := parse.RowOf(obj)
+	if !ok {
+		return nil, false
+	}
+
+	var best *parse.MibObject
+	bestLen := -1
+	for _, col := range parse.Children(row.OID) {
+		if col.Type != typ {
+			continue
+		}
+		if n := commonPrefixLen(col.Name, obj.Name); n > bestLen {
+			best, bestLen = col, n
+		}
+	}
+	return best, best != nil
+}
+
+func commonPrefixLen(a, b string) int {
+	n := 0
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+	return n
+}
+
+// resolveAddresses re-renders the InetAddress and TAddress columns of row
+// using the InetAddressType and TDomain columns beside them, read either
+// from the row's values or from its index.
+func resolveAddresses(row *Row) {
+	byObject := make(map[*parse.MibObject]*Value, len(row.Values))
+	for _, v := range row.Values {
+		if v.Object != nil {
+			byObject[v.Object] = v
+		}
+	}
+
+	for _, v := range row.Values {
+		if v.Object == nil || v.Bytes == nil {
+			continue
+		}
+		switch v.Object.Type {
+		case "InetAddress":
+			sibling, ok := siblingOfType(v.Object, "InetAddressType")
+			if !ok {
+				continue
+			}
+			addrType, ok := siblingEnum(sibling, byObject, row.IndexValues)
+			if !ok {
+				continue
+			}
+			if str, err := FormatInetAddress(addrType, v.Bytes); err == nil {
+				v.String = str
+			}
+		case "TAddress":
+			sibling, ok := siblingOfType(v.Object, "TDomain")
+			if !ok {
+				continue
+			}
+			domain, ok := byObject[sibling]
+			if !ok {
+				continue
+			}
+			oid, _ := domain.Raw.(string)
+			if str, err := FormatTAddress(oid, v.Bytes); err == nil {
+				v.String = str
+			}
+		}
+	}
+}
+
+func siblingEnum(sibling *parse.MibObject, byObject map[*parse.MibObject]*Value, index []IndexValue) (int, bool) {
+	if v, ok := byObject[sibling]; ok && v.Number != nil {
+		return v.EnumNumber, true
+	}
+	for _, iv := range index {
+		if iv.Object == sibling {
+			n, err := enumFromString(iv.Value, sibling.Syntax)
+			return n, err == nil
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,115 @@
+package snmp
+
+import (
+	"github.com/gosnmp/gosnmp"
+	"github.com/stretchr/testify/assert"
+	"snmp-test/snmp/parse"
+	"testing"
+)
+
+var ipv6LinkLocal = []byte{0xfe, 0x80, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1}
+
+func TestFormatInetAddress(t *testing.T) {
+	for _, tc := range []struct {
+		typ  int
+		in   []byte
+		want string
+	}{
+		{InetAddressTypeIPv4, []byte{10, 0, 0, 1}, "10.0.0.1"},
+		{InetAddressTypeIPv6, ipv6LinkLocal, "fe80::1"},
+		{InetAddressTypeIPv6, []byte{0x20, 0x01, 0x0d, 0xb8, 0, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 1}, "2001:db8::1:0:0:1"},
+		{InetAddressTypeIPv4z, []byte{10, 0, 0, 1, 0, 0, 0, 5}, "10.0.0.1%5"},
+		{InetAddressTypeIPv6z, append(append([]byte{}, ipv6LinkLocal...), 0, 0, 0, 3), "fe80::1%3"},
+		{InetAddressTypeDNS, []byte("cmts.example.com"), "cmts.example.com"},
+		{InetAddressTypeUnknown, nil, ""},
+	} {
+		got, err := FormatInetAddress(tc.typ, tc.in)
+		assert.NoError(t, err, tc.want)
+		assert.Equal(t, tc.want, got)
+
+		b, err := parseInetAddress("InetAddress", got)
+		assert.NoError(t, err, tc.want)
+		assert.Equal(t, len(tc.in), len(b), tc.want)
+	}
+
+	_, err := FormatInetAddress(InetAddressTypeIPv6, []byte{1, 2, 3, 4, 5, 6})
+	assert.Error(t, err)
+	_, err = FormatInetAddress(InetAddressTypeIPv4, ipv6LinkLocal)
+	assert.Error(t, err)
+
+	// without a type the length decides, odd lengths are shown as hex
+	assert.Equal(t, "fe80::1", formatInetAddressGuess("InetAddress", ipv6LinkLocal))
+	assert.Equal(t, "01 02 03 04 05 06", formatInetAddressGuess("InetAddress", []byte{1, 2, 3, 4, 5, 6}))
+}
+
+func TestFormatTAddress(t *testing.T) {
+	v4 := []byte{10, 0, 0, 1, 0, 161}
+	v6 := append(append([]byte{}, ipv6LinkLocal...), 0, 162)
+
+	for _, tc := range []struct {
+		domain string
+		in     []byte
+		want   string
+	}{
+		{SnmpUDPDomain, v4, "10.0.0.1/161"},
+		{"." + DomainUDPIPv4, v4, "10.0.0.1:161"},
+		{DomainUDPIPv6, v6, "[fe80::1]:162"},
+		{DomainUDPDNS, []byte("cmts.example.com:161"), "cmts.example.com:161"},
+		{"", v4, "10.0.0.1/161"},
+		{"", v6, "[fe80::1]:162"},
+	} {
+		got, err := FormatTAddress(tc.domain, tc.in)
+		assert.NoError(t, err, tc.want)
+		assert.Equal(t, tc.want, got)
+
+		b, err := parseTAddress(got)
+		assert.NoError(t, err, tc.want)
+		assert.Equal(t, tc.in, b, tc.want)
+	}
+
+	_, err := FormatTAddress(DomainUDPIPv6, v4)
+	assert.Error(t, err)
+	assert.Equal(t, "01", formatTAddressGuess([]byte{1}), "short input does not panic")
+}
+
+func TestResolveAddresses(t *testing.T) {
+	loadTestMibs(t)
+	entry, _ := parse.FindMib("testPeerEntry")
+	remoteType, _ := parse.FindMib("testPeerRemoteType")
+	remote, _ := parse.FindMib("testPeerRemote")
+	tdomain, _ := parse.FindMib("testPeerTDomain")
+	taddress, _ := parse.FindMib("testPeerTAddress")
+
+	// INDEX { ipv6(2), 16 octets of fe80::1 }
+	index := "2.16.254.128.0.0.0.0.0.0.0.0.0.0.0.0.0.1"
+	indexValues, err := DecodeIndex(entry, index)
+	assert.NoError(t, err)
+	assert.Equal(t, "ipv6", indexValues[0].Value)
+	assert.Equal(t, "fe80::1", indexValues[1].Value)
+
+	encoded, err := EncodeIndex(entry, "ipv6", "fe80::1")
+	assert.NoError(t, err)
+	assert.Equal(t, index, encoded)
+
+	// a 4 octet dns name would be taken for an ipv4 address without its type
+	row := Row{
+		Index:       index,
+		IndexValues: indexValues,
+		Values: map[string]*Value{
+			"testPeerRemoteType": newValue(remoteType, index, &gosnmp.SnmpPDU{Type: gosnmp.Integer, Value: InetAddressTypeDNS}),
+			"testPeerRemote":     newValue(remote, index, &gosnmp.SnmpPDU{Type: gosnmp.OctetString, Value: []byte("cmts")}),
+			"testPeerTDomain":    newValue(tdomain, index, &gosnmp.SnmpPDU{Type: gosnmp.ObjectIdentifier, Value: "." + DomainUDPIPv4}),
+			"testPeerTAddress":   newValue(taddress, index, &gosnmp.SnmpPDU{Type: gosnmp.OctetString, Value: []byte{10, 0, 0, 1, 0, 161}}),
+		},
+	}
+	assert.Equal(t, "99.109.116.115", row.Values["testPeerRemote"].String)
+	assert.Equal(t, "10.0.0.1/161", row.Values["testPeerTAddress"].String)
+
+	resolveAddresses(&row)
+	assert.Equal(t, "cmts", row.Values["testPeerRemote"].String)
+	assert.Equal(t, "10.0.0.1:161", row.Values["testPeerTAddress"].String)
+
+	sibling, ok := siblingOfType(remote, "InetAddressType")
+	assert.True(t, ok)
+	assert.Equal(t, "testPeerRemoteType", sibling.Name)
+}
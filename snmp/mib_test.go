@@ -0,0 +1,20 @@
+package snmp
+
+import (
+	"snmp-test/snmp/parse"
+	"sync"
+	"testing"
+)
+
+var loadTestMibsOnce sync.Once
+
+// loadTestMibs loads the stub MIBs in testdata/mibs into the parse tree.
+func loadTestMibs(t *testing.T) {
+	t.Helper()
+	loadTestMibsOnce.Do(func() {
+		parse.LoadMibFromDir("testdata/mibs")
+	})
+	if _, ok := parse.FindMib("SNMP-TEST-MIB::testNodeEntry"); !ok {
+		t.Fatal("failed to load testdata mibs")
+	}
+}
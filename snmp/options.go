@@ -0,0 +1,95 @@
+package snmp
+
+import (
+	"context"
+	"github.com/gosnmp/gosnmp"
+	"snmp-test/snmp/scraper"
+	"time"
+)
+
+// ScraperFactory builds the backend of a client. The client sets the
+// request context, connects it and closes it when done.
+type ScraperFactory func(config *scraper.ClientConfig) (scraper.SNMPScraper, error)
+
+func newGoSNMP(config *scraper.ClientConfig) (scraper.SNMPScraper, error) {
+	return scraper.NewGoSNMP(config)
+}
+
+// Option configures a client from NewClient or NewSessionClient.
+type Option func(s *snmp)
+
+// WithScraperFactory replaces scraper.NewGoSNMP, e.g. to serve a client from
+// a recording or to wrap the network backend in a cache or rate limiter.
+func WithScraperFactory(factory ScraperFactory) Option {
+	return func(s *snmp) {
+		s.newScraper = factory
+	}
+}
+
+// WithScraperOptions applies fns to every scraper the client builds, after
+// the ClientConfig is applied.
+func WithScraperOptions(fns ...func(snmp *gosnmp.GoSNMP)) Option {
+	return func(s *snmp) {
+		s.scraperOptions = append(s.scraperOptions, fns...)
+	}
+}
+
+type callOptionsKey struct{}
+
+// WithCallOptions returns a context that applies fns to the scraper for the
+// calls made with it only, e.g. a longer timeout for one slow table. On a
+// session client the previous settings are restored after the call.
+func WithCallOptions(ctx context.Context, fns ...func(snmp *gosnmp.GoSNMP)) context.Context {
+	// full slice expression: never append into the parent's backing array
+	prev := callOptions(ctx)
+	fns = append(prev[:len(prev):len(prev)], fns...)
+	return context.WithValue(ctx, callOptionsKey{}, fns)
+}
+
+func callOptions(ctx context.Context) []func(snmp *gosnmp.GoSNMP) {
+	fns, _ := ctx.Value(callOptionsKey{}).([]func(snmp *gosnmp.GoSNMP))
+	return fns
+}
+
+// settings are the fields of gosnmp.GoSNMP that make sense to change per
+// call.
+type settings struct {
+	timeout            time.Duration
+	retries            int
+	exponentialTimeout bool
+	maxOids            int
+	maxRepetitions     uint32
+	nonRepeaters       int
+	community          string
+	contextName        string
+	contextEngineID    string
+}
+
+// saveSettings returns an option restoring the current settings of client.
+func saveSettings(client scraper.SNMPScraper) func(snmp *gosnmp.GoSNMP) {
+	var saved settings
+	client.SetOptions(func(snmp *gosnmp.GoSNMP) {
+		saved = settings{
+			timeout:            snmp.Timeout,
+			retries:            snmp.Retries,
+			exponentialTimeout: snmp.ExponentialTimeout,
+			maxOids:            snmp.MaxOids,
+			maxRepetitions:     snmp.MaxRepetitions,
+			nonRepeaters:       snmp.NonRepeaters,
+			community:          snmp.Community,
+			contextName:        snmp.ContextName,
+			contextEngineID:    snmp.ContextEngineID,
+		}
+	})
+	return func(snmp *gosnmp.GoSNMP) {
+		snmp.Timeout = saved.timeout
+		snmp.Retries = saved.retries
+		snmp.ExponentialTimeout = saved.exponentialTimeout
+		snmp.MaxOids = saved.maxOids
+		snmp.MaxRepetitions = saved.maxRepetitions
+		snmp.NonRepeaters = saved.nonRepeaters
+		snmp.Community = saved.community
+		snmp.ContextName = saved.contextName
+		snmp.ContextEngineID = saved.contextEngineID
+	}
+}
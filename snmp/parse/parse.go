@@ -3,14 +3,19 @@ package parse
 import (
 	"fmt"
 	"github.com/sleepinggenius2/gosmi"
+	"github.com/sleepinggenius2/gosmi/models"
 	"github.com/sleepinggenius2/gosmi/smi"
 	"github.com/sleepinggenius2/gosmi/types"
+	"log/slog"
 	"os"
 	"snmp-test/set"
+	"sort"
+	"strconv"
 	"strings"
 )
 
 type MibObject struct {
+	Module    string
 	Name      string
 	OID       string
 	ParentOID string
@@ -18,13 +23,147 @@ type MibObject struct {
 	SmiType   int
 	Syntax    map[int]string
 	Access    string
+	// Kind is the gosmi node kind: Table, Row, Column, Scalar, ...
+	Kind string
+	// Create is set for read-create columns.
+	Create bool
+	// HasDefault is set when the object has a DEFVAL clause.
+	HasDefault bool
+	// Size is the fixed length of an OCTET STRING type, 0 if it varies.
+	Size int
+	// DisplayHint is the DISPLAY-HINT of the type, inherited through the
+	// textual convention chain.
+	DisplayHint string
+	// AppType is the SMI application type at the root of the textual
+	// convention chain (Counter32, TimeTicks, IpAddress, ...), empty for
+	// types built on INTEGER, OCTET STRING or OBJECT IDENTIFIER.
+	AppType string
+
+	// Index lists the OIDs of the INDEX columns of a Row, following
+	// AUGMENTS to the base row. Implied is set if the last one is IMPLIED.
+	Index    []string
+	Implied  bool
+	Augments string
 }
 
+// RowOf returns the conceptual row a column belongs to.
+func RowOf(column *MibObject) (*MibObject, bool) {
+	row, ok := tree[column.ParentOID]
+	if !ok || row.Kind != "Row" {
+		return nil, false
+	}
+	return row, true
+}
+
+// IndexObjects returns the INDEX columns of row in clause order.
+func IndexObjects(row *MibObject) ([]*MibObject, bool) {
+	objs := make([]*MibObject, 0, len(row.Index))
+	for _, oid := range row.Index {
+		mib, ok := tree[oid]
+		if !ok {
+			return nil, false
+		}
+		objs = append(objs, mib)
+	}
+	return objs, len(objs) > 0
+}
+
+// tree maps numeric OIDs and MODULE::name to objects.
 var tree = make(map[string]*MibObject, 1024*10)
 
+// names maps bare names to every object defining them, in load order.
+var names = make(map[string][]*MibObject, 1024*10)
+
+// children maps a parent OID to its child objects.
+var children = make(map[string][]*MibObject, 1024)
+
+// modulePreference decides between objects sharing a bare name.
+var modulePreference []string
+
+// SetModulePreference sets the modules whose definitions win when a bare
+// name is defined by several loaded MIBs, most preferred first.
+func SetModulePreference(modules ...string) {
+	modulePreference = modules
+}
+
+// AmbiguousError is the failed lookup of a bare name that several loaded
+// modules define with different OIDs, none of them preferred.
+type AmbiguousError struct {
+	Name    string
+	Modules []string
+}
+
+func (e *AmbiguousError) Error() string {
+	return fmt.Sprintf("ambiguous mib name %s, defined by %s; qualify it as MODULE::%s",
+		e.Name, strings.Join(e.Modules, ", "), e.Name)
+}
+
+// FindMib looks up a numeric OID, a qualified MODULE::name, or a bare name.
+// An ambiguous bare name resolves through the module preference; if no
+// preferred module defines it, the lookup fails rather than guess.
 func FindMib(name string) (*MibObject, bool) {
-	v, ok := tree[name]
-	return v, ok
+	mib, err := Lookup(name)
+	return mib, err == nil
+}
+
+// Lookup is FindMib returning why a name was not found; an ambiguous bare
+// name fails with an *AmbiguousError naming the candidate modules.
+func Lookup(name string) (*MibObject, error) {
+	if v, ok := tree[name]; ok {
+		return v, nil
+	}
+
+	candidates := names[name]
+	switch {
+	case len(candidates) == 0:
+		return nil, fmt.Errorf("unknown mib name %s", name)
+	case len(candidates) == 1 || sameOID(candidates):
+		return candidates[0], nil
+	}
+
+	for _, module := range modulePreference {
+		for _, mib := range candidates {
+			if mib.Module == module {
+				return mib, nil
+			}
+		}
+	}
+	modules := make([]string, 0, len(candidates))
+	for _, mib := range candidates {
+		modules = append(modules, mib.Module)
+	}
+	return nil, &AmbiguousError{Name: name, Modules: modules}
+}
+
+// FindMibs returns every loaded object with the given bare name.
+func FindMibs(name string) []*MibObject {
+	return names[name]
+}
+
+// Collisions returns the bare names defined with different OIDs by more than
+// one loaded module.
+func Collisions() map[string][]*MibObject {
+	ret := make(map[string][]*MibObject)
+	for name, candidates := range names {
+		if len(candidates) > 1 && !sameOID(candidates) {
+			ret[name] = candidates
+		}
+	}
+	return ret
+}
+
+func sameOID(objs []*MibObject) bool {
+	for _, mib := range objs[1:] {
+		if mib.OID != objs[0].OID {
+			return false
+		}
+	}
+	return true
+}
+
+// Children returns the objects directly below oid, ordered by sub-identifier.
+func Children(oid string) []*MibObject {
+	return children[oid]
 }
 
 func load(dir string) set.Set[string] {
@@ -61,7 +200,13 @@ func load(dir string) set.Set[string] {
 }
 
 func buildMibObject(modules set.Set[string]) {
+	sortedModules := make([]string, 0, len(modules))
 	for module := range modules {
+		sortedModules = append(sortedModules, module)
+	}
+	sort.Strings(sortedModules)
+
+	for _, module := range sortedModules {
 		m, err := gosmi.GetModule(module)
 		if err != nil {
 			fmt.Printf("failed to get module (%s) information, err: %v\n", module, err)
@@ -73,14 +218,33 @@ func buildMibObject(modules set.Set[string]) {
 				continue
 			}
 
+			raw := node.GetRaw()
 			mib := &MibObject{
-				Name:   node.Name,
-				OID:    node.Oid.String(),
-				Access: node.Access.String(),
+				Module:     module,
+				Name:       node.Name,
+				OID:        node.Oid.String(),
+				Access:     node.Access.String(),
+				Kind:       node.Kind.String(),
+				Create:     raw.Create,
+				HasDefault: raw.Value.BaseType != types.BaseTypeUnknown,
+			}
+			if node.Kind == types.NodeRow {
+				for _, index := range node.GetIndex() {
+					mib.Index = append(mib.Index, index.Oid.String())
+				}
+				mib.Implied = node.GetImplied()
+				if augment := node.GetAugment(); augment.Name != "" {
+					mib.Augments = augment.Oid.String()
+					// the index, IMPLIED included, is the base row's
+					mib.Implied = augment.GetImplied()
+				}
 			}
 			if node.Type != nil {
 				mib.Type = node.Type.Name
 				mib.SmiType = int(node.Type.BaseType)
+				mib.Size = fixedSize(node.Type)
+				mib.DisplayHint = displayHint(node.SmiType)
+				mib.AppType = appType(node.SmiType)
 				nodeEnum := node.Type.Enum
 				switch node.Type.BaseType {
 				case types.BaseTypeEnum, types.BaseTypeBits:
@@ -92,14 +256,88 @@ func buildMibObject(modules set.Set[string]) {
 					}
 				}
 			}
-			if parent := smi.GetParentNode(node.GetRaw()); parent != nil {
+			if parent := smi.GetParentNode(raw); parent != nil {
 				mib.ParentOID = parent.Oid.String()
 			}
 
-			tree[mib.OID] = mib
-			tree[mib.Name] = mib
+			register(mib)
+		}
+	}
+
+	// see Collisions for the full list
+	for name, candidates := range Collisions() {
+		defs := make([]string, 0, len(candidates))
+		for _, mib := range candidates {
+			defs = append(defs, fmt.Sprintf("%s::%s(%s)", mib.Module, name, mib.OID))
+		}
+		slog.Warn("Mib name collision", "name", name, "definitions", defs)
+	}
+
+	for _, objs := range children {
+		sort.Slice(objs, func(i, j int) bool {
+			return lastSubId(objs[i].OID) < lastSubId(objs[j].OID)
+		})
+	}
+}
+
+// register indexes mib by OID, qualified name and bare name. The first
+// object loaded for an OID keeps it.
+func register(mib *MibObject) {
+	if _, ok := tree[mib.OID]; !ok {
+		if mib.ParentOID != "" {
+			children[mib.ParentOID] = append(children[mib.ParentOID], mib)
 		}
+		tree[mib.OID] = mib
 	}
+	tree[mib.Module+"::"+mib.Name] = mib
+	names[mib.Name] = append(names[mib.Name], mib)
+}
+
+// displayHint walks from typ up its parent types until one has a hint.
+func displayHint(typ *gosmi.SmiType) string {
+	if typ == nil {
+		return ""
+	}
+	for raw := typ.GetRaw(); raw != nil; raw = smi.GetParentType(raw) {
+		if raw.Format != "" {
+			return raw.Format
+		}
+	}
+	return ""
+}
+
+// appType walks the textual convention chain of typ up to an application
+// type of SNMPv2-SMI or RFC1155-SMI.
+func appType(typ *gosmi.SmiType) string {
+	if typ == nil {
+		return ""
+	}
+	for raw := typ.GetRaw(); raw != nil; raw = smi.GetParentType(raw) {
+		switch name := string(raw.Name); name {
+		case "Integer32", "Unsigned32", "Counter32", "Counter64", "Gauge32", "TimeTicks",
+			"IpAddress", "Opaque", "Counter", "Gauge", "NetworkAddress":
+			return name
+		}
+	}
+	return ""
+}
+
+func fixedSize(typ *models.Type) int {
+	if typ.BaseType != types.BaseTypeOctetString {
+		return 0
+	}
+	if typ.Name == "IpAddress" {
+		return 4
+	}
+	if len(typ.Ranges) == 1 && typ.Ranges[0].MinValue == typ.Ranges[0].MaxValue {
+		return int(typ.Ranges[0].MinValue)
+	}
+	return 0
+}
+
+func lastSubId(oid string) int {
+	id, _ := strconv.Atoi(oid[strings.LastIndex(oid, ".")+1:])
+	return id
 }
 
 func LoadMibFromDir(dir string) {
@@ -0,0 +1,103 @@
+package parse
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"maps"
+	"testing"
+)
+
+// isolate lets a test register objects and set a module preference without
+// leaking them into the tests that run after it.
+func isolate(t *testing.T) {
+	t.Helper()
+	savedTree, savedNames, savedChildren, savedPreference := tree, names, children, modulePreference
+	tree, names, children = maps.Clone(tree), maps.Clone(names), maps.Clone(children)
+	t.Cleanup(func() {
+		tree, names, children, modulePreference = savedTree, savedNames, savedChildren, savedPreference
+	})
+}
+
+func TestFindMib_Collision(t *testing.T) {
+	isolate(t)
+	register(&MibObject{Module: "IF-MIB", Name: "ifDescr", OID: "1.3.6.1.2.1.2.2.1.2", ParentOID: "1.3.6.1.2.1.2.2.1"})
+	register(&MibObject{Module: "VENDOR-MIB", Name: "ifDescr", OID: "1.3.6.1.4.1.20858.1.2", ParentOID: "1.3.6.1.4.1.20858.1"})
+	register(&MibObject{Module: "SNMPv2-MIB", Name: "sysDescr", OID: "1.3.6.1.2.1.1.1", ParentOID: "1.3.6.1.2.1.1"})
+
+	mib, ok := FindMib("sysDescr")
+	assert.True(t, ok)
+	assert.Equal(t, "1.3.6.1.2.1.1.1", mib.OID)
+
+	_, ok = FindMib("ifDescr")
+	assert.False(t, ok, "ambiguous name must not resolve without preference")
+	assert.Len(t, FindMibs("ifDescr"), 2)
+	assert.Contains(t, Collisions(), "ifDescr")
+
+	// the failure names the candidates
+	_, err := Lookup("ifDescr")
+	var ambiguous *AmbiguousError
+	require.ErrorAs(t, err, &ambiguous)
+	assert.Equal(t, []string{"IF-MIB", "VENDOR-MIB"}, ambiguous.Modules)
+	_, _, err = Resolve("ifDescr.5")
+	assert.ErrorContains(t, err, "ambiguous mib name ifDescr, defined by IF-MIB, VENDOR-MIB")
+
+	mib, ok = FindMib("VENDOR-MIB::ifDescr")
+	assert.True(t, ok)
+	assert.Equal(t, "1.3.6.1.4.1.20858.1.2", mib.OID)
+
+	mib, ok = FindMib("1.3.6.1.2.1.2.2.1.2")
+	assert.True(t, ok)
+	assert.Equal(t, "IF-MIB", mib.Module)
+
+	SetModulePreference("IF-MIB")
+	mib, ok = FindMib("ifDescr")
+	assert.True(t, ok)
+	assert.Equal(t, "IF-MIB", mib.Module)
+}
+
+func TestResolve(t *testing.T) {
+	isolate(t)
+	register(&MibObject{Module: "SNMPv2-SMI", Name: "org", OID: "1.3", ParentOID: "1"})
+	register(&MibObject{Module: "SNMPv2-SMI", Name: "dod", OID: "1.3.6", ParentOID: "1.3"})
+	register(&MibObject{Module: "SNMPv2-SMI", Name: "internet", OID: "1.3.6.1", ParentOID: "1.3.6"})
+	register(&MibObject{Module: "IF-MIB", Name: "ifName", OID: "1.3.6.1.2.1.31.1.1.1.1", ParentOID: "1.3.6.1.2.1.31.1.1.1"})
+
+	for _, tc := range []struct {
+		in, name, index string
+	}{
+		{"ifName.1000073", "ifName", "1000073"},
+		{"IF-MIB::ifName.5", "ifName", "5"},
+		{".1.3.6.1.2.1.31.1.1.1.1.5", "ifName", "5"},
+		{"1.3.6.1.2.1.31.1.1.1.1", "ifName", ""},
+		{"iso.org.dod.internet.2", "internet", "2"},
+	} {
+		mib, index, err := Resolve(tc.in)
+		assert.NoError(t, err, tc.in)
+		assert.Equal(t, tc.name, mib.Name, tc.in)
+		assert.Equal(t, tc.index, index, tc.in)
+	}
+
+	_, _, err := Resolve("noSuchName.0")
+	assert.Error(t, err)
+	_, _, err = Resolve("iso.org.bogus")
+	assert.Error(t, err)
+
+	assert.Equal(t, "ifName.7", Render(".1.3.6.1.2.1.31.1.1.1.1.7"))
+	assert.Equal(t, "0.0", Render("0.0"))
+}
+
+func TestLoadMibFromDir_Augments(t *testing.T) {
+	isolate(t)
+	LoadMibFromDir("../testdata/mibs")
+
+	base, ok := FindMib("testNameEntry")
+	require.True(t, ok)
+	assert.True(t, base.Implied)
+
+	// an augmenting row is indexed like its base row
+	ext, ok := FindMib("testNameExtEntry")
+	require.True(t, ok)
+	assert.Equal(t, base.OID, ext.Augments)
+	assert.Equal(t, base.Index, ext.Index)
+	assert.True(t, ext.Implied)
+}
@@ -0,0 +1,124 @@
+package parse
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// wellKnownRoots are the top arcs gosmi does not expose as module nodes.
+var wellKnownRoots = map[string]string{
+	"ccitt":           "0",
+	"iso":             "1",
+	"joint-iso-ccitt": "2",
+}
+
+// Resolve parses an OID the way operators type it and returns the closest
+// MIB object plus the instance index below it. Accepted forms include
+// "sysDescr.0", "ifDescr.1000073", "IF-MIB::ifDescr.5",
+// ".1.3.6.1.2.1.2.2.1.2.5" and "iso.org.dod.internet.mgmt.mib-2.system.sysDescr.0".
+func Resolve(s string) (*MibObject, string, error) {
+	if mib, ok := FindMib(strings.TrimPrefix(s, ".")); ok {
+		return mib, "", nil
+	}
+
+	oid, err := ToNumeric(s)
+	if err != nil {
+		return nil, "", err
+	}
+	mib, index, ok := LongestMatch(oid)
+	if !ok {
+		return nil, "", fmt.Errorf("no mib object for %s", s)
+	}
+	return mib, index, nil
+}
+
+// ToNumeric converts a symbolic or mixed OID to dotted numeric form without
+// a leading dot.
+func ToNumeric(s string) (string, error) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), ".")
+	if s == "" {
+		return "", fmt.Errorf("empty oid")
+	}
+
+	// the module part may contain dots in theory; split it off first
+	prefix := ""
+	if i := strings.Index(s, "::"); i >= 0 {
+		prefix, s = s[:i+2], s[i+2:]
+	}
+
+	parts := strings.Split(s, ".")
+	numeric := make([]string, 0, len(parts))
+	for i, part := range parts {
+		if isNumber(part) {
+			numeric = append(numeric, part)
+			continue
+		}
+
+		if i == 0 {
+			if root, ok := wellKnownRoots[part]; ok && prefix == "" {
+				numeric = append(numeric, root)
+				continue
+			}
+			mib, err := Lookup(prefix + part)
+			if err != nil {
+				return "", err
+			}
+			numeric = append(numeric, mib.OID)
+			continue
+		}
+
+		// a name in the middle of a path must be a child of what precedes it
+		parent := strings.Join(numeric, ".")
+		child := findChild(parent, part)
+		if child == nil {
+			return "", fmt.Errorf("%s is not below %s", part, strings.Join(parts[:i], "."))
+		}
+		numeric = []string{child.OID}
+	}
+	return strings.Join(numeric, "."), nil
+}
+
+// LongestMatch finds the deepest loaded object that is a prefix of oid and
+// returns it with the remaining instance index.
+func LongestMatch(oid string) (*MibObject, string, bool) {
+	oid = strings.TrimPrefix(oid, ".")
+	for prefix := oid; prefix != ""; {
+		if mib, ok := tree[prefix]; ok {
+			return mib, strings.TrimPrefix(oid[len(prefix):], "."), true
+		}
+		i := strings.LastIndex(prefix, ".")
+		if i < 0 {
+			break
+		}
+		prefix = prefix[:i]
+	}
+	return nil, "", false
+}
+
+// Render returns oid as name.index using the longest matching object, or
+// the numeric OID if nothing matches.
+func Render(oid string) string {
+	mib, index, ok := LongestMatch(oid)
+	if !ok {
+		return strings.TrimPrefix(oid, ".")
+	}
+	if index == "" {
+		return mib.Name
+	}
+	return mib.Name + "." + index
+}
+
+func findChild(parent, name string) *MibObject {
+	for _, mib := range children[parent] {
+		if mib.Name == name {
+			return mib
+		}
+	}
+	return nil
+}
+
+func isNumber(s string) bool {
+	_, err := strconv.ParseUint(s, 10, 32)
+	return err == nil
+}
@@ -0,0 +1,234 @@
+package poller
+
+import (
+	"context"
+	"errors"
+	"snmp-test/snmp"
+	"snmp-test/snmp/scraper"
+	"sync"
+	"time"
+)
+
+// Kind selects the SnmpClient call a Query makes.
+type Kind int
+
+const (
+	// KindNames gets scalar or instance names with GetValues, or with
+	// GetTableValues when Indexes are given.
+	KindNames Kind = iota
+	// KindTable walks a table with GetBulkTableValues.
+	KindTable
+	// KindWalk walks each object with GetBulkValues.
+	KindWalk
+)
+
+// Query is one unit of work run against every target.
+type Query struct {
+	// Name identifies the query in results, it defaults to the first object.
+	Name    string
+	Kind    Kind
+	Objects []string
+	Indexes []string
+	// Timeout bounds the whole query if set. Each request is bounded by the
+	// target's own Timeout and Retries either way.
+	Timeout time.Duration
+}
+
+// Names returns a query getting the given objects.
+func Names(names ...string) Query {
+	return Query{Kind: KindNames, Objects: names}
+}
+
+// Table returns a query walking the given table.
+func Table(name string) Query {
+	return Query{Kind: KindTable, Objects: []string{name}}
+}
+
+// Walk returns a query walking the given subtrees.
+func Walk(names ...string) Query {
+	return Query{Kind: KindWalk, Objects: names}
+}
+
+func (q Query) name() string {
+	if q.Name == "" && len(q.Objects) > 0 {
+		return q.Objects[0]
+	}
+	return q.Name
+}
+
+// Target is one agent to poll.
+type Target struct {
+	// Name identifies the target in results, it defaults to Config.Target.
+	Name   string
+	Config *scraper.ClientConfig
+}
+
+func (t Target) name() string {
+	if t.Name == "" && t.Config != nil {
+		return t.Config.Target
+	}
+	return t.Name
+}
+
+// Result is the outcome of one query against one target. Values is set for
+// KindNames, Walks (keyed by name, then index) for KindWalk, and Rows for
+// KindTable and for KindNames with indexes. A partial result comes with a
+// *snmp.PartialResultError.
+type Result struct {
+	Target string
+	Query  string
+
+	Values map[string]*snmp.Value
+	Walks  map[string]map[string]*snmp.Value
+	Rows   []snmp.Row
+
+	Start    time.Time
+	Duration time.Duration
+	Err      error
+}
+
+// Config tunes a Poller. Zero values pick the defaults.
+type Config struct {
+	// Concurrency caps the queries in flight across all targets, default 64.
+	Concurrency int
+	// PerTarget caps the queries in flight against one target, default 1.
+	PerTarget int
+	// NewClient builds the client of a target, default snmp.NewClient.
+	NewClient func(config *scraper.ClientConfig) snmp.SnmpClient
+}
+
+// Poller runs queries against many targets concurrently.
+type Poller struct {
+	concurrency int
+	perTarget   int
+	newClient   func(config *scraper.ClientConfig) snmp.SnmpClient
+}
+
+// New returns a Poller applying the limits of config.
+func New(config Config) *Poller {
+	p := &Poller{
+		concurrency: config.Concurrency,
+		perTarget:   config.PerTarget,
+		newClient:   config.NewClient,
+	}
+	if p.concurrency <= 0 {
+		p.concurrency = 64
+	}
+	if p.perTarget <= 0 {
+		p.perTarget = 1
+	}
+	if p.newClient == nil {
+		p.newClient = func(c *scraper.ClientConfig) snmp.SnmpClient {
+			return snmp.NewClient(c)
+		}
+	}
+	return p
+}
+
+// Poll runs every query against every target and streams the results. The
+// channel is closed once all queries finished. If ctx is cancelled queries
+// not yet started are skipped and results not yet delivered are dropped.
+func (p *Poller) Poll(ctx context.Context, targets []Target, queries []Query) <-chan Result {
+	out := make(chan Result)
+	global := make(chan struct{}, p.concurrency)
+
+	var wg sync.WaitGroup
+	for _, target := range targets {
+		if target.Config == nil {
+			continue
+		}
+		client := p.newClient(target.Config)
+		perTarget := make(chan struct{}, p.perTarget)
+
+		var targetWg sync.WaitGroup
+		for _, query := range queries {
+			wg.Add(1)
+			targetWg.Add(1)
+			go func(target Target, query Query) {
+				defer wg.Done()
+				defer targetWg.Done()
+
+				if !acquire(ctx, perTarget) {
+					return
+				}
+				defer release(perTarget)
+				if !acquire(ctx, global) {
+					return
+				}
+				result := run(ctx, client, target, query)
+				release(global)
+
+				select {
+				case out <- result:
+				case <-ctx.Done():
+				}
+			}(target, query)
+		}
+
+		wg.Add(1)
+		go func(client snmp.SnmpClient) {
+			defer wg.Done()
+			targetWg.Wait()
+			_ = client.Close()
+		}(client)
+	}
+
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+	return out
+}
+
+// PollAll is Poll collecting every result.
+func (p *Poller) PollAll(ctx context.Context, targets []Target, queries []Query) []Result {
+	var results []Result
+	for result := range p.Poll(ctx, targets, queries) {
+		results = append(results, result)
+	}
+	return results
+}
+
+func acquire(ctx context.Context, sem chan struct{}) bool {
+	select {
+	case sem <- struct{}{}:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func release(sem chan struct{}) {
+	<-sem
+}
+
+func run(ctx context.Context, client snmp.SnmpClient, target Target, query Query) Result {
+	result := Result{Target: target.name(), Query: query.name(), Start: time.Now()}
+	if query.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, query.Timeout)
+		defer cancel()
+	}
+
+	switch query.Kind {
+	case KindNames:
+		if len(query.Indexes) > 0 {
+			result.Rows, result.Err = client.GetTableValues(ctx, query.Objects, query.Indexes)
+		} else {
+			result.Values, result.Err = client.GetValues(ctx, query.Objects...)
+		}
+	case KindTable:
+		if len(query.Objects) != 1 {
+			result.Err = errors.New("a table query takes exactly one table")
+			break
+		}
+		result.Rows, result.Err = client.GetBulkTableValues(ctx, query.Objects[0])
+	case KindWalk:
+		result.Walks, result.Err = client.GetBulkValuesByNames(ctx, query.Objects)
+	default:
+		result.Err = errors.New("unknown query kind")
+	}
+
+	result.Duration = time.Since(result.Start)
+	return result
+}
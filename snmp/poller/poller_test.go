@@ -0,0 +1,154 @@
+package poller
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"snmp-test/snmp"
+	"snmp-test/snmp/scraper"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeClient implements the calls the poller makes and counts how many run
+// at once, overall and per target.
+type fakeClient struct {
+	snmp.SnmpClient
+	target string
+	stats  *stats
+	closed atomic.Bool
+}
+
+type stats struct {
+	mu        sync.Mutex
+	inFlight  int
+	maxGlobal int
+	perTarget map[string]int
+	maxTarget int
+}
+
+func (f *fakeClient) enter() {
+	f.stats.mu.Lock()
+	defer f.stats.mu.Unlock()
+	f.stats.inFlight++
+	f.stats.perTarget[f.target]++
+	if f.stats.inFlight > f.stats.maxGlobal {
+		f.stats.maxGlobal = f.stats.inFlight
+	}
+	if f.stats.perTarget[f.target] > f.stats.maxTarget {
+		f.stats.maxTarget = f.stats.perTarget[f.target]
+	}
+}
+
+func (f *fakeClient) leave() {
+	f.stats.mu.Lock()
+	defer f.stats.mu.Unlock()
+	f.stats.inFlight--
+	f.stats.perTarget[f.target]--
+}
+
+func (f *fakeClient) GetValues(ctx context.Context, names ...string) (map[string]*snmp.Value, error) {
+	f.enter()
+	defer f.leave()
+	select {
+	case <-time.After(5 * time.Millisecond):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if f.target == "down" {
+		return nil, errors.New("no route to host")
+	}
+	ret := make(map[string]*snmp.Value, len(names))
+	for _, name := range names {
+		ret[name] = &snmp.Value{String: f.target + "/" + name}
+	}
+	return ret, nil
+}
+
+func (f *fakeClient) GetBulkTableValues(ctx context.Context, name string) ([]snmp.Row, error) {
+	f.enter()
+	defer f.leave()
+	return []snmp.Row{{Index: "1"}}, nil
+}
+
+func (f *fakeClient) Close() error {
+	f.closed.Store(true)
+	return nil
+}
+
+func newFakePoller(config Config) (*Poller, *stats, *sync.Map) {
+	st := &stats{perTarget: make(map[string]int)}
+	clients := &sync.Map{}
+	config.NewClient = func(c *scraper.ClientConfig) snmp.SnmpClient {
+		client := &fakeClient{target: c.Target, stats: st}
+		clients.Store(c.Target, client)
+		return client
+	}
+	return New(config), st, clients
+}
+
+func targets(names ...string) []Target {
+	ret := make([]Target, len(names))
+	for i, name := range names {
+		ret[i] = Target{Config: &scraper.ClientConfig{Target: name}}
+	}
+	return ret
+}
+
+func TestPoll(t *testing.T) {
+	p, st, clients := newFakePoller(Config{Concurrency: 3, PerTarget: 2})
+
+	queries := []Query{Names("sysName"), Names("sysDescr"), Names("sysUpTime"), Table("ifTable")}
+	results := p.PollAll(context.Background(), targets("r1", "r2", "r3", "down"), queries)
+
+	assert.Len(t, results, 16)
+	assert.LessOrEqual(t, st.maxGlobal, 3)
+	assert.LessOrEqual(t, st.maxTarget, 2)
+
+	for _, r := range results {
+		switch {
+		case r.Target == "down" && r.Query != "ifTable":
+			assert.Error(t, r.Err)
+		case r.Query == "ifTable":
+			assert.NoError(t, r.Err)
+			assert.Len(t, r.Rows, 1)
+		default:
+			assert.NoError(t, r.Err)
+			assert.Equal(t, r.Target+"/"+r.Query, r.Values[r.Query].String)
+		}
+		assert.Greater(t, r.Duration, time.Duration(0))
+	}
+
+	clients.Range(func(_, client any) bool {
+		assert.True(t, client.(*fakeClient).closed.Load())
+		return true
+	})
+}
+
+func TestPoll_Cancel(t *testing.T) {
+	p, _, _ := newFakePoller(Config{Concurrency: 1})
+	ctx, cancel := context.WithCancel(context.Background())
+
+	ch := p.Poll(ctx, targets("r1", "r2", "r3"), []Query{Names("sysName"), Names("sysDescr")})
+	<-ch
+	cancel()
+
+	count := 0
+	for range ch {
+		count++
+	}
+	assert.Less(t, count, 5)
+}
+
+func TestPoll_QueryTimeout(t *testing.T) {
+	p, _, _ := newFakePoller(Config{})
+	q := Names("sysName")
+	q.Name, q.Timeout = "slow", time.Millisecond
+
+	results := p.PollAll(context.Background(), targets("r1"), []Query{q})
+	assert.Len(t, results, 1)
+	assert.Equal(t, "slow", results[0].Query)
+	assert.ErrorIs(t, results[0].Err, context.DeadlineExceeded)
+}
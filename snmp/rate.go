@@ -0,0 +1,241 @@
+package snmp
+
+import (
+	"github.com/gosnmp/gosnmp"
+	gosmitypes "github.com/sleepinggenius2/gosmi/types"
+	"math/big"
+	"snmp-test/snmp/parse"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Rate is the change of one counter instance between two polls.
+type Rate struct {
+	Target string
+	Object string
+	Index  string
+	// Delta is the counter increase, corrected for one Counter32
+	// wraparound.
+	Delta    uint64
+	Interval time.Duration
+	// PerSecond is Delta divided by Interval.
+	PerSecond float64
+}
+
+// RateCalculator turns successive polls of Counter32 and Counter64 objects
+// into per-second rates. It keeps the previous sample per target, object
+// and index, and forgets samples across counter discontinuities: a
+// sysUpTime that went backwards drops every sample of the target, a changed
+// ifCounterDiscontinuityTime drops the samples of that index, and a
+// Counter64 that decreased restarts from its new value.
+//
+// It is safe for concurrent use.
+type RateCalculator struct {
+	mu      sync.Mutex
+	samples map[rateKey]rateSample
+	// markers holds the last sysUpTime per target and the last
+	// ifCounterDiscontinuityTime per target and index.
+	markers map[rateKey]uint64
+}
+
+type rateKey struct {
+	target, object, index string
+}
+
+type rateSample struct {
+	value uint64
+	at    time.Time
+}
+
+const (
+	sysUpTime                  = "sysUpTime"
+	ifCounterDiscontinuityTime = "ifCounterDiscontinuityTime"
+)
+
+// NewRateCalculator returns a calculator with no samples.
+func NewRateCalculator() *RateCalculator {
+	return &RateCalculator{
+		samples: make(map[rateKey]rateSample),
+		markers: make(map[rateKey]uint64),
+	}
+}
+
+// Observe records one value polled from target at time at. It returns the
+// rate since the previous sample of the same instance; ok is false for the
+// first sample, after a discontinuity, and for values that are not counters.
+func (c *RateCalculator) Observe(target string, v *Value, at time.Time) (rate Rate, ok bool) {
+	if v == nil || v.Object == nil || v.Number == nil || v.Number.Sign() < 0 {
+		return Rate{}, false
+	}
+	bits := counterBits(v.Object, v.Type)
+	if bits == 0 {
+		return Rate{}, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.observe(target, v.Object.Name, v.Index, v.Number.Uint64(), bits, at)
+}
+
+// ObserveValues records the result of a GetValues call. A sysUpTime among
+// the values is checked for an agent restart before the counters are.
+func (c *RateCalculator) ObserveValues(target string, values map[string]*Value, at time.Time) []Rate {
+	c.mu.Lock()
+	for _, v := range values {
+		if v != nil && v.Object != nil && v.Object.Name == sysUpTime {
+			c.checkUptime(target, v.Uint64())
+		}
+	}
+	c.mu.Unlock()
+
+	var rates []Rate
+	for _, v := range values {
+		if rate, ok := c.Observe(target, v, at); ok {
+			rates = append(rates, rate)
+		}
+	}
+	return rates
+}
+
+// ObserveRows records the rows of a GetTableValues or GetBulkTableValues
+// call. An ifCounterDiscontinuityTime column is checked per row before the
+// counters of that row are.
+func (c *RateCalculator) ObserveRows(target string, rows []Row, at time.Time) []Rate {
+	var rates []Rate
+	for _, row := range rows {
+		c.mu.Lock()
+		for _, v := range row.Values {
+			if v != nil && v.Object != nil && v.Object.Name == ifCounterDiscontinuityTime {
+				c.checkDiscontinuity(target, row.Index, v.Uint64())
+			}
+		}
+		c.mu.Unlock()
+
+		for _, v := range row.Values {
+			if rate, ok := c.Observe(target, v, at); ok {
+				rates = append(rates, rate)
+			}
+		}
+	}
+	return rates
+}
+
+// ObserveTable records the rows of a string API table call such as
+// GetTableByNamesAndIndexes or GetBulkTable. Object types come from the
+// loaded MIBs since the strings carry none.
+func (c *RateCalculator) ObserveTable(target string, rows []map[string]string, at time.Time) []Rate {
+	typed := make([]Row, 0, len(rows))
+	for _, row := range rows {
+		r := Row{Index: row["index"], Values: make(map[string]*Value, len(row))}
+		for name, str := range row {
+			mib, ok := parse.FindMib(name)
+			if !ok {
+				continue
+			}
+			n, ok := new(big.Int).SetString(strings.TrimSpace(str), 10)
+			if !ok {
+				continue
+			}
+			r.Values[name] = &Value{Object: mib, Index: r.Index, Number: n, String: str}
+		}
+		typed = append(typed, r)
+	}
+	return c.ObserveRows(target, typed, at)
+}
+
+// Forget drops every sample and marker of target.
+func (c *RateCalculator) Forget(target string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.reset(target, nil)
+	for k := range c.markers {
+		if k.target == target {
+			delete(c.markers, k)
+		}
+	}
+}
+
+func (c *RateCalculator) observe(target, object, index string, value uint64, bits int, at time.Time) (Rate, bool) {
+	key := rateKey{target: target, object: object, index: index}
+	prev, seen := c.samples[key]
+	c.samples[key] = rateSample{value: value, at: at}
+	if !seen {
+		return Rate{}, false
+	}
+
+	interval := at.Sub(prev.at)
+	if interval <= 0 {
+		return Rate{}, false
+	}
+
+	delta := value - prev.value
+	if value < prev.value {
+		if bits != 32 {
+			// a Counter64 does not wrap in practice, it was reset; the new
+			// sample is the baseline
+			return Rate{}, false
+		}
+		delta = value + (1 << 32) - prev.value
+	}
+	return Rate{
+		Target:    target,
+		Object:    object,
+		Index:     index,
+		Delta:     delta,
+		Interval:  interval,
+		PerSecond: float64(delta) / interval.Seconds(),
+	}, true
+}
+
+// checkUptime drops the samples of target if its agent restarted.
+func (c *RateCalculator) checkUptime(target string, uptime uint64) {
+	key := rateKey{target: target, object: sysUpTime}
+	if last, ok := c.markers[key]; ok && uptime < last {
+		c.reset(target, nil)
+	}
+	c.markers[key] = uptime
+}
+
+// checkDiscontinuity drops the samples of one index of target if its
+// counters were reset.
+func (c *RateCalculator) checkDiscontinuity(target, index string, ticks uint64) {
+	key := rateKey{target: target, object: ifCounterDiscontinuityTime, index: index}
+	if last, ok := c.markers[key]; ok && ticks != last {
+		c.reset(target, &index)
+	}
+	c.markers[key] = ticks
+}
+
+func (c *RateCalculator) reset(target string, index *string) {
+	for k := range c.samples {
+		if k.target == target && (index == nil || k.index == *index) {
+			delete(c.samples, k)
+		}
+	}
+}
+
+// counterBits returns 32 or 64 for counter objects and 0 for anything else.
+// The agent's varbind type wins over the MIB when it is known.
+func counterBits(mib *parse.MibObject, typ gosnmp.Asn1BER) int {
+	switch typ {
+	case gosnmp.Counter32:
+		return 32
+	case gosnmp.Counter64:
+		return 64
+	case gosnmp.Gauge32, gosnmp.TimeTicks, gosnmp.Integer, gosnmp.Uinteger32:
+		return 0
+	}
+
+	if !strings.Contains(mib.Type, "Counter") {
+		return 0
+	}
+	switch gosmitypes.BaseType(mib.SmiType) {
+	case gosmitypes.BaseTypeUnsigned32:
+		return 32
+	case gosmitypes.BaseTypeUnsigned64:
+		return 64
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,94 @@
+package snmp
+
+import (
+	"github.com/gosnmp/gosnmp"
+	gosmitypes "github.com/sleepinggenius2/gosmi/types"
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"snmp-test/snmp/parse"
+	"testing"
+	"time"
+)
+
+var (
+	inOctets = &parse.MibObject{Name: "ifInOctets", Type: "Counter32", SmiType: int(gosmitypes.BaseTypeUnsigned32)}
+	hcOctets = &parse.MibObject{Name: "ifHCInOctets", Type: "Counter64", SmiType: int(gosmitypes.BaseTypeUnsigned64)}
+	inGauge  = &parse.MibObject{Name: "ifSpeed", Type: "Gauge32", SmiType: int(gosmitypes.BaseTypeUnsigned32)}
+	upTime   = &parse.MibObject{Name: "sysUpTime", Type: "TimeTicks", SmiType: int(gosmitypes.BaseTypeUnsigned32)}
+	discTime = &parse.MibObject{Name: "ifCounterDiscontinuityTime", Type: "TimeStamp", SmiType: int(gosmitypes.BaseTypeUnsigned32)}
+)
+
+func counter(mib *parse.MibObject, index string, typ gosnmp.Asn1BER, n uint64) *Value {
+	return &Value{Object: mib, Index: index, Type: typ, Number: new(big.Int).SetUint64(n)}
+}
+
+func TestRateCalculator_Wraparound(t *testing.T) {
+	c := NewRateCalculator()
+	t0 := time.Unix(1700000000, 0)
+
+	_, ok := c.Observe("r1", counter(inOctets, "1", gosnmp.Counter32, 4294967000), t0)
+	assert.False(t, ok, "first sample has no rate")
+
+	rate, ok := c.Observe("r1", counter(inOctets, "1", gosnmp.Counter32, 704), t0.Add(10*time.Second))
+	assert.True(t, ok)
+	assert.Equal(t, uint64(1000), rate.Delta)
+	assert.Equal(t, 100.0, rate.PerSecond)
+	assert.Equal(t, "ifInOctets", rate.Object)
+
+	// a Counter64 going backwards was reset, not wrapped
+	c.Observe("r1", counter(hcOctets, "1", gosnmp.Counter64, 1<<64-10), t0)
+	_, ok = c.Observe("r1", counter(hcOctets, "1", gosnmp.Counter64, 10), t0.Add(time.Second))
+	assert.False(t, ok)
+	rate, ok = c.Observe("r1", counter(hcOctets, "1", gosnmp.Counter64, 30), t0.Add(2*time.Second))
+	assert.True(t, ok)
+	assert.Equal(t, uint64(20), rate.Delta)
+
+	// instances and targets are tracked separately
+	_, ok = c.Observe("r2", counter(inOctets, "1", gosnmp.Counter32, 1), t0.Add(20*time.Second))
+	assert.False(t, ok)
+	_, ok = c.Observe("r1", counter(inOctets, "2", gosnmp.Counter32, 1), t0.Add(20*time.Second))
+	assert.False(t, ok)
+
+	_, ok = c.Observe("r1", counter(inGauge, "1", gosnmp.Gauge32, 1), t0)
+	assert.False(t, ok, "gauges have no rate")
+}
+
+func TestRateCalculator_Discontinuity(t *testing.T) {
+	c := NewRateCalculator()
+	t0 := time.Unix(1700000000, 0)
+
+	poll := func(at time.Time, uptime, octets uint64) []Rate {
+		return c.ObserveValues("r1", map[string]*Value{
+			"sysUpTime":  counter(upTime, "0", gosnmp.TimeTicks, uptime),
+			"ifInOctets": counter(inOctets, "1", gosnmp.Counter32, octets),
+		}, at)
+	}
+	assert.Empty(t, poll(t0, 1000, 100))
+	assert.Len(t, poll(t0.Add(time.Second), 1100, 200), 1)
+	assert.Empty(t, poll(t0.Add(2*time.Second), 50, 10), "agent restarted")
+	assert.Len(t, poll(t0.Add(3*time.Second), 150, 20), 1)
+
+	row := func(index string, disc, octets uint64) Row {
+		return Row{Index: index, Values: map[string]*Value{
+			"ifCounterDiscontinuityTime": counter(discTime, index, gosnmp.TimeTicks, disc),
+			"ifHCInOctets":               counter(hcOctets, index, gosnmp.Counter64, octets),
+		}}
+	}
+	assert.Empty(t, c.ObserveRows("r1", []Row{row("1", 0, 100), row("2", 0, 100)}, t0))
+	rates := c.ObserveRows("r1", []Row{row("1", 500, 150), row("2", 0, 300)}, t0.Add(2*time.Second))
+	assert.Len(t, rates, 1, "index 1 was reset")
+	assert.Equal(t, "2", rates[0].Index)
+	assert.Equal(t, 100.0, rates[0].PerSecond)
+}
+
+func TestRateCalculator_ObserveTable(t *testing.T) {
+	loadTestMibs(t)
+	c := NewRateCalculator()
+	t0 := time.Unix(1700000000, 0)
+
+	c.ObserveTable("r1", []map[string]string{{"index": "7", "testNodeInOctets": "1000", "testNodeName": "a"}}, t0)
+	rates := c.ObserveTable("r1", []map[string]string{{"index": "7", "testNodeInOctets": "3000", "testNodeName": "a"}}, t0.Add(4*time.Second))
+	assert.Len(t, rates, 1)
+	assert.Equal(t, "testNodeInOctets", rates[0].Object)
+	assert.Equal(t, 500.0, rates[0].PerSecond)
+}
@@ -0,0 +1,282 @@
+package record
+
+import (
+	"fmt"
+	"github.com/gosnmp/gosnmp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// entry is one instance with its parsed OID.
+type entry struct {
+	oid []uint32
+	pdu gosnmp.SnmpPDU
+}
+
+// Dataset is a set of instances kept in OID order so GetNext is a binary
+// search. It is safe for concurrent use.
+type Dataset struct {
+	mu      sync.RWMutex
+	entries []entry
+}
+
+// NewDataset returns a dataset holding pdus; a later duplicate OID wins.
+func NewDataset(pdus []gosnmp.SnmpPDU) (*Dataset, error) {
+	s := &Dataset{entries: make([]entry, 0, len(pdus))}
+	for _, pdu := range pdus {
+		oid, err := parseOID(pdu.Name)
+		if err != nil {
+			return nil, err
+		}
+		pdu.Name = formatOID(oid)
+		s.entries = append(s.entries, entry{oid: oid, pdu: pdu})
+	}
+	sort.SliceStable(s.entries, func(i, j int) bool {
+		return compareOID(s.entries[i].oid, s.entries[j].oid) < 0
+	})
+
+	// a later duplicate wins, as if it had been set after the first
+	deduped := s.entries[:0]
+	for _, e := range s.entries {
+		if n := len(deduped); n > 0 && compareOID(deduped[n-1].oid, e.oid) == 0 {
+			deduped[n-1] = e
+			continue
+		}
+		deduped = append(deduped, e)
+	}
+	s.entries = deduped
+	return s, nil
+}
+
+// search returns the position of the first entry not below oid.
+func (s *Dataset) search(oid []uint32) (int, bool) {
+	i := sort.Search(len(s.entries), func(i int) bool {
+		return compareOID(s.entries[i].oid, oid) >= 0
+	})
+	return i, i < len(s.entries) && compareOID(s.entries[i].oid, oid) == 0
+}
+
+// Get returns the instance at oid or a noSuchObject/noSuchInstance varbind.
+// Without a MIB the object is guessed: an instance is missing rather than
+// the object if anything is served below the requested OID or its parent.
+func (s *Dataset) Get(name string) gosnmp.SnmpPDU {
+	oid, err := parseOID(name)
+	if err != nil {
+		return gosnmp.SnmpPDU{Name: name, Type: gosnmp.NoSuchObject}
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	i, ok := s.search(oid)
+	if ok {
+		return s.entries[i].pdu
+	}
+
+	typ := gosnmp.NoSuchObject
+	if s.below(i, oid) || (len(oid) > 1 && (s.below(i, oid[:len(oid)-1]) || s.below(i-1, oid[:len(oid)-1]))) {
+		typ = gosnmp.NoSuchInstance
+	}
+	return gosnmp.SnmpPDU{Name: formatOID(oid), Type: typ}
+}
+
+// below reports whether entry i exists and lies at or under prefix.
+func (s *Dataset) below(i int, prefix []uint32) bool {
+	return i >= 0 && i < len(s.entries) && hasPrefix(s.entries[i].oid, prefix)
+}
+
+// Next returns the first instance after name; ok is false at the end of the
+// MIB view.
+func (s *Dataset) Next(name string) (gosnmp.SnmpPDU, bool) {
+	oid, err := parseOID(name)
+	if err != nil {
+		return gosnmp.SnmpPDU{Name: name, Type: gosnmp.EndOfMibView}, false
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	i, ok := s.search(oid)
+	if ok {
+		i++
+	}
+	if i >= len(s.entries) {
+		return gosnmp.SnmpPDU{Name: formatOID(oid), Type: gosnmp.EndOfMibView}, false
+	}
+	return s.entries[i].pdu, true
+}
+
+// SetError is the index and status of the first varbind a set rejects.
+type SetError struct {
+	Index  int
+	Status gosnmp.SNMPError
+}
+
+func (e *SetError) Error() string {
+	return fmt.Sprintf("varbind %d rejected: %s", e.Index+1, e.Status)
+}
+
+// Set applies all pdus or none of them, like an agent does for one
+// SetRequest. An existing instance keeps its type; new instances are
+// created as given.
+func (s *Dataset) Set(pdus []gosnmp.SnmpPDU) *SetError {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	oids := make([][]uint32, len(pdus))
+	for n, pdu := range pdus {
+		oid, err := parseOID(pdu.Name)
+		if err != nil {
+			return &SetError{Index: n, Status: gosnmp.NoCreation}
+		}
+		switch pdu.Type {
+		case gosnmp.Null, gosnmp.NoSuchObject, gosnmp.NoSuchInstance, gosnmp.EndOfMibView:
+			return &SetError{Index: n, Status: gosnmp.WrongType}
+		}
+		if i, ok := s.search(oid); ok && s.entries[i].pdu.Type != pdu.Type {
+			return &SetError{Index: n, Status: gosnmp.WrongType}
+		}
+		oids[n] = oid
+	}
+
+	for n, pdu := range pdus {
+		s.put(oids[n], pdu)
+	}
+	return nil
+}
+
+// Add stores pdus, replacing instances of any type. Exceptions such as
+// noSuchObject are not instances and are ignored.
+func (s *Dataset) Add(pdus ...gosnmp.SnmpPDU) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, pdu := range pdus {
+		switch pdu.Type {
+		case gosnmp.NoSuchObject, gosnmp.NoSuchInstance, gosnmp.EndOfMibView:
+			continue
+		}
+		oid, err := parseOID(pdu.Name)
+		if err != nil {
+			return err
+		}
+		s.put(oid, pdu)
+	}
+	return nil
+}
+
+func (s *Dataset) put(oid []uint32, pdu gosnmp.SnmpPDU) {
+	pdu.Name = formatOID(oid)
+	// decoded octets point into the receive buffer; strings from callers
+	// are stored the way they would be decoded
+	switch v := pdu.Value.(type) {
+	case []byte:
+		pdu.Value = append([]byte{}, v...)
+	case string:
+		if pdu.Type == gosnmp.OctetString || pdu.Type == gosnmp.Opaque {
+			pdu.Value = []byte(v)
+		}
+	}
+	i, ok := s.search(oid)
+	if ok {
+		s.entries[i].pdu = pdu
+		return
+	}
+	s.entries = append(s.entries, entry{})
+	copy(s.entries[i+1:], s.entries[i:])
+	s.entries[i] = entry{oid: oid, pdu: pdu}
+}
+
+// Walk returns the instances below name in OID order. Like a walk on an
+// agent, it returns name itself if nothing lies below it.
+func (s *Dataset) Walk(name string) []gosnmp.SnmpPDU {
+	oid, err := parseOID(name)
+	if err != nil {
+		return nil
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	i, ok := s.search(oid)
+	if ok && !s.below(i+1, oid) {
+		return []gosnmp.SnmpPDU{s.entries[i].pdu}
+	}
+	if ok {
+		i++
+	}
+	var pdus []gosnmp.SnmpPDU
+	for ; s.below(i, oid); i++ {
+		pdus = append(pdus, s.entries[i].pdu)
+	}
+	return pdus
+}
+
+// Remove drops every instance at or below name.
+func (s *Dataset) Remove(name string) {
+	oid, err := parseOID(name)
+	if err != nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i, _ := s.search(oid)
+	j := i
+	for j < len(s.entries) && hasPrefix(s.entries[j].oid, oid) {
+		j++
+	}
+	s.entries = append(s.entries[:i], s.entries[j:]...)
+}
+
+// PDUs returns every instance in OID order.
+func (s *Dataset) PDUs() []gosnmp.SnmpPDU {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	pdus := make([]gosnmp.SnmpPDU, 0, len(s.entries))
+	for _, e := range s.entries {
+		pdus = append(pdus, e.pdu)
+	}
+	return pdus
+}
+
+func parseOID(s string) ([]uint32, error) {
+	s = strings.TrimPrefix(s, ".")
+	if s == "" {
+		return nil, fmt.Errorf("empty oid")
+	}
+	parts := strings.Split(s, ".")
+	oid := make([]uint32, 0, len(parts))
+	for _, part := range parts {
+		n, err := strconv.ParseUint(part, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid oid %s", s)
+		}
+		oid = append(oid, uint32(n))
+	}
+	return oid, nil
+}
+
+func formatOID(oid []uint32) string {
+	var sb strings.Builder
+	for _, n := range oid {
+		sb.WriteByte('.')
+		sb.WriteString(strconv.FormatUint(uint64(n), 10))
+	}
+	return sb.String()
+}
+
+func compareOID(a, b []uint32) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] != b[i] {
+			if a[i] < b[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return len(a) - len(b)
+}
+
+func hasPrefix(oid, prefix []uint32) bool {
+	return len(oid) >= len(prefix) && compareOID(oid[:len(prefix)], prefix) == 0
+}
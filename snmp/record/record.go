@@ -0,0 +1,114 @@
+// Package record captures what a device answers and replays it: a Recorder
+// wraps a scraper and keeps every PDU it sees, Load and Save read and write
+// snmpsim's .snmprec and net-snmp's `snmpwalk -On` text formats, and Replay
+// serves a recording as a scraper.
+package record
+
+import (
+	"github.com/gosnmp/gosnmp"
+	"os"
+	"path/filepath"
+	"snmp-test/snmp/scraper"
+	"strings"
+)
+
+// Load reads a recording, in the .snmprec format if the file has that
+// extension and as `snmpwalk -On` output otherwise.
+func Load(path string) ([]gosnmp.SnmpPDU, error) {
+	if !isSnmprec(path) {
+		return LoadWalk(path)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadSnmprec(f)
+}
+
+// Save writes pdus to path in the format Load picks for it.
+func Save(path string, pdus []gosnmp.SnmpPDU) error {
+	write := WriteWalk
+	if isSnmprec(path) {
+		write = WriteSnmprec
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(f, pdus); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func isSnmprec(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".snmprec")
+}
+
+// Recorder is a scraper that keeps every instance returned by the gets and
+// walks of the scraper it wraps. Set is passed through unrecorded, the
+// agent's answer is not the state of the device.
+type Recorder struct {
+	scraper.SNMPScraper
+	data *Dataset
+}
+
+var _ scraper.SNMPScraper = (*Recorder)(nil)
+
+// NewRecorder records what inner returns into data.
+func NewRecorder(inner scraper.SNMPScraper, data *Dataset) *Recorder {
+	return &Recorder{SNMPScraper: inner, data: data}
+}
+
+// Data returns the recording so far.
+func (r *Recorder) Data() *Dataset {
+	return r.data
+}
+
+func (r *Recorder) Get(oids []string) (*gosnmp.SnmpPacket, error) {
+	return r.record(r.SNMPScraper.Get(oids))
+}
+
+// record keeps the instances of a response without an error status.
+func (r *Recorder) record(packet *gosnmp.SnmpPacket, err error) (*gosnmp.SnmpPacket, error) {
+	if err != nil {
+		return packet, err
+	}
+	if packet.Error == gosnmp.NoError {
+		err = r.data.Add(packet.Variables...)
+	}
+	return packet, err
+}
+
+func (r *Recorder) GetNext(oids []string) (*gosnmp.SnmpPacket, error) {
+	return r.record(r.SNMPScraper.GetNext(oids))
+}
+
+func (r *Recorder) GetBulk(oids []string, nonRepeaters uint8, maxRepetitions uint32) (*gosnmp.SnmpPacket, error) {
+	return r.record(r.SNMPScraper.GetBulk(oids, nonRepeaters, maxRepetitions))
+}
+
+func (r *Recorder) Walk(oid string, fn gosnmp.WalkFunc) error {
+	return r.SNMPScraper.Walk(oid, func(pdu gosnmp.SnmpPDU) error {
+		if err := r.data.Add(pdu); err != nil {
+			return err
+		}
+		return fn(pdu)
+	})
+}
+
+func (r *Recorder) WalkAll(oid string) ([]gosnmp.SnmpPDU, error) {
+	pdus, err := r.SNMPScraper.WalkAll(oid)
+	if err != nil {
+		return pdus, err
+	}
+	return pdus, r.data.Add(pdus...)
+}
+
+// Save writes the recording to path, see Save.
+func (r *Recorder) Save(path string) error {
+	return Save(path, r.data.PDUs())
+}
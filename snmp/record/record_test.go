@@ -0,0 +1,86 @@
+package record
+
+import (
+	"github.com/gosnmp/gosnmp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"testing"
+)
+
+func TestRecorder(t *testing.T) {
+	device, err := LoadReplay("../testdata/agent.walk")
+	require.NoError(t, err)
+	empty, err := NewDataset(nil)
+	require.NoError(t, err)
+	recorder := NewRecorder(device, empty)
+
+	_, err = recorder.Get([]string{".1.3.6.1.4.1.99999.1.1.0", ".1.3.6.1.4.1.99999.1.9.0"})
+	require.NoError(t, err)
+	_, err = recorder.WalkAll(".1.3.6.1.4.1.99999.2")
+	require.NoError(t, err)
+	_, err = recorder.Set([]gosnmp.SnmpPDU{{Name: ".1.3.6.1.4.1.99999.1.5.0", Type: gosnmp.Integer, Value: 240}})
+	require.NoError(t, err)
+
+	// the missing instance and the set are not part of the recording
+	want := append(device.Data().Walk(".1.3.6.1.4.1.99999.1.1.0"), device.Data().Walk(".1.3.6.1.4.1.99999.2")...)
+	require.Len(t, want, 7)
+	assert.Equal(t, want, recorder.Data().PDUs())
+
+	for _, name := range []string{"device.snmprec", "device.walk"} {
+		path := filepath.Join(t.TempDir(), name)
+		require.NoError(t, recorder.Save(path))
+		replay, err := LoadReplay(path)
+		require.NoError(t, err, name)
+		assert.Equal(t, want, replay.Data().PDUs(), name)
+	}
+}
+
+func TestReplay(t *testing.T) {
+	replay, err := LoadReplay("../testdata/agent.walk")
+	require.NoError(t, err)
+	require.NoError(t, replay.Connect())
+	defer replay.Close()
+
+	packet, err := replay.Get([]string{".1.3.6.1.4.1.99999.1.1.0", ".1.3.6.1.4.1.99999.2.1.3.9", ".1.3.6.1.4.1.99998.1.0"})
+	require.NoError(t, err)
+	assert.Equal(t, []byte(`CASA "C100G"`), packet.Variables[0].Value)
+	assert.Equal(t, gosnmp.NoSuchInstance, packet.Variables[1].Type)
+	assert.Equal(t, gosnmp.NoSuchObject, packet.Variables[2].Type)
+
+	packet, err = replay.GetNext([]string{".1.3.6.1.4.1.99999.2.1.3", ".1.3.6.1.4.1.99999.3.1.6.1.4.10.0.0.1"})
+	require.NoError(t, err)
+	assert.Equal(t, ".1.3.6.1.4.1.99999.2.1.3.0.23.16.43.105.88.10.0.0.1", packet.Variables[0].Name)
+	assert.Equal(t, gosnmp.EndOfMibView, packet.Variables[1].Type)
+
+	pdus, err := replay.WalkAll(".1.3.6.1.4.1.99999.3")
+	require.NoError(t, err)
+	assert.Len(t, pdus, 4)
+	var walked []gosnmp.SnmpPDU
+	require.NoError(t, replay.Walk(".1.3.6.1.4.1.99999.3", func(pdu gosnmp.SnmpPDU) error {
+		walked = append(walked, pdu)
+		return nil
+	}))
+	assert.Equal(t, pdus, walked)
+
+	packet, err = replay.GetBulk([]string{".1.3.6.1.4.1.99999.1.1.0", ".1.3.6.1.4.1.99999.2.1.3"}, 1, 2)
+	require.NoError(t, err)
+	require.Len(t, packet.Variables, 3)
+	assert.Equal(t, ".1.3.6.1.4.1.99999.1.2.0", packet.Variables[0].Name)
+	assert.Equal(t, ".1.3.6.1.4.1.99999.2.1.3.0.23.16.43.105.89.10.0.0.2", packet.Variables[2].Name)
+
+	packet, err = replay.Set([]gosnmp.SnmpPDU{
+		{Name: ".1.3.6.1.4.1.99999.1.1.0", Type: gosnmp.OctetString, Value: "C100G-130"},
+		{Name: ".1.3.6.1.4.1.99999.1.2.0", Type: gosnmp.Integer, Value: 1},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, gosnmp.WrongType, packet.Error)
+	assert.Equal(t, uint8(2), packet.ErrorIndex)
+
+	packet, err = replay.Set([]gosnmp.SnmpPDU{{Name: ".1.3.6.1.4.1.99999.1.1.0", Type: gosnmp.OctetString, Value: "C100G-130"}})
+	require.NoError(t, err)
+	assert.Equal(t, gosnmp.NoError, packet.Error)
+	packet, err = replay.Get([]string{".1.3.6.1.4.1.99999.1.1.0"})
+	require.NoError(t, err)
+	assert.Equal(t, []byte("C100G-130"), packet.Variables[0].Value)
+}
@@ -0,0 +1,176 @@
+package record
+
+import (
+	"github.com/gosnmp/gosnmp"
+	"snmp-test/snmp/scraper"
+	"strings"
+)
+
+// Replay is a scraper answering from a recording instead of the network.
+// It behaves like a v2c agent: missing instances come back as
+// noSuchObject/noSuchInstance varbinds and sets change the recording.
+type Replay struct {
+	c    *gosnmp.GoSNMP
+	data *Dataset
+}
+
+var _ scraper.SNMPScraper = (*Replay)(nil)
+
+// NewReplay serves data.
+func NewReplay(data *Dataset) *Replay {
+	return &Replay{c: &gosnmp.GoSNMP{Version: gosnmp.Version2c}, data: data}
+}
+
+// LoadReplay serves the recording at path, see Load.
+func LoadReplay(path string) (*Replay, error) {
+	pdus, err := Load(path)
+	if err != nil {
+		return nil, err
+	}
+	data, err := NewDataset(pdus)
+	if err != nil {
+		return nil, err
+	}
+	return NewReplay(data), nil
+}
+
+// Data returns the recording being served.
+func (r *Replay) Data() *Dataset {
+	return r.data
+}
+
+func (r *Replay) Connect() error {
+	return nil
+}
+
+func (r *Replay) Close() error {
+	return nil
+}
+
+// SetOptions applies fns to a client that is never connected; only its
+// Context is honored.
+func (r *Replay) SetOptions(fns ...func(snmp *gosnmp.GoSNMP)) {
+	for _, fn := range fns {
+		fn(r.c)
+	}
+}
+
+func (r *Replay) Get(oids []string) (*gosnmp.SnmpPacket, error) {
+	if err := r.err(); err != nil {
+		return nil, err
+	}
+	pdus := make([]gosnmp.SnmpPDU, len(oids))
+	for i, oid := range oids {
+		pdus[i] = r.data.Get(oid)
+	}
+	return r.response(pdus), nil
+}
+
+func (r *Replay) GetNext(oids []string) (*gosnmp.SnmpPacket, error) {
+	if err := r.err(); err != nil {
+		return nil, err
+	}
+	pdus := make([]gosnmp.SnmpPDU, len(oids))
+	for i, oid := range oids {
+		pdus[i], _ = r.data.Next(oid)
+	}
+	return r.response(pdus), nil
+}
+
+// GetBulk answers like an agent with no limit on the response size: the
+// non-repeaters once, then the repeaters until maxRepetitions or until all
+// of them are at the end of the MIB view.
+func (r *Replay) GetBulk(oids []string, nonRepeaters uint8, maxRepetitions uint32) (*gosnmp.SnmpPacket, error) {
+	if err := r.err(); err != nil {
+		return nil, err
+	}
+	n := int(nonRepeaters)
+	if n > len(oids) {
+		n = len(oids)
+	}
+
+	var pdus []gosnmp.SnmpPDU
+	for _, oid := range oids[:n] {
+		pdu, _ := r.data.Next(oid)
+		pdus = append(pdus, pdu)
+	}
+	cursors := append([]string{}, oids[n:]...)
+	for rep := uint32(0); rep < maxRepetitions && len(cursors) > 0; rep++ {
+		ended := true
+		for i, cursor := range cursors {
+			pdu, ok := r.data.Next(cursor)
+			if ok {
+				cursors[i] = pdu.Name
+				ended = false
+			}
+			pdus = append(pdus, pdu)
+		}
+		if ended {
+			break
+		}
+	}
+	return r.response(pdus), nil
+}
+
+func (r *Replay) WalkAll(oid string) ([]gosnmp.SnmpPDU, error) {
+	if err := r.err(); err != nil {
+		return nil, err
+	}
+	return r.data.Walk(oid), nil
+}
+
+// Walk is WalkAll one instance at a time, without copying the subtree.
+func (r *Replay) Walk(oid string, fn gosnmp.WalkFunc) error {
+	root := "." + strings.TrimPrefix(oid, ".")
+	n := 0
+	for cursor := root; ; n++ {
+		if err := r.err(); err != nil {
+			return err
+		}
+		pdu, ok := r.data.Next(cursor)
+		if !ok || !strings.HasPrefix(pdu.Name, root+".") {
+			break
+		}
+		if err := fn(pdu); err != nil {
+			return err
+		}
+		cursor = pdu.Name
+	}
+
+	// like WalkAll, a walk of an instance returns the instance
+	if pdu := r.data.Get(root); n == 0 && !isException(pdu.Type) {
+		return fn(pdu)
+	}
+	return nil
+}
+
+func (r *Replay) Set(pdus []gosnmp.SnmpPDU) (*gosnmp.SnmpPacket, error) {
+	if err := r.err(); err != nil {
+		return nil, err
+	}
+	packet := r.response(pdus)
+	if err := r.data.Set(pdus); err != nil {
+		packet.Error = err.Status
+		packet.ErrorIndex = uint8(err.Index + 1)
+	}
+	return packet, nil
+}
+
+func (r *Replay) err() error {
+	if r.c.Context == nil {
+		return nil
+	}
+	return r.c.Context.Err()
+}
+
+func (r *Replay) response(pdus []gosnmp.SnmpPDU) *gosnmp.SnmpPacket {
+	return &gosnmp.SnmpPacket{
+		Version:   r.c.Version,
+		PDUType:   gosnmp.GetResponse,
+		Variables: pdus,
+	}
+}
+
+func isException(t gosnmp.Asn1BER) bool {
+	return t == gosnmp.NoSuchObject || t == gosnmp.NoSuchInstance || t == gosnmp.EndOfMibView
+}
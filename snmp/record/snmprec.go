@@ -0,0 +1,147 @@
+package record
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"github.com/gosnmp/gosnmp"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// snmprec tags are the BER tags of the value types.
+var snmprecTypes = map[string]gosnmp.Asn1BER{
+	"2":  gosnmp.Integer,
+	"4":  gosnmp.OctetString,
+	"5":  gosnmp.Null,
+	"6":  gosnmp.ObjectIdentifier,
+	"64": gosnmp.IPAddress,
+	"65": gosnmp.Counter32,
+	"66": gosnmp.Gauge32,
+	"67": gosnmp.TimeTicks,
+	"68": gosnmp.Opaque,
+	"70": gosnmp.Counter64,
+	"71": gosnmp.Uinteger32,
+}
+
+// ReadSnmprec parses snmpsim's .snmprec format, one "oid|tag|value" per
+// line, e.g.
+//
+//	1.3.6.1.2.1.1.1.0|4|CASA C100G
+//	1.3.6.1.2.1.2.2.1.6.2|4x|00171029b958
+//
+// A tag with an "x" suffix carries a hex encoded value. Variation modules
+// ("4:numeric", "4e") are not supported.
+func ReadSnmprec(r io.Reader) ([]gosnmp.SnmpPDU, error) {
+	var pdus []gosnmp.SnmpPDU
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.SplitN(line, "|", 3)
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("line %d: expected oid|tag|value, got %q", n, line)
+		}
+		pdu, err := parseSnmprecValue(parts[1], parts[2])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s: %w", n, parts[0], err)
+		}
+		pdu.Name = "." + strings.TrimPrefix(parts[0], ".")
+		pdus = append(pdus, pdu)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return pdus, nil
+}
+
+func parseSnmprecValue(tag, value string) (pdu gosnmp.SnmpPDU, err error) {
+	isHex := strings.HasSuffix(tag, "x")
+	typ, ok := snmprecTypes[strings.TrimSuffix(tag, "x")]
+	if !ok {
+		return pdu, fmt.Errorf("unsupported tag %s", tag)
+	}
+	if isHex {
+		b, err := hex.DecodeString(value)
+		if err != nil {
+			return pdu, fmt.Errorf("invalid hex value: %w", err)
+		}
+		value = string(b)
+	}
+
+	pdu.Type = typ
+	switch typ {
+	case gosnmp.OctetString, gosnmp.Opaque:
+		pdu.Value = []byte(value)
+	case gosnmp.Integer:
+		pdu.Value, err = strconv.Atoi(value)
+	case gosnmp.Counter32, gosnmp.Gauge32, gosnmp.Uinteger32:
+		var n uint64
+		n, err = strconv.ParseUint(value, 10, 32)
+		pdu.Value = uint(n)
+	case gosnmp.TimeTicks:
+		var n uint64
+		n, err = strconv.ParseUint(value, 10, 32)
+		pdu.Value = uint32(n)
+	case gosnmp.Counter64:
+		pdu.Value, err = strconv.ParseUint(value, 10, 64)
+	case gosnmp.IPAddress:
+		pdu.Value = value
+		if isHex && len(value) == 4 {
+			pdu.Value = fmt.Sprintf("%d.%d.%d.%d", value[0], value[1], value[2], value[3])
+		}
+	case gosnmp.ObjectIdentifier:
+		pdu.Value = "." + strings.TrimPrefix(value, ".")
+	}
+	return pdu, err
+}
+
+// WriteSnmprec writes pdus in the .snmprec format. Strings that are not
+// printable ASCII are hex encoded.
+func WriteSnmprec(w io.Writer, pdus []gosnmp.SnmpPDU) error {
+	bw := bufio.NewWriter(w)
+	for _, pdu := range pdus {
+		tag, value, err := formatSnmprecValue(pdu)
+		if err != nil {
+			return fmt.Errorf("%s: %w", pdu.Name, err)
+		}
+		fmt.Fprintf(bw, "%s|%s|%s\n", strings.TrimPrefix(pdu.Name, "."), tag, value)
+	}
+	return bw.Flush()
+}
+
+func formatSnmprecValue(pdu gosnmp.SnmpPDU) (string, string, error) {
+	switch pdu.Type {
+	case gosnmp.OctetString, gosnmp.BitString:
+		b := toBytes(pdu.Value)
+		if isPrintable(b, false) {
+			return "4", string(b), nil
+		}
+		return "4x", hex.EncodeToString(b), nil
+	case gosnmp.Opaque:
+		return "68x", hex.EncodeToString(toBytes(pdu.Value)), nil
+	case gosnmp.Integer:
+		return "2", gosnmp.ToBigInt(pdu.Value).String(), nil
+	case gosnmp.Counter32:
+		return "65", gosnmp.ToBigInt(pdu.Value).String(), nil
+	case gosnmp.Gauge32:
+		return "66", gosnmp.ToBigInt(pdu.Value).String(), nil
+	case gosnmp.Uinteger32:
+		return "71", gosnmp.ToBigInt(pdu.Value).String(), nil
+	case gosnmp.TimeTicks:
+		return "67", gosnmp.ToBigInt(pdu.Value).String(), nil
+	case gosnmp.Counter64:
+		return "70", gosnmp.ToBigInt(pdu.Value).String(), nil
+	case gosnmp.IPAddress:
+		return "64", fmt.Sprint(pdu.Value), nil
+	case gosnmp.ObjectIdentifier:
+		return "6", strings.TrimPrefix(fmt.Sprint(pdu.Value), "."), nil
+	case gosnmp.Null:
+		return "5", "", nil
+	}
+	return "", "", fmt.Errorf("unsupported type %s", pdu.Type)
+}
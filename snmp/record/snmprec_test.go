@@ -0,0 +1,58 @@
+package record
+
+import (
+	"github.com/gosnmp/gosnmp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func TestReadSnmprec(t *testing.T) {
+	pdus, err := ReadSnmprec(strings.NewReader(`# comment
+1.3.6.1.2.1.1.1.0|4|CASA C100G|x
+1.3.6.1.2.1.1.3.0|67|18295586
+1.3.6.1.2.1.2.2.1.6.2|4x|00171029b958
+1.3.6.1.2.1.2.2.1.8.2|2|-1
+1.3.6.1.2.1.4.20.1.1.10.0.0.1|64|10.0.0.1
+1.3.6.1.2.1.4.20.1.1.10.0.0.2|64x|0a000002
+1.3.6.1.2.1.31.1.1.1.6.2|70|18446744073709551615
+1.3.6.1.2.1.1.2.0|6|1.3.6.1.4.1.99999
+
+`))
+	require.NoError(t, err)
+	assert.Equal(t, []gosnmp.SnmpPDU{
+		{Name: ".1.3.6.1.2.1.1.1.0", Type: gosnmp.OctetString, Value: []byte("CASA C100G|x")},
+		{Name: ".1.3.6.1.2.1.1.3.0", Type: gosnmp.TimeTicks, Value: uint32(18295586)},
+		{Name: ".1.3.6.1.2.1.2.2.1.6.2", Type: gosnmp.OctetString, Value: []byte{0x00, 0x17, 0x10, 0x29, 0xb9, 0x58}},
+		{Name: ".1.3.6.1.2.1.2.2.1.8.2", Type: gosnmp.Integer, Value: -1},
+		{Name: ".1.3.6.1.2.1.4.20.1.1.10.0.0.1", Type: gosnmp.IPAddress, Value: "10.0.0.1"},
+		{Name: ".1.3.6.1.2.1.4.20.1.1.10.0.0.2", Type: gosnmp.IPAddress, Value: "10.0.0.2"},
+		{Name: ".1.3.6.1.2.1.31.1.1.1.6.2", Type: gosnmp.Counter64, Value: uint64(18446744073709551615)},
+		{Name: ".1.3.6.1.2.1.1.2.0", Type: gosnmp.ObjectIdentifier, Value: ".1.3.6.1.4.1.99999"},
+	}, pdus)
+
+	_, err = ReadSnmprec(strings.NewReader("1.3.6.1.2.1.1.1.0|4:numeric|x\n"))
+	assert.ErrorContains(t, err, "line 1")
+	_, err = ReadSnmprec(strings.NewReader("1.3.6.1.2.1.1.1.0|2|abc\n"))
+	assert.Error(t, err)
+	_, err = ReadSnmprec(strings.NewReader("1.3.6.1.2.1.1.1.0\n"))
+	assert.Error(t, err)
+}
+
+func TestWriteSnmprec(t *testing.T) {
+	pdus, err := LoadWalk("../testdata/agent.walk")
+	require.NoError(t, err)
+
+	var b strings.Builder
+	require.NoError(t, WriteSnmprec(&b, pdus))
+	assert.Contains(t, b.String(), "1.3.6.1.4.1.99999.1.1.0|4|CASA \"C100G\"\n")
+	assert.Contains(t, b.String(), "1.3.6.1.4.1.99999.1.6.0|4x|6c696e65206f6e650a6c696e652074776f\n")
+
+	got, err := ReadSnmprec(strings.NewReader(b.String()))
+	require.NoError(t, err)
+	assert.Equal(t, pdus, got)
+
+	err = WriteSnmprec(&b, []gosnmp.SnmpPDU{{Name: ".1.1", Type: gosnmp.OpaqueFloat, Value: float32(1.5)}})
+	assert.ErrorContains(t, err, "unsupported type")
+}
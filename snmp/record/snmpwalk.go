@@ -0,0 +1,305 @@
+package record
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"github.com/gosnmp/gosnmp"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// oidLine matches the start of a varbind in `snmpwalk -On` output; any
+// other line continues the value of the previous one.
+var oidLine = regexp.MustCompile(`^\.?[0-9]+(\.[0-9]+)* = `)
+
+// LoadWalk reads a walk file, see ReadWalk.
+func LoadWalk(path string) ([]gosnmp.SnmpPDU, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadWalk(f)
+}
+
+// ReadWalk parses the output of net-snmp's `snmpwalk -On`, e.g.
+//
+//	.1.3.6.1.2.1.1.1.0 = STRING: "CASA C100G"
+//	.1.3.6.1.2.1.1.3.0 = Timeticks: (18295586) 2 days, 2:49:15.86
+//
+// Values are typed the way gosnmp decodes them off the wire. Lines reporting
+// noSuchObject, noSuchInstance or the end of the MIB view are skipped.
+func ReadWalk(r io.Reader) ([]gosnmp.SnmpPDU, error) {
+	type record struct {
+		line  int
+		name  string
+		value string
+	}
+
+	var records []record
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if loc := oidLine.FindStringIndex(line); loc != nil {
+			records = append(records, record{line: n, name: line[:loc[1]-3], value: line[loc[1]:]})
+			continue
+		}
+		if len(records) == 0 {
+			if strings.TrimSpace(line) == "" {
+				continue
+			}
+			return nil, fmt.Errorf("line %d: expected an oid, got %q", n, line)
+		}
+		records[len(records)-1].value += "\n" + line
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	pdus := make([]gosnmp.SnmpPDU, 0, len(records))
+	for _, rec := range records {
+		pdu, ok, err := parseWalkValue(rec.value)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s: %w", rec.line, rec.name, err)
+		}
+		if !ok {
+			continue
+		}
+		pdu.Name = "." + strings.TrimPrefix(rec.name, ".")
+		pdus = append(pdus, pdu)
+	}
+	return pdus, nil
+}
+
+// parseWalkValue parses the part after " = "; ok is false for exceptions.
+func parseWalkValue(s string) (pdu gosnmp.SnmpPDU, ok bool, err error) {
+	s = strings.TrimRight(s, "\n")
+	typ, value, found := strings.Cut(s, ": ")
+	if !found {
+		switch {
+		case s == `""`:
+			return gosnmp.SnmpPDU{Type: gosnmp.OctetString, Value: []byte{}}, true, nil
+		case s == "NULL":
+			return gosnmp.SnmpPDU{Type: gosnmp.Null}, true, nil
+		case strings.HasPrefix(s, "No Such"), strings.HasPrefix(s, "No more variables"):
+			return pdu, false, nil
+		}
+		// an empty value prints as "STRING: " with the space trimmed by editors
+		typ, value = strings.TrimSuffix(s, ":"), ""
+	}
+
+	pdu.Type = gosnmp.OctetString
+	switch typ {
+	case "STRING":
+		pdu.Value = []byte(unquote(value))
+	case "Hex-STRING":
+		pdu.Value, err = parseHex(strings.Fields(value))
+	case "BITS":
+		// "80 00 up(0)": the octets come first, then the set labels
+		var octets []string
+		for _, field := range strings.Fields(value) {
+			if len(field) != 2 || !isHex(field) {
+				break
+			}
+			octets = append(octets, field)
+		}
+		pdu.Value, err = parseHex(octets)
+	case "INTEGER":
+		pdu.Type = gosnmp.Integer
+		pdu.Value, err = strconv.Atoi(number(value))
+	case "Counter32", "Gauge32", "Unsigned32", "UInteger32":
+		pdu.Type = map[string]gosnmp.Asn1BER{
+			"Counter32":  gosnmp.Counter32,
+			"Gauge32":    gosnmp.Gauge32,
+			"Unsigned32": gosnmp.Gauge32,
+			"UInteger32": gosnmp.Uinteger32,
+		}[typ]
+		var n uint64
+		n, err = strconv.ParseUint(number(value), 10, 32)
+		pdu.Value = uint(n)
+	case "Counter64":
+		pdu.Type = gosnmp.Counter64
+		pdu.Value, err = strconv.ParseUint(number(value), 10, 64)
+	case "Timeticks":
+		pdu.Type = gosnmp.TimeTicks
+		var n uint64
+		n, err = strconv.ParseUint(number(value), 10, 32)
+		pdu.Value = uint32(n)
+	case "IpAddress":
+		pdu.Type = gosnmp.IPAddress
+		pdu.Value = strings.TrimSpace(value)
+	case "Network Address":
+		pdu.Type = gosnmp.IPAddress
+		var b []byte
+		if b, err = parseHex(strings.Split(strings.TrimSpace(value), ":")); err == nil && len(b) == 4 {
+			pdu.Value = fmt.Sprintf("%d.%d.%d.%d", b[0], b[1], b[2], b[3])
+		} else if err == nil {
+			err = fmt.Errorf("invalid network address %s", value)
+		}
+	case "OID":
+		pdu.Type = gosnmp.ObjectIdentifier
+		pdu.Value = "." + strings.TrimPrefix(strings.TrimSpace(value), ".")
+	case "Opaque":
+		pdu, err = parseOpaque(value)
+	default:
+		return pdu, false, fmt.Errorf("unsupported type %s", typ)
+	}
+	return pdu, err == nil, err
+}
+
+func parseOpaque(value string) (pdu gosnmp.SnmpPDU, err error) {
+	kind, v, _ := strings.Cut(value, ": ")
+	switch kind {
+	case "Float":
+		var f float64
+		f, err = strconv.ParseFloat(strings.TrimSpace(v), 32)
+		return gosnmp.SnmpPDU{Type: gosnmp.OpaqueFloat, Value: float32(f)}, err
+	case "Double":
+		var f float64
+		f, err = strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return gosnmp.SnmpPDU{Type: gosnmp.OpaqueDouble, Value: f}, err
+	}
+	b, err := parseHex(strings.Fields(value))
+	return gosnmp.SnmpPDU{Type: gosnmp.Opaque, Value: b}, err
+}
+
+// number extracts the number of "up(1)", "(123) 0:00:01.23" or "5 seconds".
+func number(s string) string {
+	s = strings.TrimSpace(s)
+	if i := strings.Index(s, "("); i >= 0 {
+		if j := strings.Index(s[i:], ")"); j > 0 {
+			return s[i+1 : i+j]
+		}
+	}
+	if fields := strings.Fields(s); len(fields) > 0 {
+		return fields[0]
+	}
+	return s
+}
+
+// unquote strips the quotes net-snmp puts around printable strings.
+func unquote(s string) string {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return s
+	}
+	return strings.NewReplacer(`\"`, `"`, `\\`, `\`).Replace(s[1 : len(s)-1])
+}
+
+func parseHex(fields []string) ([]byte, error) {
+	b := make([]byte, 0, len(fields))
+	for _, field := range fields {
+		octet, err := hex.DecodeString(field)
+		if err != nil || len(octet) != 1 {
+			return nil, fmt.Errorf("invalid hex octet %q", field)
+		}
+		b = append(b, octet[0])
+	}
+	return b, nil
+}
+
+func isHex(s string) bool {
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
+// WriteWalk writes pdus the way `snmpwalk -On` prints them, one varbind per
+// line; ReadWalk reads the result back to the same values.
+func WriteWalk(w io.Writer, pdus []gosnmp.SnmpPDU) error {
+	bw := bufio.NewWriter(w)
+	for _, pdu := range pdus {
+		value, err := formatWalkValue(pdu)
+		if err != nil {
+			return fmt.Errorf("%s: %w", pdu.Name, err)
+		}
+		fmt.Fprintf(bw, ".%s = %s\n", strings.TrimPrefix(pdu.Name, "."), value)
+	}
+	return bw.Flush()
+}
+
+func formatWalkValue(pdu gosnmp.SnmpPDU) (string, error) {
+	switch pdu.Type {
+	case gosnmp.OctetString, gosnmp.BitString:
+		b := toBytes(pdu.Value)
+		switch {
+		case len(b) == 0:
+			return `""`, nil
+		case isPrintable(b, true) && b[len(b)-1] != '\n':
+			return `STRING: "` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(string(b)) + `"`, nil
+		}
+		return "Hex-STRING: " + formatHex(b), nil
+	case gosnmp.Integer:
+		return fmt.Sprintf("INTEGER: %d", gosnmp.ToBigInt(pdu.Value)), nil
+	case gosnmp.Counter32:
+		return fmt.Sprintf("Counter32: %d", gosnmp.ToBigInt(pdu.Value)), nil
+	case gosnmp.Gauge32:
+		return fmt.Sprintf("Gauge32: %d", gosnmp.ToBigInt(pdu.Value)), nil
+	case gosnmp.Uinteger32:
+		return fmt.Sprintf("UInteger32: %d", gosnmp.ToBigInt(pdu.Value)), nil
+	case gosnmp.Counter64:
+		return fmt.Sprintf("Counter64: %d", gosnmp.ToBigInt(pdu.Value)), nil
+	case gosnmp.TimeTicks:
+		return "Timeticks: " + formatTicks(gosnmp.ToBigInt(pdu.Value).Uint64()), nil
+	case gosnmp.IPAddress:
+		return fmt.Sprintf("IpAddress: %v", pdu.Value), nil
+	case gosnmp.ObjectIdentifier:
+		return fmt.Sprintf("OID: .%s", strings.TrimPrefix(fmt.Sprint(pdu.Value), ".")), nil
+	case gosnmp.Opaque:
+		return "Opaque: " + formatHex(toBytes(pdu.Value)), nil
+	case gosnmp.OpaqueFloat:
+		return fmt.Sprintf("Opaque: Float: %v", pdu.Value), nil
+	case gosnmp.OpaqueDouble:
+		return fmt.Sprintf("Opaque: Double: %v", pdu.Value), nil
+	case gosnmp.Null:
+		return "NULL", nil
+	}
+	return "", fmt.Errorf("unsupported type %s", pdu.Type)
+}
+
+// formatTicks renders hundredths of a second like net-snmp:
+// "(18295586) 2 days, 2:49:15.86".
+func formatTicks(ticks uint64) string {
+	days := ticks / 8640000
+	rest := ticks % 8640000
+	clock := fmt.Sprintf("%d:%02d:%02d.%02d", rest/360000, rest/6000%60, rest/100%60, rest%100)
+	switch days {
+	case 0:
+		return fmt.Sprintf("(%d) %s", ticks, clock)
+	case 1:
+		return fmt.Sprintf("(%d) 1 day, %s", ticks, clock)
+	}
+	return fmt.Sprintf("(%d) %d days, %s", ticks, days, clock)
+}
+
+func formatHex(b []byte) string {
+	fields := make([]string, len(b))
+	for i, octet := range b {
+		fields[i] = fmt.Sprintf("%02X", octet)
+	}
+	return strings.Join(fields, " ")
+}
+
+// isPrintable reports whether b is ASCII text; newlines are allowed where
+// the format can carry them.
+func isPrintable(b []byte, newlines bool) bool {
+	for _, c := range b {
+		if (c < 0x20 || c > 0x7e) && !(newlines && c == '\n') {
+			return false
+		}
+	}
+	return true
+}
+
+func toBytes(v interface{}) []byte {
+	switch v := v.(type) {
+	case []byte:
+		return v
+	case string:
+		return []byte(v)
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package record
+
+import (
+	"github.com/gosnmp/gosnmp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func TestReadWalk(t *testing.T) {
+	pdus, err := LoadWalk("../testdata/agent.walk")
+	require.NoError(t, err)
+	require.Len(t, pdus, 16)
+
+	assert.Equal(t, gosnmp.SnmpPDU{Name: ".1.3.6.1.4.1.99999.1.1.0", Type: gosnmp.OctetString, Value: []byte(`CASA "C100G"`)}, pdus[0])
+	assert.Equal(t, gosnmp.SnmpPDU{Name: ".1.3.6.1.4.1.99999.1.2.0", Type: gosnmp.TimeTicks, Value: uint32(18295586)}, pdus[1])
+	assert.Equal(t, []byte{0x00, 0x17, 0x10, 0x2b, 0x69, 0x58}, pdus[3].Value)
+	assert.Equal(t, []byte("line one\nline two"), pdus[5].Value)
+	assert.Equal(t, gosnmp.SnmpPDU{Name: ".1.3.6.1.4.1.99999.2.1.4.0.23.16.43.105.88.10.0.0.1", Type: gosnmp.Counter64, Value: uint64(1000)}, pdus[8])
+	assert.Equal(t, 2, pdus[11].Value)
+	assert.Equal(t, gosnmp.SnmpPDU{Name: ".1.3.6.1.4.1.99999.3.1.5.1.4.10.0.0.1", Type: gosnmp.ObjectIdentifier, Value: ".1.3.6.1.6.1.1"}, pdus[14])
+}
+
+func TestReadWalk_Types(t *testing.T) {
+	pdus, err := ReadWalk(strings.NewReader(`
+.1.1 = ""
+.1.2 = Gauge32: 100
+.1.3 = Counter32: 7
+.1.4 = IpAddress: 10.0.0.1
+.1.5 = Network Address: 0A:00:00:02
+.1.6 = BITS: 80 40 up(0) down(9)
+.1.7 = Opaque: Float: 1.5
+.1.8 = INTEGER: -3
+.1.9 = No Such Instance currently exists at this OID
+`))
+	require.NoError(t, err)
+	require.Len(t, pdus, 8)
+
+	assert.Equal(t, []byte{}, pdus[0].Value)
+	assert.Equal(t, gosnmp.SnmpPDU{Name: ".1.2", Type: gosnmp.Gauge32, Value: uint(100)}, pdus[1])
+	assert.Equal(t, gosnmp.SnmpPDU{Name: ".1.3", Type: gosnmp.Counter32, Value: uint(7)}, pdus[2])
+	assert.Equal(t, "10.0.0.1", pdus[3].Value)
+	assert.Equal(t, "10.0.0.2", pdus[4].Value)
+	assert.Equal(t, []byte{0x80, 0x40}, pdus[5].Value)
+	assert.Equal(t, gosnmp.SnmpPDU{Name: ".1.7", Type: gosnmp.OpaqueFloat, Value: float32(1.5)}, pdus[6])
+	assert.Equal(t, -3, pdus[7].Value)
+
+	_, err = ReadWalk(strings.NewReader(".1.1 = Widget: 3\n"))
+	assert.ErrorContains(t, err, "line 1")
+	_, err = ReadWalk(strings.NewReader("garbage\n"))
+	assert.Error(t, err)
+}
+
+func TestWriteWalk(t *testing.T) {
+	pdus, err := LoadWalk("../testdata/agent.walk")
+	require.NoError(t, err)
+	pdus = append(pdus,
+		gosnmp.SnmpPDU{Name: ".1.1", Type: gosnmp.Gauge32, Value: uint(100)},
+		gosnmp.SnmpPDU{Name: ".1.2", Type: gosnmp.IPAddress, Value: "10.0.0.1"},
+		gosnmp.SnmpPDU{Name: ".1.3", Type: gosnmp.OctetString, Value: []byte{}},
+		gosnmp.SnmpPDU{Name: ".1.4", Type: gosnmp.OctetString, Value: []byte("trailing\n")},
+	)
+
+	var b strings.Builder
+	require.NoError(t, WriteWalk(&b, pdus))
+	assert.Contains(t, b.String(), `.1.3.6.1.4.1.99999.1.1.0 = STRING: "CASA \"C100G\""`+"\n")
+	assert.Contains(t, b.String(), ".1.3.6.1.4.1.99999.1.2.0 = Timeticks: (18295586) 2 days, 2:49:15.86\n")
+
+	got, err := ReadWalk(strings.NewReader(b.String()))
+	require.NoError(t, err)
+	assert.Equal(t, pdus, got)
+}
@@ -0,0 +1,152 @@
+package snmp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"snmp-test/snmp/parse"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// RowStatus values from SNMPv2-TC.
+type RowStatus int
+
+const (
+	RowStatusActive        RowStatus = 1
+	RowStatusNotInService  RowStatus = 2
+	RowStatusNotReady      RowStatus = 3
+	RowStatusCreateAndGo   RowStatus = 4
+	RowStatusCreateAndWait RowStatus = 5
+	RowStatusDestroy       RowStatus = 6
+)
+
+const rowStatusType = "RowStatus"
+
+// ErrNoRowStatus is returned for tables without a RowStatus column.
+var ErrNoRowStatus = errors.New("table has no RowStatus column")
+
+// rowEntry is a conceptual row resolved from the MIB tree.
+type rowEntry struct {
+	entry     *parse.MibObject
+	columns   map[string]*parse.MibObject
+	rowStatus *parse.MibObject
+}
+
+// CreateRow creates a row with createAndGo, setting values and the
+// RowStatus column in one request.
+func (s *snmp) CreateRow(ctx context.Context, table, index string, values map[string]string) error {
+	row, err := resolveRowEntry(table)
+	if err != nil {
+		return err
+	}
+	vars, err := row.setVars(index, values)
+	if err != nil {
+		return err
+	}
+	return s.set(ctx, append([]setVar{row.statusVar(index, RowStatusCreateAndGo)}, vars...))
+}
+
+// CreateRowAndWait creates a row with createAndWait, then sets values and
+// finally activates it. If setting values fails the row is destroyed again.
+func (s *snmp) CreateRowAndWait(ctx context.Context, table, index string, values map[string]string) error {
+	row, err := resolveRowEntry(table)
+	if err != nil {
+		return err
+	}
+	vars, err := row.setVars(index, values)
+	if err != nil {
+		return err
+	}
+
+	if err = s.set(ctx, []setVar{row.statusVar(index, RowStatusCreateAndWait)}); err != nil {
+		return err
+	}
+	if err = s.set(ctx, vars); err != nil {
+		_ = s.set(ctx, []setVar{row.statusVar(index, RowStatusDestroy)})
+		return err
+	}
+	return s.set(ctx, []setVar{row.statusVar(index, RowStatusActive)})
+}
+
+// DestroyRow deletes a row by setting its RowStatus to destroy.
+func (s *snmp) DestroyRow(ctx context.Context, table, index string) error {
+	row, err := resolveRowEntry(table)
+	if err != nil {
+		return err
+	}
+	return s.set(ctx, []setVar{row.statusVar(index, RowStatusDestroy)})
+}
+
+// resolveRowEntry accepts either the table or its entry name.
+func resolveRowEntry(name string) (*rowEntry, error) {
+	mib, _, err := resolveObject(name)
+	if err != nil {
+		return nil, err
+	}
+	if mib.Kind == "Table" {
+		if rows := parse.Children(mib.OID); len(rows) == 1 {
+			mib = rows[0]
+		}
+	}
+	if mib.Kind != "Row" {
+		return nil, fmt.Errorf("%s is not a table entry", name)
+	}
+
+	row := &rowEntry{entry: mib, columns: make(map[string]*parse.MibObject)}
+	for _, column := range parse.Children(mib.OID) {
+		row.columns[column.Name] = column
+		if column.Type == rowStatusType {
+			row.rowStatus = column
+		}
+	}
+	if row.rowStatus == nil {
+		return nil, fmt.Errorf("%w: %s", ErrNoRowStatus, mib.Name)
+	}
+	return row, nil
+}
+
+func (r *rowEntry) statusVar(index string, status RowStatus) setVar {
+	return setVar{mib: r.rowStatus, index: index, value: strconv.Itoa(int(status))}
+}
+
+// setVars validates values against the entry's columns and checks that all
+// mandatory read-create columns are supplied.
+func (r *rowEntry) setVars(index string, values map[string]string) ([]setVar, error) {
+	if index == "" {
+		return nil, errors.New("row index is required")
+	}
+
+	var missing []string
+	for name, column := range r.columns {
+		if column.Create && !column.HasDefault && column != r.rowStatus {
+			if _, ok := values[name]; !ok {
+				missing = append(missing, name)
+			}
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return nil, fmt.Errorf("missing mandatory columns for %s: %s", r.entry.Name, strings.Join(missing, ","))
+	}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	vars := make([]setVar, 0, len(names))
+	for _, name := range names {
+		column, ok := r.columns[name]
+		if !ok {
+			return nil, fmt.Errorf("%s is not a column of %s", name, r.entry.Name)
+		}
+		if column == r.rowStatus {
+			return nil, fmt.Errorf("%s is managed by the row api", name)
+		}
+		vars = append(vars, setVar{mib: column, index: index, value: values[name]})
+	}
+	return vars, nil
+}
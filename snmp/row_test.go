@@ -0,0 +1,33 @@
+package snmp
+
+import (
+	"github.com/stretchr/testify/assert"
+	"snmp-test/snmp/parse"
+	"testing"
+)
+
+func TestRowEntry_SetVars(t *testing.T) {
+	status := &parse.MibObject{Name: "nodeRowStatus", Type: rowStatusType, Create: true}
+	row := &rowEntry{
+		entry: &parse.MibObject{Name: "nodeEntry"},
+		columns: map[string]*parse.MibObject{
+			"nodeMac":       {Name: "nodeMac", Create: true},
+			"nodeDescr":     {Name: "nodeDescr", Create: true, HasDefault: true},
+			"nodeOper":      {Name: "nodeOper"},
+			"nodeRowStatus": status,
+		},
+		rowStatus: status,
+	}
+
+	_, err := row.setVars("5", map[string]string{"nodeDescr": "a"})
+	assert.ErrorContains(t, err, "nodeMac")
+
+	_, err = row.setVars("5", map[string]string{"nodeMac": "00:17:10:2B:69:58", "bogus": "1"})
+	assert.ErrorContains(t, err, "bogus")
+
+	vars, err := row.setVars("5", map[string]string{"nodeMac": "00:17:10:2B:69:58", "nodeDescr": "a"})
+	assert.NoError(t, err)
+	assert.Len(t, vars, 2)
+	assert.Equal(t, "nodeDescr", vars[0].mib.Name)
+	assert.Equal(t, "4", row.statusVar("5", RowStatusCreateAndGo).value)
+}
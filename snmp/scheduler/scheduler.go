@@ -0,0 +1,234 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"snmp-test/snmp/poller"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// OverrunPolicy decides what happens when a run is due while the previous
+// run of the same job is still going.
+type OverrunPolicy int
+
+const (
+	// Skip drops the due run.
+	Skip OverrunPolicy = iota
+	// Queue runs it once the previous run finished, keeping up to
+	// Job.MaxQueued runs waiting.
+	Queue
+)
+
+// Job is a set of queries run against a set of targets every Interval.
+type Job struct {
+	Name     string
+	Targets  []poller.Target
+	Queries  []poller.Query
+	Interval time.Duration
+	// Jitter spreads jobs with the same interval: each job runs at a random
+	// but fixed offset in [0, Jitter) after every interval boundary.
+	Jitter  time.Duration
+	Overrun OverrunPolicy
+	// MaxQueued bounds the waiting runs under Queue, default 1.
+	MaxQueued int
+}
+
+// Run is the outcome of one run of a job.
+type Run struct {
+	Job string
+	// Scheduled is the interval boundary the run belongs to, the timestamp
+	// to store the results under.
+	Scheduled time.Time
+	Started   time.Time
+	Finished  time.Time
+	Results   []poller.Result
+}
+
+// Sink receives every finished run.
+type Sink interface {
+	Write(ctx context.Context, run Run)
+}
+
+// SinkFunc adapts a function to Sink.
+type SinkFunc func(ctx context.Context, run Run)
+
+func (f SinkFunc) Write(ctx context.Context, run Run) {
+	f(ctx, run)
+}
+
+// Option configures a Scheduler.
+type Option func(s *Scheduler)
+
+// WithClock replaces the wall clock.
+func WithClock(clock Clock) Option {
+	return func(s *Scheduler) {
+		s.clock = clock
+	}
+}
+
+// WithSink adds a sink, sinks are called in the order they were added.
+func WithSink(sink Sink) Option {
+	return func(s *Scheduler) {
+		s.sinks = append(s.sinks, sink)
+	}
+}
+
+// WithRand sets the source of the jitter offsets.
+func WithRand(r *rand.Rand) Option {
+	return func(s *Scheduler) {
+		s.rand = r
+	}
+}
+
+// Scheduler runs jobs at fixed intervals through a poller.Poller.
+type Scheduler struct {
+	poller *poller.Poller
+	clock  Clock
+	sinks  []Sink
+	rand   *rand.Rand
+
+	mu      sync.Mutex
+	jobs    []Job
+	running bool
+}
+
+// New returns a scheduler polling through p.
+func New(p *poller.Poller, opts ...Option) *Scheduler {
+	s := &Scheduler{poller: p, clock: realClock{}}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.rand == nil {
+		s.rand = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	return s
+}
+
+// Add registers a job. Jobs must be added before Run.
+func (s *Scheduler) Add(job Job) error {
+	if job.Name == "" {
+		return errors.New("job name is empty")
+	}
+	if job.Interval <= 0 {
+		return fmt.Errorf("job %s: interval must be positive", job.Name)
+	}
+	if job.Jitter < 0 || job.Jitter >= job.Interval {
+		return fmt.Errorf("job %s: jitter must be in [0, interval)", job.Name)
+	}
+	if job.MaxQueued <= 0 {
+		job.MaxQueued = 1
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.running {
+		return fmt.Errorf("job %s: scheduler is already running", job.Name)
+	}
+	for _, j := range s.jobs {
+		if j.Name == job.Name {
+			return fmt.Errorf("job %s already exists", job.Name)
+		}
+	}
+	s.jobs = append(s.jobs, job)
+	return nil
+}
+
+// Run schedules the jobs until ctx is done and then waits for the runs in
+// progress to finish.
+func (s *Scheduler) Run(ctx context.Context) error {
+	s.mu.Lock()
+	if s.running {
+		s.mu.Unlock()
+		return errors.New("scheduler is already running")
+	}
+	s.running = true
+	jobs := s.jobs
+	offsets := make([]time.Duration, len(jobs))
+	for i, job := range jobs {
+		if job.Jitter > 0 {
+			offsets[i] = time.Duration(s.rand.Int63n(int64(job.Jitter)))
+		}
+	}
+	s.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for i, job := range jobs {
+		size := 1
+		if job.Overrun == Queue {
+			size = job.MaxQueued
+		}
+		due := make(chan time.Time, size)
+		busy := &atomic.Bool{}
+
+		wg.Add(2)
+		go func(job Job, offset time.Duration) {
+			defer wg.Done()
+			defer close(due)
+			s.tick(ctx, job, offset, busy, due)
+		}(job, offsets[i])
+		go func(job Job) {
+			defer wg.Done()
+			for scheduled := range due {
+				s.run(ctx, job, scheduled, busy)
+			}
+		}(job)
+	}
+	wg.Wait()
+
+	s.mu.Lock()
+	s.running = false
+	s.mu.Unlock()
+	return ctx.Err()
+}
+
+// tick sends every interval boundary of job to due once the boundary plus
+// offset has passed, unless the overrun policy drops it.
+func (s *Scheduler) tick(ctx context.Context, job Job, offset time.Duration, busy *atomic.Bool, due chan<- time.Time) {
+	next := s.clock.Now().Truncate(job.Interval).Add(job.Interval)
+	for {
+		wait := next.Add(offset).Sub(s.clock.Now())
+		select {
+		case <-s.clock.After(wait):
+		case <-ctx.Done():
+			return
+		}
+
+		if job.Overrun == Skip && busy.Load() {
+			slog.Warn("Skipping overrunning job run", "job", job.Name, "scheduled", next)
+		} else {
+			select {
+			case due <- next:
+			default:
+				slog.Warn("Dropping job run, queue is full", "job", job.Name, "scheduled", next)
+			}
+		}
+
+		next = next.Add(job.Interval)
+		if now := s.clock.Now(); !next.Add(offset).After(now) {
+			// the clock jumped or we fell behind, resume at the next boundary
+			next = now.Truncate(job.Interval).Add(job.Interval)
+		}
+	}
+}
+
+// run polls job and hands the results to the sinks. The job counts as busy
+// while polling only, a slow sink does not cause runs to be skipped.
+func (s *Scheduler) run(ctx context.Context, job Job, scheduled time.Time, busy *atomic.Bool) {
+	if ctx.Err() != nil {
+		return
+	}
+	busy.Store(true)
+	run := Run{Job: job.Name, Scheduled: scheduled, Started: s.clock.Now()}
+	run.Results = s.poller.PollAll(ctx, job.Targets, job.Queries)
+	run.Finished = s.clock.Now()
+	busy.Store(false)
+
+	for _, sink := range s.sinks {
+		sink.Write(ctx, run)
+	}
+}
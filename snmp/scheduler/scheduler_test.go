@@ -0,0 +1,219 @@
+package scheduler
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"math/rand"
+	"snmp-test/snmp"
+	"snmp-test/snmp/poller"
+	"snmp-test/snmp/scraper"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock only moves when Advance is called.
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []waiter
+}
+
+type waiter struct {
+	at time.Time
+	ch chan time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- c.now
+		return ch
+	}
+	c.waiters = append(c.waiters, waiter{at: c.now.Add(d), ch: ch})
+	return ch
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	pending := c.waiters[:0]
+	for _, w := range c.waiters {
+		if w.at.After(c.now) {
+			pending = append(pending, w)
+		} else {
+			w.ch <- c.now
+		}
+	}
+	c.waiters = pending
+}
+
+// blockUntil waits for n goroutines to sleep on the clock.
+func (c *fakeClock) blockUntil(t *testing.T, n int) {
+	require.Eventually(t, func() bool {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return len(c.waiters) >= n
+	}, time.Second, time.Millisecond)
+}
+
+type fakeClient struct {
+	snmp.SnmpClient
+	gate chan struct{}
+}
+
+func (f *fakeClient) GetValues(ctx context.Context, names ...string) (map[string]*snmp.Value, error) {
+	if f.gate != nil {
+		<-f.gate
+	}
+	return map[string]*snmp.Value{names[0]: {String: "1"}}, nil
+}
+
+func (f *fakeClient) Close() error {
+	return nil
+}
+
+var t0 = time.Date(2024, 5, 7, 10, 0, 5, 0, time.UTC)
+
+func newTestScheduler(gate chan struct{}) (*Scheduler, *fakeClock, chan Run) {
+	clock := &fakeClock{now: t0}
+	runs := make(chan Run, 10)
+	p := poller.New(poller.Config{NewClient: func(*scraper.ClientConfig) snmp.SnmpClient {
+		return &fakeClient{gate: gate}
+	}})
+	s := New(p,
+		WithClock(clock),
+		WithRand(rand.New(rand.NewSource(1))),
+		WithSink(SinkFunc(func(_ context.Context, run Run) { runs <- run })),
+	)
+	return s, clock, runs
+}
+
+func job(name string, interval time.Duration) Job {
+	return Job{
+		Name:     name,
+		Targets:  []poller.Target{{Config: &scraper.ClientConfig{Target: "r1"}}},
+		Queries:  []poller.Query{poller.Names("sysUpTime")},
+		Interval: interval,
+	}
+}
+
+func receive(t *testing.T, runs chan Run) Run {
+	select {
+	case run := <-runs:
+		return run
+	case <-time.After(time.Second):
+		t.Fatal("no run delivered")
+		return Run{}
+	}
+}
+
+func start(s *Scheduler) (context.CancelFunc, chan error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- s.Run(ctx) }()
+	return cancel, done
+}
+
+func TestScheduler_Aligned(t *testing.T) {
+	s, clock, runs := newTestScheduler(nil)
+	require.NoError(t, s.Add(job("uptime", 10*time.Second)))
+	cancel, done := start(s)
+
+	for i := 1; i <= 3; i++ {
+		clock.blockUntil(t, 1)
+		clock.Advance(10 * time.Second)
+
+		run := receive(t, runs)
+		assert.Equal(t, "uptime", run.Job)
+		assert.Equal(t, time.Date(2024, 5, 7, 10, 0, i*10, 0, time.UTC), run.Scheduled)
+		assert.Len(t, run.Results, 1)
+		assert.NoError(t, run.Results[0].Err)
+	}
+
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+}
+
+func TestScheduler_Jitter(t *testing.T) {
+	s, clock, runs := newTestScheduler(nil)
+	j := job("walk", time.Minute)
+	j.Jitter = 30 * time.Second
+	require.NoError(t, s.Add(j))
+	cancel, done := start(s)
+	defer func() { cancel(); <-done }()
+
+	// the offset New draws from the same seed
+	offset := time.Duration(rand.New(rand.NewSource(1)).Int63n(int64(30 * time.Second)))
+	require.Greater(t, offset, time.Duration(0))
+
+	clock.blockUntil(t, 1)
+	clock.Advance(55*time.Second + offset - time.Nanosecond) // just before 10:01:00 + offset
+	clock.blockUntil(t, 1)
+	assert.Empty(t, runs)
+
+	clock.Advance(time.Nanosecond)
+	run := receive(t, runs)
+	assert.Equal(t, time.Date(2024, 5, 7, 10, 1, 0, 0, time.UTC), run.Scheduled)
+	assert.Equal(t, offset, run.Started.Sub(run.Scheduled))
+}
+
+func TestScheduler_Overrun(t *testing.T) {
+	for _, tc := range []struct {
+		policy OverrunPolicy
+		want   []int
+	}{
+		{Skip, []int{10, 30}},
+		{Queue, []int{10, 20, 30}},
+	} {
+		gate := make(chan struct{})
+		s, clock, runs := newTestScheduler(gate)
+		j := job("slow", 10*time.Second)
+		j.Overrun = tc.policy
+		require.NoError(t, s.Add(j))
+		cancel, done := start(s)
+
+		clock.blockUntil(t, 1)
+		clock.Advance(10 * time.Second) // 10:00:10 starts and hangs
+		clock.blockUntil(t, 1)
+		clock.Advance(10 * time.Second) // 10:00:20 is due while it runs
+		clock.blockUntil(t, 1)
+		gate <- struct{}{}
+		receive(t, runs)
+		if tc.policy == Queue {
+			gate <- struct{}{}
+			receive(t, runs)
+		}
+
+		clock.Advance(10 * time.Second) // 10:00:30
+		gate <- struct{}{}
+		last := receive(t, runs)
+		assert.Equal(t, time.Date(2024, 5, 7, 10, 0, tc.want[len(tc.want)-1], 0, time.UTC), last.Scheduled)
+
+		cancel()
+		close(gate)
+		<-done
+		assert.Empty(t, runs, "policy %d", tc.policy)
+	}
+}
+
+func TestScheduler_Add(t *testing.T) {
+	s, _, _ := newTestScheduler(nil)
+	assert.NoError(t, s.Add(job("a", time.Second)))
+	assert.Error(t, s.Add(job("a", time.Second)))
+	assert.Error(t, s.Add(job("b", 0)))
+
+	j := job("c", time.Second)
+	j.Jitter = time.Second
+	assert.Error(t, s.Add(j))
+}
@@ -2,6 +2,7 @@ package scraper
 
 import (
 	"context"
+	"net"
 	"time"
 )
 
@@ -11,9 +12,41 @@ const (
 	Version3   = "snmpv3"
 )
 
+const (
+	TransportUDP  = "udp"
+	TransportUDP4 = "udp4"
+	TransportUDP6 = "udp6"
+	TransportTCP  = "tcp"
+	TransportTCP4 = "tcp4"
+	TransportTCP6 = "tcp6"
+	// a stream unix socket, e.g. of a relay to an agent out of reach
+	TransportUnix = "unix"
+)
+
+const (
+	AddressFamilyIPv4 = "ipv4"
+	AddressFamilyIPv6 = "ipv6"
+)
+
 type ClientConfig struct {
-	Target  string
-	Port    uint16
+	// ip address, hostname, or socket path for the unix transport
+	Target string
+	Port   uint16
+	// optional value: udp|udp4|udp6|tcp|tcp4|tcp6|unix, default udp
+	Transport string
+	// optional, opens the connection to the agent in place of gosnmp, e.g.
+	// through a proxy. It gets the transport and Target:Port, or the socket
+	// path for unix; hostnames are passed unresolved and LocalAddr is left
+	// to it.
+	Dialer func(ctx context.Context, network, address string) (net.Conn, error)
+	// preferred family when a hostname resolves to both, optional value: ""|ipv4|ipv6
+	AddressFamily string
+	// re-resolve a hostname target every time a connection is made instead of
+	// reusing the first resolved address
+	ResolveOnReconnect bool
+	// local source address ("ip" or "ip:port") for multi-homed pollers
+	LocalAddr string
+
 	Version string
 	// version 1 && 2
 	Community string
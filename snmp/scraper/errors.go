@@ -0,0 +1,40 @@
+package scraper
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// TimeoutError is returned when the target did not answer within
+// Timeout after all Retries.
+type TimeoutError struct {
+	Target   string
+	Op       string
+	Duration time.Duration
+	Err      error
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("timeout after %s %s target %s: %v", e.Duration, e.Op, e.Target, e.Err)
+}
+
+func (e *TimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// Timeout implements net.Error.
+func (e *TimeoutError) Timeout() bool {
+	return true
+}
+
+func isTimeout(err error) bool {
+	var ne net.Error
+	if errors.As(err, &ne) && ne.Timeout() {
+		return true
+	}
+	// gosnmp reports exhausted retries as a plain "request timeout" error
+	return strings.Contains(err.Error(), "timeout")
+}
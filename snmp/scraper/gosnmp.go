@@ -7,16 +7,26 @@ import (
 	"github.com/gosnmp/gosnmp"
 	"log/slog"
 	"net"
+	"strconv"
 	"strings"
 	"time"
 )
 
+// SNMPScraper is what the snmp client needs of a backend. Requests honor
+// the context set with WithContext.
 type SNMPScraper interface {
 	Connect() error
 	Close() error
 	SetOptions(...func(snmp *gosnmp.GoSNMP))
 	Get([]string) (*gosnmp.SnmpPacket, error)
+	GetNext([]string) (*gosnmp.SnmpPacket, error)
+	GetBulk(oids []string, nonRepeaters uint8, maxRepetitions uint32) (*gosnmp.SnmpPacket, error)
+	// WalkAll returns the subtree below oid, Walk hands it to fn one
+	// instance at a time and stops at the first error fn returns. Both use
+	// GetBulk where the version allows.
 	WalkAll(string) ([]gosnmp.SnmpPDU, error)
+	Walk(oid string, fn gosnmp.WalkFunc) error
+	Set([]gosnmp.SnmpPDU) (*gosnmp.SnmpPacket, error)
 }
 
 var _ SNMPScraper = (*GoSNMPWrapper)(nil)
@@ -32,20 +42,45 @@ func NewGoSNMP(config *ClientConfig) (*GoSNMPWrapper, error) {
 		Context:            config.Context,
 	}
 
-	ip := net.ParseIP(config.Target)
-	if ip == nil {
-		return nil, fmt.Errorf("invalid target: %s", config.Target)
+	if config.Target == "" {
+		return nil, errors.New("invalid target: target is empty")
 	}
-	gs.Target = ip.String()
 
-	transport := "udp"
-	if ip.To4() != nil {
-		transport = "udp4"
-	} else {
-		transport = "udp6"
+	network := strings.ToLower(config.Transport)
+	switch network {
+	case "":
+		network = TransportUDP
+	case TransportUDP, TransportUDP4, TransportUDP6, TransportTCP, TransportTCP4, TransportTCP6, TransportUnix:
+	default:
+		return nil, fmt.Errorf("invalid transport %s, support (udp|udp4|udp6|tcp|tcp4|tcp6|unix)", config.Transport)
 	}
-	gs.Transport = transport
+
+	switch strings.ToLower(config.AddressFamily) {
+	case "", AddressFamilyIPv4, AddressFamilyIPv6:
+	default:
+		return nil, fmt.Errorf("invalid addressFamily %s, support (\"\"|ipv4|ipv6)", config.AddressFamily)
+	}
+
+	gs.Target = config.Target
+	gs.Transport = network
 	gs.Port = config.Port
+	if config.LocalAddr != "" {
+		gs.LocalAddr = config.LocalAddr
+		if _, _, err := net.SplitHostPort(config.LocalAddr); err != nil {
+			gs.LocalAddr = net.JoinHostPort(config.LocalAddr, "0")
+		}
+	}
+
+	dial := config.Dialer
+	if dial == nil && network == TransportUnix {
+		dial = (&net.Dialer{Timeout: config.Timeout}).DialContext
+	}
+
+	// literal addresses are settled here, hostnames are resolved on Connect
+	if ip := net.ParseIP(config.Target); ip != nil && dial == nil {
+		gs.Target = ip.String()
+		gs.Transport = transportFor(network, ip)
+	}
 
 	switch config.Version {
 	case Version3:
@@ -137,26 +172,143 @@ func NewGoSNMP(config *ClientConfig) (*GoSNMPWrapper, error) {
 		}
 	}
 
-	return &GoSNMPWrapper{c: gs}, nil
+	return &GoSNMPWrapper{c: gs, network: network, family: strings.ToLower(config.AddressFamily), dial: dial}, nil
+}
+
+// WithContext returns an option that makes subsequent requests honor ctx's
+// deadline and cancellation instead of ClientConfig.Context.
+func WithContext(ctx context.Context) func(snmp *gosnmp.GoSNMP) {
+	return func(snmp *gosnmp.GoSNMP) {
+		if ctx != nil {
+			snmp.Context = ctx
+		}
+	}
 }
 
 // GoSNMPWrapper implement SNMPScraper
 type GoSNMPWrapper struct {
 	c *gosnmp.GoSNMP
+	// network is the configured transport before the target is resolved.
+	network string
+	family  string
+	// dial opens the connection for unix targets and ClientConfig.Dialer.
+	dial func(ctx context.Context, network, address string) (net.Conn, error)
 }
 
 func (gs *GoSNMPWrapper) Connect() error {
 	st := time.Now()
+
+	var err error
+	if gs.dial != nil {
+		err = gs.connectDial()
+	} else if err = gs.resolve(); err == nil {
+		err = gs.c.Connect()
+	}
+	if err != nil {
+		return gs.wrapError(err, "connecting to", st)
+	}
+	return nil
+}
+
+// placeholderPort is where the loopback socket of connectDial points; a udp
+// connect sends nothing, nothing needs to listen there.
+const placeholderPort = 9
+
+// connectDial sets gosnmp's Conn to a connection from gs.dial. gosnmp sets
+// up its request ids and receive buffer only in Connect, which opens a
+// socket of its own, so Connect runs against a loopback udp address first
+// and its socket is replaced. The gosnmp transport stays udp4 on purpose:
+// gosnmp redials tcp by itself on EOF, bypassing gs.dial; the error goes to
+// the caller instead, and a session client reconnects through here.
+func (gs *GoSNMPWrapper) connectDial() error {
+	target, port, localAddr := gs.c.Target, gs.c.Port, gs.c.LocalAddr
+	address := target
+	if gs.network != TransportUnix {
+		address = net.JoinHostPort(target, strconv.Itoa(int(port)))
+	}
+
+	gs.c.Target, gs.c.Port, gs.c.LocalAddr, gs.c.Transport = "127.0.0.1", placeholderPort, "", TransportUDP4
 	err := gs.c.Connect()
+	gs.c.Target, gs.c.Port, gs.c.LocalAddr = target, port, localAddr
+	if err != nil {
+		return err
+	}
+	_ = gs.c.Conn.Close()
+
+	ctx := gs.c.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	conn, err := gs.dial(ctx, gs.network, address)
+	if err != nil {
+		return err
+	}
+	gs.c.Conn = conn
+	return nil
+}
+
+// Target returns the address in use; after Connect this is the resolved IP
+// for hostname targets.
+func (gs *GoSNMPWrapper) Target() string {
+	return gs.c.Target
+}
+
+// resolve looks up a hostname target and picks an address honoring the
+// transport suffix and the address family preference.
+func (gs *GoSNMPWrapper) resolve() error {
+	if net.ParseIP(gs.c.Target) != nil {
+		return nil
+	}
+
+	ctx := gs.c.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, gs.c.Target)
 	if err != nil {
-		if err == context.Canceled {
-			return fmt.Errorf("snmp connect cancelled after %s connecting to target %s", time.Since(st), gs.c.Target)
+		return err
+	}
+
+	// udp4/tcp6 etc. force a family, AddressFamily only states a preference
+	want, strict := gs.family, false
+	switch {
+	case strings.HasSuffix(gs.network, "4"):
+		want, strict = AddressFamilyIPv4, true
+	case strings.HasSuffix(gs.network, "6"):
+		want, strict = AddressFamilyIPv6, true
+	}
+
+	var picked net.IP
+	for _, addr := range addrs {
+		isV4 := addr.IP.To4() != nil
+		if want == "" || (want == AddressFamilyIPv4) == isV4 {
+			picked = addr.IP
+			break
 		}
-		return fmt.Errorf("error connecting to target %s: %s", gs.c.Target, err)
 	}
+	if picked == nil && !strict && len(addrs) > 0 {
+		// a preference only, fall back to whatever the name resolves to
+		picked = addrs[0].IP
+	}
+	if picked == nil {
+		return fmt.Errorf("no %s address found for %s", want, gs.c.Target)
+	}
+
+	slog.Debug("Resolved target", "host", gs.c.Target, "ip", picked)
+	gs.c.Target = picked.String()
+	gs.c.Transport = transportFor(gs.network, picked)
 	return nil
 }
 
+// transportFor narrows udp/tcp to the ip version of ip.
+func transportFor(network string, ip net.IP) string {
+	base := strings.TrimRight(network, "46")
+	if ip.To4() != nil {
+		return base + "4"
+	}
+	return base + "6"
+}
+
 func (gs *GoSNMPWrapper) Close() error {
 	return gs.c.Conn.Close()
 }
@@ -173,11 +325,7 @@ func (gs *GoSNMPWrapper) Get(oids []string) (results *gosnmp.SnmpPacket, err err
 
 	results, err = gs.c.Get(oids)
 	if err != nil {
-		if err == context.Canceled {
-			err = fmt.Errorf("snmp connect cancelled after %s connecting to target %s", time.Since(st), gs.c.Target)
-		} else {
-			err = fmt.Errorf("error getting to target %s: %s", gs.c.Target, err)
-		}
+		err = gs.wrapError(err, "getting from", st)
 	}
 
 	slog.Debug("Get of OIDs completed", "oids", oids, "duration", time.Since(st))
@@ -194,14 +342,90 @@ func (gs *GoSNMPWrapper) WalkAll(oid string) (results []gosnmp.SnmpPDU, err erro
 		results, err = gs.c.BulkWalkAll(oid)
 	}
 	if err != nil {
-		if err == context.Canceled {
-			err = fmt.Errorf("scrape canceled after %s walking target %s", time.Since(st), gs.c.Target)
-		} else {
-			err = fmt.Errorf("error walking target %s: %s", gs.c.Target, err)
-		}
+		err = gs.wrapError(err, "walking", st)
 		return
 	}
 
 	slog.Debug("Walk of subtree completed", "oid", oid, "duration", time.Since(st))
 	return
 }
+
+func (gs *GoSNMPWrapper) GetNext(oids []string) (results *gosnmp.SnmpPacket, err error) {
+	slog.Debug("Getting next OIDS", "oids", oids)
+	st := time.Now()
+
+	results, err = gs.c.GetNext(oids)
+	if err != nil {
+		err = gs.wrapError(err, "getting next from", st)
+	}
+
+	slog.Debug("GetNext of OIDs completed", "oids", oids, "duration", time.Since(st))
+	return
+}
+
+func (gs *GoSNMPWrapper) GetBulk(oids []string, nonRepeaters uint8, maxRepetitions uint32) (results *gosnmp.SnmpPacket, err error) {
+	slog.Debug("Getting bulk OIDS", "oids", oids, "nonRepeaters", nonRepeaters, "maxRepetitions", maxRepetitions)
+	st := time.Now()
+
+	results, err = gs.c.GetBulk(oids, nonRepeaters, maxRepetitions)
+	if err != nil {
+		err = gs.wrapError(err, "getting bulk from", st)
+	}
+
+	slog.Debug("GetBulk of OIDs completed", "oids", oids, "duration", time.Since(st))
+	return
+}
+
+// Walk streams the subtree to fn as responses arrive. An error returned by
+// fn ends the walk and is returned as is.
+func (gs *GoSNMPWrapper) Walk(oid string, fn gosnmp.WalkFunc) error {
+	slog.Debug("Walking subtree", "oid", oid)
+	st := time.Now()
+
+	var fnErr error
+	walkFn := func(pdu gosnmp.SnmpPDU) error {
+		fnErr = fn(pdu)
+		return fnErr
+	}
+
+	var err error
+	if gs.c.Version == gosnmp.Version1 {
+		err = gs.c.Walk(oid, walkFn)
+	} else {
+		err = gs.c.BulkWalk(oid, walkFn)
+	}
+	if err != nil {
+		if fnErr != nil {
+			return fnErr
+		}
+		return gs.wrapError(err, "walking", st)
+	}
+
+	slog.Debug("Walk of subtree completed", "oid", oid, "duration", time.Since(st))
+	return nil
+}
+
+func (gs *GoSNMPWrapper) Set(pdus []gosnmp.SnmpPDU) (results *gosnmp.SnmpPacket, err error) {
+	slog.Debug("Setting OIDS", "count", len(pdus))
+	st := time.Now()
+
+	results, err = gs.c.Set(pdus)
+	if err != nil {
+		err = gs.wrapError(err, "setting on", st)
+	}
+
+	slog.Debug("Set of OIDs completed", "count", len(pdus), "duration", time.Since(st))
+	return
+}
+
+// wrapError adds the target to err while keeping it matchable with errors.Is
+// and errors.As; timeouts become *TimeoutError.
+func (gs *GoSNMPWrapper) wrapError(err error, op string, st time.Time) error {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("scrape cancelled after %s %s target %s: %w", time.Since(st), op, gs.c.Target, err)
+	}
+	if isTimeout(err) {
+		return &TimeoutError{Target: gs.c.Target, Op: op, Duration: time.Since(st), Err: err}
+	}
+	return fmt.Errorf("error %s target %s: %w", op, gs.c.Target, err)
+}
@@ -0,0 +1,98 @@
+package scraper
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestNewGoSNMP_Transport(t *testing.T) {
+	config := ClientConfig{
+		Target:    "localhost",
+		Port:      161,
+		Version:   Versionv2c,
+		Community: "public",
+		Timeout:   time.Second,
+		Transport: TransportUDP4,
+		LocalAddr: "127.0.0.1",
+	}
+
+	gs, err := NewGoSNMP(&config)
+	assert.NoError(t, err)
+	assert.NoError(t, gs.Connect())
+	defer gs.Close()
+	assert.Equal(t, "127.0.0.1", gs.Target())
+	assert.Equal(t, "127.0.0.1:0", gs.c.LocalAddr)
+
+	config.Target = "::1"
+	config.Transport = TransportTCP
+	gs, err = NewGoSNMP(&config)
+	assert.NoError(t, err)
+	assert.Equal(t, "tcp6", gs.c.Transport)
+
+	// a socket path is left alone
+	config.Target = "/run/snmp/relay.sock"
+	config.Transport = TransportUnix
+	gs, err = NewGoSNMP(&config)
+	assert.NoError(t, err)
+	assert.Equal(t, "/run/snmp/relay.sock", gs.Target())
+	assert.ErrorContains(t, gs.Connect(), "/run/snmp/relay.sock")
+
+	config.Transport = "sctp"
+	_, err = NewGoSNMP(&config)
+	assert.Error(t, err)
+}
+
+func TestNewGoSNMP_Hostname(t *testing.T) {
+	addrs, err := net.LookupIP("localhost")
+	require.NoError(t, err)
+	hasV6 := false
+	for _, ip := range addrs {
+		hasV6 = hasV6 || ip.To4() == nil
+	}
+
+	config := ClientConfig{
+		Target:        "localhost",
+		Port:          161,
+		Version:       Versionv2c,
+		Community:     "public",
+		Timeout:       time.Second,
+		AddressFamily: AddressFamilyIPv4,
+	}
+	gs, err := NewGoSNMP(&config)
+	require.NoError(t, err)
+	// hostnames are resolved on Connect
+	assert.Equal(t, "localhost", gs.Target())
+	require.NoError(t, gs.Connect())
+	assert.Equal(t, "127.0.0.1", gs.Target())
+	assert.Equal(t, "udp4", gs.c.Transport)
+	gs.Close()
+
+	// the family is a preference, a name without such an address still
+	// connects
+	config.AddressFamily = AddressFamilyIPv6
+	gs, err = NewGoSNMP(&config)
+	require.NoError(t, err)
+	require.NoError(t, gs.Connect())
+	if hasV6 {
+		assert.Equal(t, "::1", gs.Target())
+	} else {
+		assert.Equal(t, "127.0.0.1", gs.Target())
+	}
+	gs.Close()
+
+	// a transport with a family suffix is a requirement
+	config.AddressFamily = ""
+	config.Transport = TransportTCP6
+	gs, err = NewGoSNMP(&config)
+	require.NoError(t, err)
+	if !hasV6 {
+		assert.ErrorContains(t, gs.Connect(), "no ipv6 address found for localhost")
+	}
+
+	config.AddressFamily = "ipx"
+	_, err = NewGoSNMP(&config)
+	assert.Error(t, err)
+}
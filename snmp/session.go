@@ -0,0 +1,118 @@
+package snmp
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"snmp-test/snmp/scraper"
+	"sync"
+	"syscall"
+)
+
+var errSessionClosed = errors.New("snmp session is closed")
+
+// session holds one long-lived scraper shared by all calls of a client.
+// Requests are serialized by mu since gosnmp does not support concurrent
+// requests on one connection.
+type session struct {
+	mu     sync.Mutex
+	client scraper.SNMPScraper
+	closed bool
+}
+
+func (ss *session) do(ctx context.Context, dial func() (scraper.SNMPScraper, error), fn func(client scraper.SNMPScraper) error) error {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	if ss.closed {
+		return errSessionClosed
+	}
+
+	reused := ss.client != nil
+	if !reused {
+		client, err := dial()
+		if err != nil {
+			return err
+		}
+		ss.client = client
+	}
+	ss.client.SetOptions(scraper.WithContext(ctx))
+
+	err := fn(ss.client)
+	var noRetry *noRetryError
+	if errors.As(err, &noRetry) {
+		err = noRetry.err
+	}
+	if !isTransportError(ctx, err) {
+		return err
+	}
+
+	// The socket may be stale (agent restarted, tcp reset); drop it so the
+	// next call dials again. Retry once if the connection was not fresh and
+	// the socket failed; gosnmp already retried a timeout.
+	slog.Debug("Dropping snmp session after error", "err", err)
+	ss.reset()
+	if !reused || noRetry != nil || !isSocketError(err) {
+		return err
+	}
+
+	client, dialErr := dial()
+	if dialErr != nil {
+		return err
+	}
+	ss.client = client
+	if err = fn(ss.client); isTransportError(ctx, err) {
+		ss.reset()
+	}
+	return err
+}
+
+// isTransportError reports whether err means the connection itself failed,
+// as opposed to the agent answering with an error status.
+func isTransportError(ctx context.Context, err error) bool {
+	if err == nil || ctx.Err() != nil {
+		return false
+	}
+	var pe *PacketError
+	if errors.As(err, &pe) || isPartial(err) || errors.Is(err, ErrUnknownObject) {
+		return false
+	}
+	return true
+}
+
+// isSocketError reports whether err comes from the socket rather than from
+// an agent that did not answer.
+func isSocketError(err error) bool {
+	var timeout *scraper.TimeoutError
+	if errors.As(err, &timeout) {
+		return false
+	}
+	if errors.Is(err, net.ErrClosed) || errors.Is(err, io.EOF) || errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && !opErr.Timeout()
+}
+
+func (ss *session) reset() {
+	if ss.client != nil {
+		_ = ss.client.Close()
+		ss.client = nil
+	}
+}
+
+func (ss *session) close() error {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	ss.closed = true
+	if ss.client == nil {
+		return nil
+	}
+	err := ss.client.Close()
+	ss.client = nil
+	return err
+}
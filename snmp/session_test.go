@@ -0,0 +1,198 @@
+package snmp
+
+import (
+	"context"
+	"errors"
+	"github.com/gosnmp/gosnmp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"snmp-test/snmp/scraper"
+	"sync"
+	"testing"
+)
+
+// dialer builds go-snmp scrapers for a session and counts the dials and
+// the requests sent through them. With timeout set, gets time out.
+type dialer struct {
+	mu       sync.Mutex
+	dials    int
+	requests int
+	timeout  bool
+	last     scraper.SNMPScraper
+}
+
+func (d *dialer) dial(config *scraper.ClientConfig) (scraper.SNMPScraper, error) {
+	inner, err := scraper.NewGoSNMP(config)
+	if err != nil {
+		return nil, err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.dials++
+	d.last = inner
+	return &countingScraper{SNMPScraper: inner, d: d}, nil
+}
+
+// breakConn closes the socket of the last scraper behind the session's
+// back, like an agent restart or a tcp reset would.
+func (d *dialer) breakConn() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	_ = d.last.Close()
+}
+
+func (d *dialer) counts() (int, int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.dials, d.requests
+}
+
+type countingScraper struct {
+	scraper.SNMPScraper
+	d *dialer
+}
+
+func (c *countingScraper) count() (timeout bool) {
+	c.d.mu.Lock()
+	defer c.d.mu.Unlock()
+	c.d.requests++
+	return c.d.timeout
+}
+
+func (c *countingScraper) Get(oids []string) (*gosnmp.SnmpPacket, error) {
+	if c.count() {
+		return nil, &scraper.TimeoutError{Op: "getting from", Err: errors.New("request timeout")}
+	}
+	return c.SNMPScraper.Get(oids)
+}
+
+func (c *countingScraper) Walk(oid string, fn gosnmp.WalkFunc) error {
+	c.count()
+	return c.SNMPScraper.Walk(oid, fn)
+}
+
+func TestSession_Redial(t *testing.T) {
+	_, config := startAgent(t)
+	d := &dialer{}
+	client := NewSessionClient(config, WithScraperFactory(d.dial))
+	defer client.Close()
+
+	_, err := client.GetName("testDescr")
+	require.NoError(t, err)
+	dials, requests := d.counts()
+	assert.Equal(t, 1, dials)
+	assert.Equal(t, 1, requests)
+
+	// the failed request is retried once on a new connection
+	d.breakConn()
+	got, err := client.GetName("testDescr")
+	require.NoError(t, err)
+	assert.Equal(t, `CASA "C100G"`, got)
+	dials, requests = d.counts()
+	assert.Equal(t, 2, dials)
+	assert.Equal(t, 3, requests)
+
+	// and the new connection is kept
+	_, err = client.GetName("testDescr")
+	require.NoError(t, err)
+	dials, requests = d.counts()
+	assert.Equal(t, 2, dials)
+	assert.Equal(t, 4, requests)
+}
+
+func TestSession_TimeoutNotRetried(t *testing.T) {
+	_, config := startAgent(t)
+	d := &dialer{}
+	client := NewSessionClient(config, WithScraperFactory(d.dial))
+	defer client.Close()
+
+	_, err := client.GetName("testDescr")
+	require.NoError(t, err)
+
+	// gosnmp retried the request already, a redial would only double the wait
+	d.timeout = true
+	_, err = client.GetName("testDescr")
+	var timeout *scraper.TimeoutError
+	require.ErrorAs(t, err, &timeout)
+	dials, requests := d.counts()
+	assert.Equal(t, 1, dials)
+	assert.Equal(t, 2, requests)
+
+	// the session is still dropped
+	d.timeout = false
+	_, err = client.GetName("testDescr")
+	require.NoError(t, err)
+	dials, _ = d.counts()
+	assert.Equal(t, 2, dials)
+}
+
+func TestSession_NoRetry(t *testing.T) {
+	_, config := startAgent(t)
+	d := &dialer{}
+	client := NewSessionClient(config, WithScraperFactory(d.dial))
+	defer client.Close()
+
+	_, err := client.GetName("testDescr")
+	require.NoError(t, err)
+
+	// the walk breaks after delivering a value, so it fails with a
+	// noRetryError and must not start over
+	ctx := WithCallOptions(context.Background(), func(snmp *gosnmp.GoSNMP) {
+		snmp.MaxRepetitions = 1
+	})
+	var values []string
+	err = client.StreamBulkValues(ctx, "testNodeName", func(v *Value) error {
+		if len(values) == 0 {
+			d.breakConn()
+		}
+		values = append(values, v.String)
+		return nil
+	})
+	require.Error(t, err)
+	assert.Equal(t, []string{"node-a"}, values)
+	dials, requests := d.counts()
+	assert.Equal(t, 1, dials)
+	assert.Equal(t, 2, requests)
+
+	// the broken session was dropped, the next call dials
+	_, err = client.GetName("testDescr")
+	require.NoError(t, err)
+	dials, _ = d.counts()
+	assert.Equal(t, 2, dials)
+}
+
+func TestSession_Concurrent(t *testing.T) {
+	_, config := startAgent(t)
+	d := &dialer{}
+	client := NewSessionClient(config, WithScraperFactory(d.dial))
+	defer client.Close()
+
+	const goroutines, calls = 8, 10
+	getAll := func() {
+		var wg sync.WaitGroup
+		for i := 0; i < goroutines; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < calls; j++ {
+					got, err := client.GetName("testUpTime")
+					assert.NoError(t, err)
+					assert.Equal(t, "18295586", got)
+				}
+			}()
+		}
+		wg.Wait()
+	}
+
+	getAll()
+	dials, requests := d.counts()
+	assert.Equal(t, 1, dials)
+	assert.Equal(t, goroutines*calls, requests)
+
+	// the first call after the break redials, the others share the result
+	d.breakConn()
+	getAll()
+	dials, requests = d.counts()
+	assert.Equal(t, 2, dials)
+	assert.Equal(t, 2*goroutines*calls+1, requests)
+}
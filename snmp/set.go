@@ -0,0 +1,107 @@
+package snmp
+
+import (
+	"context"
+	"fmt"
+	"github.com/gosnmp/gosnmp"
+	"snmp-test/snmp/parse"
+	"snmp-test/snmp/scraper"
+	"sort"
+)
+
+// setVar is one instance to write and its human value.
+type setVar struct {
+	mib   *parse.MibObject
+	index string
+	value string
+}
+
+func (s *snmp) Set(ctx context.Context, name, index, value string) error {
+	v, err := newSetVar(name, index, value)
+	if err != nil {
+		return err
+	}
+	return s.set(ctx, []setVar{v})
+}
+
+func (s *snmp) SetNames(ctx context.Context, index string, values map[string]string) error {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	vars := make([]setVar, 0, len(names))
+	for _, name := range names {
+		v, err := newSetVar(name, index, values[name])
+		if err != nil {
+			return err
+		}
+		vars = append(vars, v)
+	}
+	return s.set(ctx, vars)
+}
+
+// newSetVar resolves name for a SET. The instance comes from index or from
+// a suffix on name, never both: joining them would write elsewhere than
+// either names.
+func newSetVar(name, index, value string) (setVar, error) {
+	mibObject, instance, err := resolveObject(name)
+	if err != nil {
+		return setVar{}, err
+	}
+	if index == "" {
+		index = instance
+	} else if instance != "" {
+		return setVar{}, fmt.Errorf("%s already names instance %s, index %s is not applied to it", name, instance, index)
+	}
+	return setVar{mib: mibObject, index: index, value: value}, nil
+}
+
+// set sends all vars in a single SetRequest so the agent applies them
+// atomically.
+func (s *snmp) set(ctx context.Context, vars []setVar) error {
+	if len(vars) == 0 {
+		return nil
+	}
+
+	pdus := make([]gosnmp.SnmpPDU, 0, len(vars))
+	oids := make([]string, 0, len(vars))
+	for _, v := range vars {
+		if !isWritable(v.mib) {
+			return fmt.Errorf("%w: %s is %s", ErrNotWritable, v.mib.Name, v.mib.Access)
+		}
+		typ, value, err := encodeValue(v.mib, v.value)
+		if err != nil {
+			return fmt.Errorf("failed to encode %s: %w", v.mib.Name, err)
+		}
+		if v.index == "" {
+			v.index = zeroIndex
+		}
+		oid, err := AddIndexErr(v.mib.OID, v.index)
+		if err != nil {
+			return fmt.Errorf("failed to set %s: %w", v.mib.Name, err)
+		}
+		oids = append(oids, oid)
+		pdus = append(pdus, gosnmp.SnmpPDU{Name: oid, Type: typ, Value: value})
+	}
+
+	return s.do(ctx, func(client scraper.SNMPScraper) error {
+		packet, err := client.Set(pdus)
+		if err != nil {
+			// the agent may have applied it, a second createAndGo or
+			// destroy must not follow
+			return &noRetryError{err}
+		}
+		if packet.Error != gosnmp.NoError {
+			return newPacketError(packet, oids)
+		}
+		return nil
+	})
+}
+
+// isWritable reports whether mib is read-write or read-create; gosmi folds
+// both into ReadWrite.
+func isWritable(mib *parse.MibObject) bool {
+	return mib.Access == "ReadWrite"
+}
@@ -1,13 +1,15 @@
 package snmp
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"github.com/gosnmp/gosnmp"
-	"github.com/sleepinggenius2/gosmi/types"
 	"log/slog"
 	"snmp-test/snmp/parse"
 	"snmp-test/snmp/scraper"
 	"strings"
+	"sync"
 )
 
 type SnmpClient interface {
@@ -18,136 +20,336 @@ type SnmpClient interface {
 	GetBulk(name string) (map[string]string, error)
 	GetBulkByNames(names []string) (map[string]map[string]string, error)
 	GetBulkTable(name string) ([]map[string]string, error)
+
+	// The *Ctx variants carry their own deadline and cancellation down to the
+	// underlying snmp session; the plain variants use ClientConfig.Context.
+	GetNameCtx(ctx context.Context, name string) (string, error)
+	GetNamesCtx(ctx context.Context, names ...string) (map[string]string, error)
+	GetNameByIndexesCtx(ctx context.Context, name string, indexes []string) (map[string]string, error)
+	GetTableByNamesAndIndexesCtx(ctx context.Context, names, indexes []string) ([]map[string]string, error)
+	GetBulkCtx(ctx context.Context, name string) (map[string]string, error)
+	GetBulkByNamesCtx(ctx context.Context, names []string) (map[string]map[string]string, error)
+	GetBulkTableCtx(ctx context.Context, name string) ([]map[string]string, error)
+
+	TypedClient
+
+	// Set writes one instance of a read-write or read-create object. value
+	// is given in the same human form that GetName returns. The instance is
+	// either index or a suffix of name, passing both is an error.
+	Set(ctx context.Context, name, index, value string) error
+	// SetNames writes several objects in one request, at index or at the
+	// instance each name carries; like Set, not both.
+	SetNames(ctx context.Context, index string, values map[string]string) error
+
+	// CreateRow, CreateRowAndWait and DestroyRow manage rows of tables with
+	// a RowStatus column. table is the table or entry name.
+	CreateRow(ctx context.Context, table, index string, values map[string]string) error
+	CreateRowAndWait(ctx context.Context, table, index string, values map[string]string) error
+	DestroyRow(ctx context.Context, table, index string) error
+
+	// Close releases the session held by a client from NewSessionClient.
+	// It is a no-op for clients from NewClient.
+	Close() error
+}
+
+// NewClient returns a client that dials the target for every call.
+func NewClient(config *scraper.ClientConfig, opts ...Option) SnmpClient {
+	return newSnmp(config, nil, opts)
+}
+
+// NewSessionClient returns a client that keeps one connection to the target
+// open across calls and reconnects after transport errors. Calls are
+// serialized, so it is safe for concurrent use. Close it when done.
+func NewSessionClient(config *scraper.ClientConfig, opts ...Option) SnmpClient {
+	return newSnmp(config, &session{}, opts)
 }
 
-func NewClient(config *scraper.ClientConfig) SnmpClient {
-	return &snmp{config: config}
+func newSnmp(config *scraper.ClientConfig, session *session, opts []Option) *snmp {
+	s := &snmp{config: config, session: session, newScraper: newGoSNMP}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// TypedClient mirrors the string API of SnmpClient but keeps the decoded
+// type of every varbind. Table rows are keyed by index.
+type TypedClient interface {
+	GetValue(ctx context.Context, name string) (*Value, error)
+	GetValues(ctx context.Context, names ...string) (map[string]*Value, error)
+	GetValuesByIndexes(ctx context.Context, name string, indexes []string) (map[string]*Value, error)
+	GetTableValues(ctx context.Context, names, indexes []string) ([]Row, error)
+	GetBulkValues(ctx context.Context, name string) (map[string]*Value, error)
+	GetBulkValuesByNames(ctx context.Context, names []string) (map[string]map[string]*Value, error)
+	GetBulkTableValues(ctx context.Context, name string) ([]Row, error)
+
+	// GetNextValues returns the instance following each name. WalkValues
+	// and BulkWalkValues return a subtree in OID order using GetNext or,
+	// where the version allows, GetBulk. Every value of these three is
+	// bound to the MIB object its OID belongs to.
+	GetNextValues(ctx context.Context, names ...string) ([]*Value, error)
+	WalkValues(ctx context.Context, name string) ([]*Value, error)
+	BulkWalkValues(ctx context.Context, name string) ([]*Value, error)
+
+	// StreamBulkValues is GetBulkValues handing each value to fn as the
+	// responses arrive. StreamTableRows is GetBulkTableValues handing out
+	// complete rows in index order; it walks the columns side by side, so
+	// memory stays bounded however large the table. An error from fn ends
+	// the walk and is returned, unless it is ErrStopWalk.
+	StreamBulkValues(ctx context.Context, name string, fn func(*Value) error) error
+	StreamTableRows(ctx context.Context, name string, fn func(Row) error) error
 }
 
 var _ SnmpClient = (*snmp)(nil)
 
 type snmp struct {
-	config *scraper.ClientConfig
+	config  *scraper.ClientConfig
+	session *session
+
+	newScraper     ScraperFactory
+	scraperOptions []func(snmp *gosnmp.GoSNMP)
+
+	// mu guards resolved, the address a hostname target first resolved to
+	mu       sync.Mutex
+	resolved string
+}
+
+// context returns the default context used by the methods without a ctx argument.
+func (s *snmp) context() context.Context {
+	if s.config.Context != nil {
+		return s.config.Context
+	}
+	return context.Background()
 }
 
 //////////////////////////////// Get //////////////////////////////////////////
 
 func (s *snmp) GetName(name string) (string, error) {
-	m, err := s.get(name, []string{zeroIndex})
-	if err != nil {
-		return "", nil
-	}
-	return m["0"], nil
+	return s.GetNameCtx(s.context(), name)
 }
 
 func (s *snmp) GetNames(names ...string) (map[string]string, error) {
-	return s.get1(names, zeroIndex)
+	return s.GetNamesCtx(s.context(), names...)
 }
 
 func (s *snmp) GetNameByIndexes(name string, indexes []string) (map[string]string, error) {
-	if len(indexes) == 0 {
-		indexes = []string{zeroIndex}
-	}
-	return s.get(name, indexes)
+	return s.GetNameByIndexesCtx(s.context(), name, indexes)
 }
 
 func (s *snmp) GetTableByNamesAndIndexes(names, indexes []string) ([]map[string]string, error) {
+	return s.GetTableByNamesAndIndexesCtx(s.context(), names, indexes)
+}
+
+func (s *snmp) GetNameCtx(ctx context.Context, name string) (string, error) {
+	v, err := s.GetValue(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	return v.String, nil
+}
+
+func (s *snmp) GetNamesCtx(ctx context.Context, names ...string) (map[string]string, error) {
+	m, err := s.GetValues(ctx, names...)
+	return valuesAsStrings(m), err
+}
+
+func (s *snmp) GetNameByIndexesCtx(ctx context.Context, name string, indexes []string) (map[string]string, error) {
+	m, err := s.GetValuesByIndexes(ctx, name, indexes)
+	return valuesAsStrings(m), err
+}
+
+func (s *snmp) GetTableByNamesAndIndexesCtx(ctx context.Context, names, indexes []string) ([]map[string]string, error) {
+	rows, err := s.GetTableValues(ctx, names, indexes)
+	return rowsAsStrings(rows), err
+}
+
+func (s *snmp) GetValue(ctx context.Context, name string) (*Value, error) {
+	mibObject, index, err := resolveObject(name)
+	if err != nil {
+		return nil, err
+	}
+	if index == "" {
+		index = "0"
+	}
+
+	m, err := s.getObject(ctx, mibObject, []string{index})
+	if v, ok := m[index]; ok {
+		return v, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("%w: %s.%s", ErrNoSuchInstance, mibObject.Name, index)
+}
+
+func (s *snmp) GetValues(ctx context.Context, names ...string) (map[string]*Value, error) {
+	return s.get1(ctx, names, zeroIndex)
+}
+
+func (s *snmp) GetValuesByIndexes(ctx context.Context, name string, indexes []string) (map[string]*Value, error) {
+	return s.get(ctx, name, indexes)
+}
+
+func (s *snmp) GetTableValues(ctx context.Context, names, indexes []string) ([]Row, error) {
 	if len(names) == 0 {
-		return []map[string]string{}, nil
+		return []Row{}, nil
 	}
 	if len(indexes) == 0 {
 		indexes = []string{zeroIndex}
 	}
 
-	results := make([]map[string]string, 0, len(indexes))
+	partial := &PartialResultError{}
+	results := make([]Row, 0, len(indexes))
 	for _, index := range indexes {
-		nameValue := make(map[string]string)
-		nameValue["index"] = index
-		ret, err := s.get1(names, index)
-		if err == nil {
-			for k, v := range ret {
-				nameValue[k] = v
+		if err := ctx.Err(); err != nil {
+			return results, err
+		}
+		row := Row{Index: index, Values: make(map[string]*Value)}
+		ret, err := s.get1(ctx, names, index)
+		if ret != nil {
+			row.Values = ret
+		}
+		for _, v := range row.Values {
+			if entry, ok := parse.RowOf(v.Object); ok {
+				row.IndexValues = decodeRowIndex(entry, index)
 			}
+			break
+		}
+		resolveAddresses(&row)
+		if err != nil {
+			partial.add(index, err)
 		}
-		results = append(results, nameValue)
+		results = append(results, row)
 	}
-	return results, nil
+	return results, partial.orNil()
 }
 
-func (s *snmp) get(name string, indexes []string) (map[string]string, error) {
-	mibObject := getMibObjByName(name)
-	if mibObject == nil {
-		return nil, fmt.Errorf("failed to find mib name from db: %v", name)
+// get reads name at each index; an instance suffix on name is a prefix of
+// every index. Without indexes it reads the instance of name, or .0.
+func (s *snmp) get(ctx context.Context, name string, indexes []string) (map[string]*Value, error) {
+	mibObject, instance, err := resolveObject(name)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case len(indexes) == 0 && instance == "":
+		indexes = []string{zeroIndex}
+	case len(indexes) == 0:
+		indexes = []string{instance}
+	case instance != "":
+		prefixed := make([]string, 0, len(indexes))
+		for _, index := range indexes {
+			prefixed = append(prefixed, AddIndex(instance, index))
+		}
+		indexes = prefixed
 	}
+	return s.getObject(ctx, mibObject, indexes)
+}
 
+func (s *snmp) getObject(ctx context.Context, mibObject *parse.MibObject, indexes []string) (map[string]*Value, error) {
 	oids := make([]string, 0, len(indexes))
 	for _, index := range indexes {
 		oids = append(oids, AddIndex(mibObject.OID, index))
 	}
 
-	client, err := s.initWrapper()
-	if err != nil {
+	var pdus []gosnmp.SnmpPDU
+	err := s.do(ctx, func(client scraper.SNMPScraper) (err error) {
+		pdus, err = s._get(ctx, client, oids)
+		return
+	})
+	if err != nil && !isPartial(err) {
 		return nil, err
 	}
-	defer func() {
-		_ = client.Close()
-	}()
-
-	pdus, err := s._get(client, oids)
-	if err != nil {
-		return nil, err
+	var partial *PartialResultError
+	if errors.As(err, &partial) {
+		partial.rekey(func(oid string) (string, bool) {
+			index := GetIndex(mibObject.OID, oid)
+			return index, index != ""
+		})
 	}
 
-	indexValueMap := make(map[string]string, len(pdus))
+	indexValueMap := make(map[string]*Value, len(pdus))
 	for _, pdu := range pdus {
 		index := GetIndex(mibObject.OID, pdu.Name[1:])
 		if index != "" {
-			indexValueMap[index] = pduValueAsString(mibObject, &pdu)
+			indexValueMap[index] = newValue(mibObject, index, &pdu)
 		}
 	}
 
-	return indexValueMap, nil
+	return indexValueMap, err
 }
 
-func (s *snmp) get1(names []string, index string) (map[string]string, error) {
-	oidMibObjectMap := make(map[string]*parse.MibObject, len(names))
+func (s *snmp) get1(ctx context.Context, names []string, index string) (map[string]*Value, error) {
+	type named struct {
+		mib  *parse.MibObject
+		name string
+	}
+
+	// names may carry their own instance suffix, which wins over index;
+	// results are keyed by the name as given
+	partial := &PartialResultError{}
+	oidMibObjectMap := make(map[string]named, len(names))
 	oids := make([]string, 0, len(names))
 	for _, name := range names {
-		object := getMibObjByName(name)
-		if object != nil {
-			oid := AddIndex(object.OID, index)
-			oidMibObjectMap[oid] = object
-			oids = append(oids, oid)
+		object, instance, err := resolveObject(name)
+		// a numeric OID no MIB covers is requested as given, without index
+		var oid string
+		if err == nil {
+			if instance == "" {
+				instance = index
+			}
+			if oid, err = AddIndexErr(object.OID, instance); err != nil {
+				partial.add(name, err)
+				continue
+			}
+		} else if oid, _, err = resolveOID(name); err != nil {
+			partial.add(name, err)
+			continue
 		}
+		oidMibObjectMap[oid] = named{mib: object, name: name}
+		oids = append(oids, oid)
 	}
 	if len(oidMibObjectMap) == 0 {
-		return nil, fmt.Errorf("failed to find mib names from db: %v", strings.Join(names, ","))
+		// nothing to request
+		return map[string]*Value{}, partial
 	}
 
-	client, err := s.initWrapper()
-	if err != nil {
+	var pdus []gosnmp.SnmpPDU
+	err := s.do(ctx, func(client scraper.SNMPScraper) (err error) {
+		pdus, err = s._get(ctx, client, oids)
+		return
+	})
+	if err != nil && !isPartial(err) {
 		return nil, err
 	}
-	defer func() {
-		_ = client.Close()
-	}()
 
-	pdus, err := s._get(client, oids)
-	if err != nil {
-		return nil, err
+	var getErr *PartialResultError
+	if errors.As(err, &getErr) {
+		getErr.rekey(func(oid string) (string, bool) {
+			n, ok := oidMibObjectMap[oid]
+			return n.name, ok
+		})
+		for name, failure := range getErr.Failures {
+			partial.add(name, failure)
+		}
 	}
 
-	nameValueMap := make(map[string]string, len(pdus))
+	nameValueMap := make(map[string]*Value, len(pdus))
 	for _, pdu := range pdus {
-		if mib, ok := oidMibObjectMap[pdu.Name[1:]]; ok {
-			nameValueMap[mib.Name] = pduValueAsString(mib, &pdu)
+		n, ok := oidMibObjectMap[pdu.Name[1:]]
+		switch {
+		case !ok:
+		case n.mib == nil:
+			nameValueMap[n.name] = boundValue(&pdu)
+		default:
+			nameValueMap[n.name] = newValue(n.mib, GetIndex(n.mib.OID, pdu.Name[1:]), &pdu)
 		}
 	}
 
-	return nameValueMap, nil
+	return nameValueMap, partial.orNil()
 }
 
-func (s *snmp) _get(client *scraper.GoSNMPWrapper, getOids []string) ([]gosnmp.SnmpPDU, error) {
+func (s *snmp) _get(ctx context.Context, client scraper.SNMPScraper, getOids []string) ([]gosnmp.SnmpPDU, error) {
 	maxOids := s.config.MaxOIDs
 	isVersion1 := s.config.Version == scraper.Version1
 
@@ -159,7 +361,12 @@ func (s *snmp) _get(client *scraper.GoSNMPWrapper, getOids []string) ([]gosnmp.S
 	}
 
 	var results []gosnmp.SnmpPDU
+	partial := &PartialResultError{}
 	for len(getOids) > 0 {
+		if err := ctx.Err(); err != nil {
+			return results, err
+		}
+
 		oidsLen := len(getOids)
 		if oidsLen > maxOids {
 			oidsLen = maxOids
@@ -167,24 +374,31 @@ func (s *snmp) _get(client *scraper.GoSNMPWrapper, getOids []string) ([]gosnmp.S
 
 		packet, err := client.Get(getOids[:oidsLen])
 		if err != nil {
-			break
+			return results, err
 		}
 
 		// SNMPv1 will return packet error for unsupported OIDs.
 		if packet.Error == gosnmp.NoSuchName && isVersion1 {
-			slog.Debug("OID not supported by target", "OID", getOids[0])
+			slog.Debug("OID not supported by target", "OID", getOids[0], "name", parse.Render(getOids[0]))
+			partial.add(getOids[0], fmt.Errorf("%w: %s", ErrNoSuchObject, getOids[0]))
 			getOids = getOids[oidsLen:]
 			continue
 		}
 
 		// Response received with errors.
 		if packet.Error != gosnmp.NoError {
-			return results, fmt.Errorf("packet error, status %d", packet.Error)
+			return results, newPacketError(packet, getOids[:oidsLen])
 		}
 
 		for _, v := range packet.Variables {
-			if v.Type == gosnmp.NoSuchObject || v.Type == gosnmp.NoSuchInstance {
-				slog.Debug("OID not supported by target", "oids", v.Name)
+			switch v.Type {
+			case gosnmp.NoSuchObject:
+				slog.Debug("OID not supported by target", "oids", v.Name, "name", parse.Render(v.Name))
+				partial.add(strings.TrimPrefix(v.Name, "."), fmt.Errorf("%w: %s", ErrNoSuchObject, v.Name))
+				continue
+			case gosnmp.NoSuchInstance:
+				slog.Debug("OID not supported by target", "oids", v.Name, "name", parse.Render(v.Name))
+				partial.add(strings.TrimPrefix(v.Name, "."), fmt.Errorf("%w: %s", ErrNoSuchInstance, v.Name))
 				continue
 			}
 			results = append(results, v)
@@ -193,138 +407,196 @@ func (s *snmp) _get(client *scraper.GoSNMPWrapper, getOids []string) ([]gosnmp.S
 		getOids = getOids[oidsLen:]
 	}
 
-	return results, nil
+	return results, partial.orNil()
 }
 
 ///////////////////////////// Get bulk ////////////////////////////////////////////////////////
 
 func (s *snmp) GetBulk(name string) (map[string]string, error) {
-	mibObject := getMibObjByName(name)
-	if mibObject == nil {
-		return nil, fmt.Errorf("failed to find mib %s in db", name)
+	return s.GetBulkCtx(s.context(), name)
+}
+
+func (s *snmp) GetBulkByNames(names []string) (map[string]map[string]string, error) {
+	return s.GetBulkByNamesCtx(s.context(), names)
+}
+
+func (s *snmp) GetBulkTable(name string) ([]map[string]string, error) {
+	return s.GetBulkTableCtx(s.context(), name)
+}
+
+func (s *snmp) GetBulkCtx(ctx context.Context, name string) (map[string]string, error) {
+	m, err := s.GetBulkValues(ctx, name)
+	if err != nil {
+		return nil, err
 	}
+	return valuesAsStrings(m), nil
+}
 
-	pdus, err := s._getBulkByOid(mibObject.OID)
+func (s *snmp) GetBulkByNamesCtx(ctx context.Context, names []string) (map[string]map[string]string, error) {
+	m, err := s.GetBulkValuesByNames(ctx, names)
+	nameValueMap := make(map[string]map[string]string, len(m))
+	for name, values := range m {
+		nameValueMap[name] = valuesAsStrings(values)
+	}
+	return nameValueMap, err
+}
+
+func (s *snmp) GetBulkTableCtx(ctx context.Context, name string) ([]map[string]string, error) {
+	rows, err := s.GetBulkTableValues(ctx, name)
 	if err != nil {
 		return nil, err
 	}
+	return rowsAsStrings(rows), nil
+}
 
-	indexValueMap := make(map[string]string, len(pdus))
-	for _, pdu := range pdus {
-		index := GetIndex(mibObject.OID, pdu.Name[1:])
-		if index != "" {
-			indexValueMap[index] = pduValueAsString(mibObject, &pdu)
-		}
+func (s *snmp) GetBulkValues(ctx context.Context, name string) (map[string]*Value, error) {
+	indexValueMap := make(map[string]*Value)
+	err := s.StreamBulkValues(ctx, name, func(v *Value) error {
+		indexValueMap[v.Index] = v
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 	return indexValueMap, nil
 }
 
-func (s *snmp) GetBulkByNames(names []string) (map[string]map[string]string, error) {
-	nameValueMap := make(map[string]map[string]string)
+func (s *snmp) GetBulkValuesByNames(ctx context.Context, names []string) (map[string]map[string]*Value, error) {
+	partial := &PartialResultError{}
+	nameValueMap := make(map[string]map[string]*Value)
 	for _, name := range names {
-		ret, err := s.GetBulk(name)
-		if err == nil {
-			nameValueMap[name] = ret
+		if err := ctx.Err(); err != nil {
+			return nameValueMap, err
+		}
+		ret, err := s.GetBulkValues(ctx, name)
+		if err != nil {
+			partial.add(name, err)
+			continue
 		}
+		nameValueMap[name] = ret
 	}
-	return nameValueMap, nil
+	return nameValueMap, partial.orNil()
 }
 
-func (s *snmp) GetBulkTable(name string) ([]map[string]string, error) {
-	mibObject := getMibObjByName(name)
-	if mibObject == nil {
-		return nil, fmt.Errorf("failed to find mib %q in db", name)
-	}
-
-	pdus, err := s._getBulkByOid(mibObject.OID)
+func (s *snmp) GetBulkTableValues(ctx context.Context, name string) ([]Row, error) {
+	mibObject, err := resolveEntry(name)
 	if err != nil {
 		return nil, err
 	}
 
-	isChild := func(parentOid, subOid string) bool {
-		po, err := types.OidFromString(parentOid)
+	var results []Row
+	if mibObject.Kind == "Row" {
+		err = s.streamRows(ctx, mibObject, func(row Row) error {
+			results = append(results, row)
+			return nil
+		})
 		if err != nil {
-			return false
+			return nil, err
 		}
-		so, err := types.OidFromString(subOid)
-		if err != nil {
-			return false
-		}
-		return po.ParentOf(so)
+		return results, nil
 	}
 
-	// Simplify the code to get all child-mib-object, not from db or cache
-	childIndexMap := make(map[string]*parse.MibObject)
-	for _, pdu := range pdus {
-		subId := pdu.Name[1:]
-		if !isChild(mibObject.OID, subId) {
-			continue
-		}
-		id := GetIndex(mibObject.OID, subId)
+	// anything else, e.g. a group of scalars, makes one row per instance
+	// suffix below its children
+	rows := make(map[string]map[string]*Value)
+	var order []string
+	err = s.walk(ctx, mibObject.OID, func(pdu *gosnmp.SnmpPDU) error {
+		id := GetIndex(mibObject.OID, pdu.Name[1:])
 		dotIndex := strings.Index(id, ".")
-		if dotIndex > 0 {
-			childIndex, _ := id[:dotIndex], id[:dotIndex]
-			subOid := AddIndex(mibObject.OID, childIndex)
-			if mib := getMibObjByOID(subOid); mib != nil {
-				childIndexMap[childIndex] = mib
-			}
+		if dotIndex <= 0 {
+			return nil
 		}
-	}
-
-	indexSubValueMap := make(map[string]map[string]string)
-	for _, pdu := range pdus {
-		subId := pdu.Name[1:]
-		if !isChild(mibObject.OID, subId) {
-			continue
+		childIndex, index := id[:dotIndex], id[dotIndex+1:]
+		if _, ok := rows[index]; !ok {
+			rows[index] = make(map[string]*Value)
+			order = append(order, index)
 		}
+		if childObj := getMibObjByOID(AddIndex(mibObject.OID, childIndex)); childObj != nil {
+			rows[index][childObj.Name] = newValue(childObj, index, pdu)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
 
-		id := GetIndex(mibObject.OID, subId)
-		dotIndex := strings.Index(id, ".")
-		if dotIndex > 0 {
-			childIndex, index := id[:dotIndex], id[dotIndex+1:]
-
-			if _, ok := indexSubValueMap[index]; !ok {
-				indexSubValueMap[index] = make(map[string]string)
-				indexSubValueMap[index]["index"] = index
-			}
+	results = make([]Row, 0, len(order))
+	for _, index := range order {
+		row := Row{Index: index, IndexValues: decodeRowIndex(mibObject, index), Values: rows[index]}
+		resolveAddresses(&row)
+		results = append(results, row)
+	}
+	return results, nil
+}
 
-			if childObj, ok := childIndexMap[childIndex]; ok {
-				indexSubValueMap[index][childObj.Name] = pduValueAsString(childObj, &pdu)
+// do runs fn against a connected scraper: a fresh one per call, or the shared
+// one when the client was created by NewSessionClient.
+func (s *snmp) do(ctx context.Context, fn func(client scraper.SNMPScraper) error) error {
+	if opts := callOptions(ctx); len(opts) > 0 {
+		inner := fn
+		fn = func(client scraper.SNMPScraper) error {
+			if s.session != nil {
+				defer client.SetOptions(saveSettings(client))
 			}
+			client.SetOptions(opts...)
+			return inner(client)
 		}
 	}
 
-	results := make([]map[string]string, 0, len(indexSubValueMap))
-	for _, v := range indexSubValueMap {
-		results = append(results, v)
+	if s.session != nil {
+		return s.session.do(ctx, func() (scraper.SNMPScraper, error) {
+			return s.initScraper(ctx)
+		}, fn)
 	}
-	return results, nil
-}
 
-func (s *snmp) _getBulkByOid(oid string) ([]gosnmp.SnmpPDU, error) {
-	client, err := s.initWrapper()
+	client, err := s.initScraper(ctx)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	defer func() {
 		_ = client.Close()
 	}()
 
-	pdus, err := client.WalkAll(oid)
-	if err != nil {
-		return nil, err
+	err = fn(client)
+	var noRetry *noRetryError
+	if errors.As(err, &noRetry) {
+		return noRetry.err
 	}
+	return err
+}
 
-	return pdus, nil
+func (s *snmp) Close() error {
+	if s.session != nil {
+		return s.session.close()
+	}
+	return nil
 }
 
-func (s *snmp) initWrapper() (*scraper.GoSNMPWrapper, error) {
-	wrapper, err := scraper.NewGoSNMP(s.config)
+func (s *snmp) initScraper(ctx context.Context) (scraper.SNMPScraper, error) {
+	config := s.config
+	s.mu.Lock()
+	if s.resolved != "" && !config.ResolveOnReconnect {
+		c := *config
+		c.Target = s.resolved
+		config = &c
+	}
+	s.mu.Unlock()
+
+	client, err := s.newScraper(config)
 	if err != nil {
 		return nil, err
 	}
-	if err = wrapper.Connect(); err != nil {
+	client.SetOptions(s.scraperOptions...)
+	client.SetOptions(scraper.WithContext(ctx))
+	if err = client.Connect(); err != nil {
 		return nil, err
 	}
-	return wrapper, nil
+
+	// remember what a hostname resolved to, for backends that resolve
+	if t, ok := client.(interface{ Target() string }); ok {
+		s.mu.Lock()
+		s.resolved = t.Target()
+		s.mu.Unlock()
+	}
+	return client, nil
 }
@@ -1,186 +1,653 @@
 package snmp
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"github.com/gosnmp/gosnmp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net"
+	"path/filepath"
+	"snmp-test/snmp/agent"
+	"snmp-test/snmp/fault"
+	"snmp-test/snmp/record"
 	"snmp-test/snmp/scraper"
 	"testing"
 	"time"
 )
 
-var defaultConfig = scraper.ClientConfig{
-	Target:    "172.0.1.130",
-	Port:      161,
-	Version:   scraper.Versionv2c,
-	Community: "public",
-	Timeout:   3 * time.Second,
-	Retries:   2,
-}
+const (
+	nodeA = "0.23.16.43.105.88.10.0.0.1"
+	nodeB = "0.23.16.43.105.89.10.0.0.2"
+)
 
-func printMap(m map[string]string) {
-	for k, v := range m {
-		fmt.Printf("%v: %v\n", k, v)
-	}
+// startAgent serves testdata/agent.walk on a local port and returns the
+// agent with a v2c client config for it.
+func startAgent(t *testing.T) (*agent.Agent, *scraper.ClientConfig) {
+	t.Helper()
+	loadTestMibs(t)
+	data, err := record.LoadWalk("testdata/agent.walk")
+	require.NoError(t, err)
+
+	a, err := agent.Start(agent.Config{Data: data})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = a.Close()
+	})
+	return a, a.ClientConfig(scraper.Versionv2c)
 }
 
 func TestSnmpClient_GetName(t *testing.T) {
-	client := NewClient(&defaultConfig)
-	sysDescr, _ := client.GetName("sysDescr")
-	sysUpTime, _ := client.GetName("sysUpTime")
-	sysName, _ := client.GetName("sysName")
-	casaSysConfigLastChanged, _ := client.GetName("casaSysConfigLastChanged")
-	casaSysConfigLastSaved, _ := client.GetName("casaSysConfigLastSaved")
-
-	fmt.Println("TestSnmpClient_GetName")
-	fmt.Printf("sysDescr: %v\n", sysDescr)
-	fmt.Printf("sysUpTime: %v\n", sysUpTime)
-	fmt.Printf("sysName: %v\n", sysName)
-	fmt.Printf("casaSysConfigLastChanged: %v\n", casaSysConfigLastChanged)
-	fmt.Printf("casaSysConfigLastSaved: %v\n", casaSysConfigLastSaved)
-
-	//sysDescr: CASA DCTS smm, HW=CASA-C100G, serial_no SB02CI1S0029, hardware revision 1.1, CFE version 12.5.3, software release version 8.8.3, Ver 6,buildc481
-	//sysUpTime: 18295586
-	//sysName: C100G-130
-	//casaSysConfigLastChanged: 2024-5-7 10:24:11
-	//casaSysConfigLastSaved: 2024-5-6 14:50:41
+	_, config := startAgent(t)
+	client := NewClient(config)
+
+	for name, want := range map[string]string{
+		"testDescr":       `CASA "C100G"`,
+		"testUpTime":      "18295586",
+		"testLastChanged": "2024-05-07T10:24:11+08:00",
+		"testPhysAddress": "00:17:10:2b:69:58",
+		"testTemperature": "23.5",
+	} {
+		got, err := client.GetName(name)
+		assert.NoError(t, err, name)
+		assert.Equal(t, want, got, name)
+	}
+
+	_, err := client.GetName("testNodeName")
+	assert.ErrorIs(t, err, ErrNoSuchInstance)
+	_, err = client.GetName("noSuchName")
+	assert.ErrorIs(t, err, ErrUnknownObject)
 }
 
 func TestSnmpClient_GetNames(t *testing.T) {
-	client := NewClient(&defaultConfig)
-	ret, err := client.GetNames("sysDescr", "sysUpTime", "sysName", "sysLocation", "sysContact", "casaSysConfigLastChanged", "casaSysConfigLastSaved")
-	if err != nil {
-		t.Error(err)
-	}
+	_, config := startAgent(t)
+	client := NewClient(config)
+
+	ret, err := client.GetNames("testDescr", "testUpTime", "testBlob")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"testDescr":  `CASA "C100G"`,
+		"testUpTime": "18295586",
+		"testBlob":   "line one\nline two",
+	}, ret)
 
-	fmt.Printf("\nTestSnmpClient_GetNames:\n")
-	printMap(ret)
+	// an instance suffix on a name wins over the default .0
+	ret, err = client.GetNames("testDescr", "testNodeName."+nodeA)
+	require.NoError(t, err)
+	assert.Equal(t, "node-a", ret["testNodeName."+nodeA])
 
-	//sysDescr: CASA DCTS smm, HW=CASA-C100G, serial_no SB02CI1S0029, hardware revision 1.1, CFE version 12.5.3, software release version 8.8.3, Ver 6,buildc481
-	//sysUpTime: 18882827
-	//sysName: C100G-130
-	//sysLocation: test 102#A
-	//sysContact: casaVideoInputProgEsTable
-	//casaSysConfigLastChanged: 2024-5-7 10:24:11
-	//casaSysConfigLastSaved: 2024-5-6 14:50:41
+	// failures are keyed by the name as given, whether the agent or the
+	// MIBs rejected it
+	ret, err = client.GetNames("testDescr", "testDescr.1", "noSuchName")
+	var partial *PartialResultError
+	require.True(t, errors.As(err, &partial))
+	assert.Equal(t, []string{"noSuchName", "testDescr.1"}, partial.keys())
+	assert.ErrorIs(t, partial.Failures["testDescr.1"], ErrNoSuchInstance)
+	assert.ErrorIs(t, partial.Failures["noSuchName"], ErrUnknownObject)
+	assert.Equal(t, map[string]string{"testDescr": `CASA "C100G"`}, ret)
+
+	// even when nothing could be requested
+	_, err = client.GetNames("noSuchName")
+	require.True(t, errors.As(err, &partial))
+	assert.Equal(t, []string{"noSuchName"}, partial.keys())
 }
 
 func TestSnmpClient_GetNameByIndexes(t *testing.T) {
-	client := NewClient(&defaultConfig)
-	ret, err := client.GetNameByIndexes("ifDescr", []string{"1000073", "1000168", "1002460"})
-	if err != nil {
-		t.Error(err)
-	}
+	_, config := startAgent(t)
+	client := NewClient(config)
+
+	ret, err := client.GetNameByIndexes("testNodeName", []string{nodeA, "00:17:10:2B:69:59,10.0.0.2"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{nodeA: "node-a", nodeB: "node-b"}, ret)
 
-	fmt.Printf("\nTestSnmpClient_GetNameByIndexes:\n")
-	printMap(ret)
+	ret, err = client.GetNameByIndexes("testNodeName", []string{nodeA, "1.2.3"})
+	var partial *PartialResultError
+	require.True(t, errors.As(err, &partial))
+	assert.Equal(t, []string{"1.2.3"}, partial.keys())
+	assert.Equal(t, map[string]string{nodeA: "node-a"}, ret)
 
-	//1002460: XGige 192:0/0
-	//1000073: XGige 6/1
-	//1000168: XGige 1:0/0
+	// a name with an instance reads that instance, without index or with
+	// the index under it
+	ret, err = client.GetNameByIndexes("testDescr.0", nil)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"0": `CASA "C100G"`}, ret)
+	ret, err = client.GetNameByIndexes("testNodeName."+nodeA, nil)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{nodeA: "node-a"}, ret)
+	ret, err = client.GetNameByIndexes("testNodeName.0.23.16.43.105", []string{"88.10.0.0.1"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{nodeA: "node-a"}, ret)
+
+	values, err := client.GetValuesByIndexes(context.Background(), "testDescr.0", nil)
+	require.NoError(t, err)
+	assert.Equal(t, `CASA "C100G"`, values["0"].String)
 }
 
 func TestSnmpClient_GetTableByNamesAndIndexes(t *testing.T) {
-	client := NewClient(&defaultConfig)
-	ret, _ := client.GetTableByNamesAndIndexes([]string{"ifDescr", "ifHCInOctets", "ifHCOutOctets", "ifHighSpeed"}, []string{"1000073", "1000168", "1002460"})
+	_, config := startAgent(t)
+	client := NewClient(config)
 
-	fmt.Printf("\nTestSnmpClient_GetTableByNamesAndIndexes:\n")
-	for _, v := range ret {
-		printMap(v)
-		fmt.Println()
-	}
+	ret, err := client.GetTableByNamesAndIndexes([]string{"testNodeName", "testNodeInOctets"}, []string{nodeA, nodeB})
+	require.NoError(t, err)
+	assert.Equal(t, []map[string]string{
+		{"index": nodeA, "testNodeMac": "00:17:10:2B:69:58", "testNodeAddr": "10.0.0.1", "testNodeName": "node-a", "testNodeInOctets": "1000"},
+		{"index": nodeB, "testNodeMac": "00:17:10:2B:69:59", "testNodeAddr": "10.0.0.2", "testNodeName": "node-b", "testNodeInOctets": "20"},
+	}, ret)
 
-	//index: 1000073
-	//ifHCOutOctets: 0
-	//ifHighSpeed: 10000
-	//ifDescr: XGige 6/1
-	//ifHCInOctets: 0
-	//
-	//index: 1000168
-	//ifHCInOctets: 0
-	//ifHCOutOctets: 0
-	//ifHighSpeed: 10000
-	//ifDescr: XGige 1:0/0
-	//
-	//index: 1002460
-	//ifHighSpeed: 10000
-	//ifDescr: XGige 192:0/0
-	//ifHCInOctets: 0
-	//ifHCOutOctets: 0
+	// an index that does not encode fails its row instead of reading garbage
+	ret, err = client.GetTableByNamesAndIndexes([]string{"testNodeName"}, []string{nodeA, "node-b"})
+	assert.True(t, isPartial(err))
+	assert.ErrorContains(t, err, "invalid index node-b")
+	assert.Len(t, ret, 2)
+	assert.Equal(t, "node-a", ret[0]["testNodeName"])
+	assert.NotContains(t, ret[1], "testNodeName")
 }
 
 func TestSnmpClient_GetBulk(t *testing.T) {
-	client := NewClient(&defaultConfig)
-	ret, err := client.GetBulk("docsRphyRpdDevInfoSysUpTime")
-	if err != nil {
-		t.Error(err)
+	_, config := startAgent(t)
+	client := NewClient(config)
+
+	ret, err := client.GetBulk("testNodeRowStatus")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{nodeA: "active", nodeB: "notInService"}, ret)
+}
+
+func TestSnmpClient_GetBulkByNames(t *testing.T) {
+	_, config := startAgent(t)
+	client := NewClient(config)
+
+	ret, err := client.GetBulkByNames([]string{"testNodeName", "testNodeInOctets", "noSuchName"})
+	var partial *PartialResultError
+	require.True(t, errors.As(err, &partial))
+	assert.Contains(t, partial.Failures, "noSuchName")
+	assert.Equal(t, map[string]map[string]string{
+		"testNodeName":     {nodeA: "node-a", nodeB: "node-b"},
+		"testNodeInOctets": {nodeA: "1000", nodeB: "20"},
+	}, ret)
+}
+
+func TestSnmpClient_GetBulkTable(t *testing.T) {
+	_, config := startAgent(t)
+	client := NewClient(config)
+
+	ret, err := client.GetBulkTable("testPeerTable")
+	require.NoError(t, err)
+	assert.Equal(t, []map[string]string{{
+		"index":              "1.4.10.0.0.1",
+		"testPeerAddrType":   "ipv4",
+		"testPeerAddr":       "10.0.0.1",
+		"testPeerRemoteType": "ipv4",
+		"testPeerRemote":     "10.0.0.9",
+		"testPeerTDomain":    "1.3.6.1.6.1.1",
+		"testPeerTAddress":   "10.0.0.9/161",
+	}}, ret)
+
+	rows, err := client.GetBulkTableValues(context.Background(), "testNodeEntry")
+	require.NoError(t, err)
+	assert.Len(t, rows, 2)
+	for _, row := range rows {
+		assert.Len(t, row.Values, 3)
+		assert.Len(t, row.IndexValues, 2)
 	}
+}
+
+func TestSnmpClient_Walk(t *testing.T) {
+	_, config := startAgent(t)
+	config.MaxRepetitions = 2
+	client := NewClient(config)
+	ctx := context.Background()
 
-	fmt.Printf("\nTestSnmpClient_GetBulk:\n")
-	printMap(ret)
+	next, err := client.GetNextValues(ctx, "testNodeName", "testPeerTAddress."+"1.4.10.0.0.1")
+	require.NoError(t, err)
+	require.Len(t, next, 1)
+	assert.Equal(t, "testNodeName", next[0].Object.Name)
+	assert.Equal(t, nodeA, next[0].Index)
 
-	//0.23.16.20.61.76: 0
-	//0.23.16.43.103.216: 0
-	//0.23.16.43.105.81: 0
-	//0.23.16.43.105.88: 0
-	//0.24.1.133.0.1: 0
-	//0.23.16.42.238.36: 0
-	//0.23.16.27.9.8: 2364564
-	//0.23.16.38.132.238: 2361664
-	//0.23.16.40.210.174: 0
-	//0.23.16.40.210.242: 0
-	//0.23.16.42.238.4: 0
+	for _, walk := range []func(ctx context.Context, name string) ([]*Value, error){client.WalkValues, client.BulkWalkValues} {
+		values, err := walk(ctx, "testNodeTable")
+		require.NoError(t, err)
+		require.Len(t, values, 6)
+		assert.Equal(t, "node-a", values[0].String)
+		assert.Equal(t, "notInService", values[5].String)
+	}
 }
 
-func TestSnmpClient_GetBulkByNames(t *testing.T) {
-	client := NewClient(&defaultConfig)
-	ret, err := client.GetBulkByNames([]string{"casaModuleStatus", "casaModuleSubType"})
-	if err != nil {
-		t.Error(err)
+func TestSnmpClient_Set(t *testing.T) {
+	a, config := startAgent(t)
+	client := NewSessionClient(config)
+	defer client.Close()
+	ctx := context.Background()
+
+	require.NoError(t, client.Set(ctx, "testDescr", "", "C100G-130"))
+	got, err := client.GetName("testDescr")
+	require.NoError(t, err)
+	assert.Equal(t, "C100G-130", got)
+
+	err = client.Set(ctx, "testUpTime", "", "1")
+	assert.ErrorIs(t, err, ErrNotWritable)
+
+	// an index must be either in the name or passed, and must encode
+	assert.Error(t, client.Set(ctx, "testDescr.0", "1", "C100G-131"))
+	assert.ErrorContains(t, client.Set(ctx, "testNodeName", "not-a-mac,10.0.0.1", "node-x"), "invalid index not-a-mac,10.0.0.1")
+	// SetNames alike, before anything is sent
+	err = client.SetNames(ctx, nodeA, map[string]string{"testNodeName": "node-x", "testDescr.0": "C100G-131"})
+	assert.ErrorContains(t, err, "testDescr.0 already names instance 0")
+	got, err = client.GetName("testDescr")
+	require.NoError(t, err)
+	assert.Equal(t, "C100G-130", got)
+	ret, err := client.GetNameByIndexes("testNodeName", []string{nodeA})
+	require.NoError(t, err)
+	assert.Equal(t, "node-a", ret[nodeA])
+
+	require.NoError(t, client.SetNames(ctx, "", map[string]string{"testDescr.0": "C100G-132", "testNodeName." + nodeA: "node-x"}))
+	ret, err = client.GetNames("testDescr", "testNodeName."+nodeA)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"testDescr": "C100G-132", "testNodeName." + nodeA: "node-x"}, ret)
+
+	require.NoError(t, client.CreateRow(ctx, "testNodeTable", "00:17:10:2B:69:5A,10.0.0.3", map[string]string{"testNodeName": "node-c"}))
+	ret, err = client.GetBulk("testNodeName")
+	require.NoError(t, err)
+	assert.Equal(t, "node-c", ret["0.23.16.43.105.90.10.0.0.3"])
+	assert.Len(t, a.Data(), 18)
+}
+
+// setCounter counts the SetRequests that reach the scraper it wraps.
+type setCounter struct {
+	scraper.SNMPScraper
+	sets *int
+}
+
+func (c *setCounter) Set(pdus []gosnmp.SnmpPDU) (*gosnmp.SnmpPacket, error) {
+	*c.sets++
+	return c.SNMPScraper.Set(pdus)
+}
+
+// dropSets loses the response of every set.
+type dropSets struct {
+	scraper.SNMPScraper
+	faulty *fault.Scraper
+}
+
+func (d *dropSets) Set(pdus []gosnmp.SnmpPDU) (*gosnmp.SnmpPacket, error) {
+	return d.faulty.Set(pdus)
+}
+
+func TestSnmpClient_SetNotRetried(t *testing.T) {
+	a, config := startAgent(t)
+	sets := 0
+	client := NewSessionClient(config, WithScraperFactory(func(c *scraper.ClientConfig) (scraper.SNMPScraper, error) {
+		inner, err := scraper.NewGoSNMP(c)
+		if err != nil {
+			return nil, err
+		}
+		counted := &setCounter{SNMPScraper: inner, sets: &sets}
+		return &dropSets{SNMPScraper: counted, faulty: fault.New(counted, fault.Config{Drop: 1})}, nil
+	}))
+	defer client.Close()
+	ctx := context.Background()
+
+	// a reused session retries reads, but a lost set response must not send
+	// the set again
+	_, err := client.GetName("testDescr")
+	require.NoError(t, err)
+	err = client.CreateRow(ctx, "testNodeTable", "00:17:10:2B:69:5A,10.0.0.3", map[string]string{"testNodeName": "node-c"})
+	var timeout *scraper.TimeoutError
+	require.ErrorAs(t, err, &timeout)
+	assert.Equal(t, 1, sets)
+	assert.Len(t, a.Data(), 18)
+}
+
+func TestSnmpClient_Versions(t *testing.T) {
+	loadTestMibs(t)
+	data, err := record.LoadWalk("testdata/agent.walk")
+	require.NoError(t, err)
+	a, err := agent.Start(agent.Config{
+		Data:                     data,
+		SecLevel:                 "authPriv",
+		SecName:                  "admin",
+		AuthenticationProtocol:   "SHA-256",
+		AuthenticationPassphrase: "authpassword",
+		PrivacyProtocol:          "AES",
+		PrivacyPassphrase:        "privpassword",
+	})
+	require.NoError(t, err)
+	defer a.Close()
+
+	for _, version := range []string{scraper.Version1, scraper.Versionv2c, scraper.Version3} {
+		client := NewClient(a.ClientConfig(version))
+		ret, err := client.GetBulkTable("testNodeTable")
+		assert.NoError(t, err, version)
+		assert.Len(t, ret, 2, version)
+
+		// SNMPv1 reports a missing instance as noSuchName
+		_, err = client.GetNames("testDescr", "testNodeName")
+		var partial *PartialResultError
+		assert.True(t, errors.As(err, &partial), version)
 	}
+}
+
+// optionScraper records the MaxRepetitions every walk ran with.
+type optionScraper struct {
+	scraper.SNMPScraper
+	c    *gosnmp.GoSNMP
+	reps []uint32
+}
 
-	fmt.Printf("\nTestSnmpClient_GetBulkByNames:\n")
-	for k, v := range ret {
-		fmt.Println(k)
-		printMap(v)
-		fmt.Println()
+func (o *optionScraper) SetOptions(fns ...func(snmp *gosnmp.GoSNMP)) {
+	o.SNMPScraper.SetOptions(fns...)
+	for _, fn := range fns {
+		fn(o.c)
 	}
+}
 
-	//casaModuleStatus
-	//4: primary
-	//6: primary
-	//8: primary
-	//11: primary
-	//
-	//casaModuleSubType
-	//4: QAM_8x96
-	//6: SMM_300G
-	//8: CSC_8x10G
-	//11: UPS_16x8
+func (o *optionScraper) Walk(oid string, fn gosnmp.WalkFunc) error {
+	o.reps = append(o.reps, o.c.MaxRepetitions)
+	return o.SNMPScraper.Walk(oid, fn)
 }
 
-func TestSnmpClient_GetBulkTable(t *testing.T) {
-	client := NewClient(&defaultConfig)
-	ret, err := client.GetBulkTable("casaRemotePhyNodeEntry")
-	if err != nil {
-		t.Error(err)
+func TestSnmpClient_ScraperFactory(t *testing.T) {
+	loadTestMibs(t)
+	config := &scraper.ClientConfig{Target: "device", Version: scraper.Versionv2c, Community: "public"}
+	replay, err := record.LoadReplay("testdata/agent.walk")
+	require.NoError(t, err)
+
+	var built []*scraper.ClientConfig
+	client := NewClient(config, WithScraperFactory(func(c *scraper.ClientConfig) (scraper.SNMPScraper, error) {
+		built = append(built, c)
+		return replay, nil
+	}))
+	got, err := client.GetName("testDescr")
+	require.NoError(t, err)
+	assert.Equal(t, `CASA "C100G"`, got)
+	rows, err := client.GetBulkTable("testNodeTable")
+	require.NoError(t, err)
+	assert.Len(t, rows, 2)
+	values, err := client.WalkValues(context.Background(), "testPeerTable")
+	require.NoError(t, err)
+	assert.Len(t, values, 4)
+	assert.Equal(t, []*scraper.ClientConfig{config, config, config}, built)
+
+	// decorators stack on any backend
+	client = NewClient(config, WithScraperFactory(func(c *scraper.ClientConfig) (scraper.SNMPScraper, error) {
+		return fault.New(replay, fault.Config{Timeout: 1}), nil
+	}))
+	_, err = client.GetName("testDescr")
+	var timeout *scraper.TimeoutError
+	assert.ErrorAs(t, err, &timeout)
+}
+
+func TestSnmpClient_Cancel(t *testing.T) {
+	loadTestMibs(t)
+	config := &scraper.ClientConfig{Target: "device", Version: scraper.Versionv2c, Community: "public"}
+	replay, err := record.LoadReplay("testdata/agent.walk")
+	require.NoError(t, err)
+	slow := func(latency time.Duration) Option {
+		return WithScraperFactory(func(c *scraper.ClientConfig) (scraper.SNMPScraper, error) {
+			return fault.New(replay, fault.Config{Latency: fault.Constant(latency)}), nil
+		})
+	}
+
+	// a request waiting on a slow device returns once the call is cancelled
+	client := NewClient(config, slow(time.Minute))
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	st := time.Now()
+	_, err = client.GetBulkCtx(ctx, "testNodeName")
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Less(t, time.Since(st), 10*time.Second)
+
+	// a walk stops at the next instance
+	client = NewClient(config, slow(time.Millisecond))
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	var values []string
+	err = client.StreamBulkValues(ctx, "testNodeName", func(v *Value) error {
+		values = append(values, v.String)
+		cancel()
+		return nil
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, []string{"node-a"}, values)
+}
+
+func TestSnmpClient_ResolveOnReconnect(t *testing.T) {
+	_, config := startAgent(t)
+	config.Target = "localhost"
+	config.AddressFamily = scraper.AddressFamilyIPv4
+
+	for _, reresolve := range []bool{false, true} {
+		config.ResolveOnReconnect = reresolve
+		var targets []string
+		client := NewClient(config, WithScraperFactory(func(c *scraper.ClientConfig) (scraper.SNMPScraper, error) {
+			targets = append(targets, c.Target)
+			return scraper.NewGoSNMP(c)
+		}))
+		for i := 0; i < 2; i++ {
+			_, err := client.GetName("testDescr")
+			require.NoError(t, err)
+		}
+
+		// the first resolution is reused unless asked otherwise
+		if reresolve {
+			assert.Equal(t, []string{"localhost", "localhost"}, targets)
+		} else {
+			assert.Equal(t, []string{"localhost", "127.0.0.1"}, targets)
+		}
+	}
+}
+
+// startUnixRelay forwards a unix stream socket to the udp agent at addr,
+// one datagram per read, and returns the socket path.
+func startUnixRelay(t *testing.T, addr string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "agent.sock")
+	l, err := net.Listen("unix", path)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = l.Close()
+	})
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			upstream, err := net.Dial("udp", addr)
+			if err != nil {
+				_ = conn.Close()
+				return
+			}
+			forward := func(dst, src net.Conn) {
+				defer dst.Close()
+				defer src.Close()
+				buf := make([]byte, 65535)
+				for {
+					n, err := src.Read(buf)
+					if err != nil {
+						return
+					}
+					if _, err = dst.Write(buf[:n]); err != nil {
+						return
+					}
+				}
+			}
+			go forward(upstream, conn)
+			go forward(conn, upstream)
+		}
+	}()
+	return path
+}
+
+func TestSnmpClient_Unix(t *testing.T) {
+	a, config := startAgent(t)
+	path := startUnixRelay(t, a.Addr().String())
+
+	c := *config
+	c.Target, c.Port, c.Transport = path, 0, scraper.TransportUnix
+	client := NewSessionClient(&c)
+	defer client.Close()
+
+	got, err := client.GetName("testDescr")
+	require.NoError(t, err)
+	assert.Equal(t, `CASA "C100G"`, got)
+	rows, err := client.GetBulkTable("testNodeTable")
+	require.NoError(t, err)
+	assert.Len(t, rows, 2)
+
+	c.Target = filepath.Join(t.TempDir(), "none.sock")
+	_, err = NewClient(&c).GetName("testDescr")
+	assert.ErrorContains(t, err, "none.sock")
+}
+
+func TestSnmpClient_Dialer(t *testing.T) {
+	a, config := startAgent(t)
+
+	var addresses []string
+	c := *config
+	c.Target = "agent.invalid"
+	c.Dialer = func(ctx context.Context, network, address string) (net.Conn, error) {
+		addresses = append(addresses, network+" "+address)
+		return (&net.Dialer{}).DialContext(ctx, "udp", a.Addr().String())
 	}
-	fmt.Printf("\nTestSnmpClient_GetBulkTable:\n")
-	for _, m := range ret {
-		printMap(m)
-		fmt.Println()
+	got, err := NewClient(&c).GetName("testDescr")
+	require.NoError(t, err)
+	assert.Equal(t, `CASA "C100G"`, got)
+	// the dialer gets the target unresolved
+	assert.Equal(t, []string{fmt.Sprintf("udp agent.invalid:%d", c.Port)}, addresses)
+}
+
+func TestSnmpClient_CallOptions(t *testing.T) {
+	loadTestMibs(t)
+	replay, err := record.LoadReplay("testdata/agent.walk")
+	require.NoError(t, err)
+	spy := &optionScraper{SNMPScraper: replay, c: &gosnmp.GoSNMP{}}
+	client := NewSessionClient(&scraper.ClientConfig{Target: "device", Version: scraper.Versionv2c, Community: "public"},
+		WithScraperFactory(func(*scraper.ClientConfig) (scraper.SNMPScraper, error) {
+			return spy, nil
+		}),
+		WithScraperOptions(func(snmp *gosnmp.GoSNMP) {
+			snmp.MaxRepetitions = 10
+		}),
+	)
+	defer client.Close()
+
+	ctx := context.Background()
+	callCtx := WithCallOptions(ctx, func(snmp *gosnmp.GoSNMP) {
+		snmp.MaxRepetitions = 50
+	})
+	_, err = client.GetBulkCtx(callCtx, "testNodeName")
+	require.NoError(t, err)
+	_, err = client.GetBulkCtx(ctx, "testNodeName")
+	require.NoError(t, err)
+
+	// the session is back to its own settings after the call
+	assert.Equal(t, []uint32{50, 10}, spy.reps)
+}
+
+// bulkScraper counts the varbinds of the GetBulk responses.
+type bulkScraper struct {
+	scraper.SNMPScraper
+	requests, maxVarbinds int
+}
+
+func (b *bulkScraper) GetBulk(oids []string, nonRepeaters uint8, maxRepetitions uint32) (*gosnmp.SnmpPacket, error) {
+	packet, err := b.SNMPScraper.GetBulk(oids, nonRepeaters, maxRepetitions)
+	if err == nil {
+		b.requests++
+		if len(packet.Variables) > b.maxVarbinds {
+			b.maxVarbinds = len(packet.Variables)
+		}
 	}
+	return packet, err
+}
+
+func TestSnmpClient_StreamTableRows(t *testing.T) {
+	_, config := startAgent(t)
+	config.MaxRepetitions = 1
+	client := NewClient(config)
+	ctx := context.Background()
+
+	var indexes []string
+	require.NoError(t, client.StreamTableRows(ctx, "testNodeTable", func(row Row) error {
+		indexes = append(indexes, row.Index)
+		assert.Len(t, row.Values, 3)
+		assert.Len(t, row.IndexValues, 2)
+		return nil
+	}))
+	assert.Equal(t, []string{nodeA, nodeB}, indexes)
+
+	// ErrStopWalk ends the walk quietly, other errors are returned
+	n := 0
+	require.NoError(t, client.StreamTableRows(ctx, "testNodeTable", func(row Row) error {
+		n++
+		return ErrStopWalk
+	}))
+	assert.Equal(t, 1, n)
+	errBoom := errors.New("boom")
+	assert.ErrorIs(t, client.StreamTableRows(ctx, "testNodeTable", func(row Row) error {
+		return errBoom
+	}), errBoom)
+	assert.Error(t, client.StreamTableRows(ctx, "testDescr", func(row Row) error {
+		return nil
+	}))
+
+	var rows []Row
+	Rows(ctx, client, "testPeerTable")(func(row Row, err error) bool {
+		require.NoError(t, err)
+		rows = append(rows, row)
+		return true
+	})
+	require.Len(t, rows, 1)
+	assert.Equal(t, "10.0.0.9/161", rows[0].Values["testPeerTAddress"].String)
+}
+
+func TestSnmpClient_StreamTableRows_Large(t *testing.T) {
+	loadTestMibs(t)
+	const size = 2000
+	data, err := record.NewDataset(nil)
+	require.NoError(t, err)
+	for i := 0; i < size; i++ {
+		index := fmt.Sprintf("0.23.16.43.%d.%d.10.0.%d.%d", i/256, i%256, i/256, i%256)
+		require.NoError(t, data.Add(gosnmp.SnmpPDU{Name: ".1.3.6.1.4.1.99999.2.1.3." + index, Type: gosnmp.OctetString, Value: []byte("node")}))
+		// a sparse column
+		if i%3 == 0 {
+			require.NoError(t, data.Add(gosnmp.SnmpPDU{Name: ".1.3.6.1.4.1.99999.2.1.4." + index, Type: gosnmp.Counter64, Value: uint64(i)}))
+		}
+	}
+	spy := &bulkScraper{SNMPScraper: record.NewReplay(data)}
+	client := NewClient(&scraper.ClientConfig{Target: "device", Version: scraper.Versionv2c, Community: "public"},
+		WithScraperFactory(func(*scraper.ClientConfig) (scraper.SNMPScraper, error) {
+			return spy, nil
+		}),
+		WithScraperOptions(func(snmp *gosnmp.GoSNMP) {
+			snmp.MaxRepetitions = 25
+		}),
+	)
+
+	n := 0
+	var last []uint32
+	require.NoError(t, client.StreamTableRows(context.Background(), "testNodeTable", func(row Row) error {
+		ids, err := parseSubIds(row.Index)
+		require.NoError(t, err)
+		require.Positive(t, compareSubIds(ids, last), row.Index)
+		last = ids
+		assert.Equal(t, n%3 == 0, row.Values["testNodeInOctets"] != nil, row.Index)
+		n++
+		return nil
+	}))
+	assert.Equal(t, size, n)
+	// never more than one round of repetitions per column in flight
+	assert.LessOrEqual(t, spy.maxVarbinds, 3*25)
+	assert.Greater(t, spy.requests, size/25)
 
-	//casaRemotePhyNodeMacAddress: 00:17:10:2B:69:58
-	//casaRemotePhyNodeIpv6Address:
-	//casaRemotePhyNodeAdminStatus: down
-	//casaRemotePhyNodeMacAddressString: 0017102b6958
-	//index: 5
-	//casaRemotePhyNodeRowStatus: active
-	//casaRemotePhyNodeCinIfIndex: 0
-	//casaRemotePhyNodeOperStatus: offline
-	//casaRemotePhyNodeDescription:
-	//casaRemotePhyNodeType: casa-rpd-310-1x2
-	//casaRemotePhyNodeIpv4Address:
-	//casaRemotePhyNodeEportIfIndex: 0
-	//casaRemotePhyNodeDhcpPrimary: 0
-	//casaRemotePhyNodeId: 5
+	// breaking out of the iterator stops the walk
+	values := 0
+	Values(context.Background(), client, "testNodeName")(func(v *Value, err error) bool {
+		require.NoError(t, err)
+		values++
+		return values < 10
+	})
+	assert.Equal(t, 10, values)
 }
@@ -0,0 +1,342 @@
+package snmp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/gosnmp/gosnmp"
+	"snmp-test/snmp/parse"
+	"snmp-test/snmp/scraper"
+	"strings"
+)
+
+// ErrStopWalk returned by the callback of a streaming walk ends the walk
+// early; the walk itself then returns nil.
+var ErrStopWalk = errors.New("stop walk")
+
+// defaultMaxRepetitions is gosnmp's default for bulk walks.
+const defaultMaxRepetitions = 50
+
+// noRetryError marks the failure of a call that must not be repeated on a
+// fresh session, e.g. a streaming walk that already delivered results.
+type noRetryError struct {
+	err error
+}
+
+func (e *noRetryError) Error() string {
+	return e.err.Error()
+}
+
+func (e *noRetryError) Unwrap() error {
+	return e.err
+}
+
+// Values adapts StreamBulkValues to the shape of iter.Seq2, breaking out of
+// the loop stops the walk. A failed walk yields its error last.
+func Values(ctx context.Context, client TypedClient, name string) func(yield func(*Value, error) bool) {
+	return func(yield func(*Value, error) bool) {
+		err := client.StreamBulkValues(ctx, name, func(v *Value) error {
+			if !yield(v, nil) {
+				return ErrStopWalk
+			}
+			return nil
+		})
+		if err != nil {
+			yield(nil, err)
+		}
+	}
+}
+
+// Rows adapts StreamTableRows to the shape of iter.Seq2, see Values.
+func Rows(ctx context.Context, client TypedClient, name string) func(yield func(Row, error) bool) {
+	return func(yield func(Row, error) bool) {
+		err := client.StreamTableRows(ctx, name, func(row Row) error {
+			if !yield(row, nil) {
+				return ErrStopWalk
+			}
+			return nil
+		})
+		if err != nil {
+			yield(Row{}, err)
+		}
+	}
+}
+
+func (s *snmp) StreamBulkValues(ctx context.Context, name string, fn func(*Value) error) error {
+	oid, mibObject, err := resolveOID(name)
+	if err != nil {
+		return err
+	}
+
+	return s.walk(ctx, oid, func(pdu *gosnmp.SnmpPDU) error {
+		if mibObject == nil {
+			return fn(boundValue(pdu))
+		}
+		index := GetIndex(mibObject.OID, pdu.Name[1:])
+		if index == "" {
+			return nil
+		}
+		return fn(newValue(mibObject, index, pdu))
+	})
+}
+
+// walk streams the subtree below oid to fn. Errors of fn end the walk and
+// are returned as is, except ErrStopWalk.
+func (s *snmp) walk(ctx context.Context, oid string, fn func(pdu *gosnmp.SnmpPDU) error) error {
+	var fnErr error
+	err := s.do(ctx, func(client scraper.SNMPScraper) error {
+		delivered := false
+		err := client.Walk(oid, func(pdu gosnmp.SnmpPDU) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			delivered = true
+			fnErr = fn(&pdu)
+			return fnErr
+		})
+		if fnErr != nil {
+			return nil
+		}
+		if err != nil && delivered {
+			return &noRetryError{err}
+		}
+		return err
+	})
+	if errors.Is(fnErr, ErrStopWalk) {
+		return nil
+	}
+	if fnErr != nil {
+		return fnErr
+	}
+	return err
+}
+
+func (s *snmp) StreamTableRows(ctx context.Context, name string, fn func(Row) error) error {
+	entry, err := resolveEntry(name)
+	if err != nil {
+		return err
+	}
+	if entry.Kind != "Row" {
+		return fmt.Errorf("%s is not a table", name)
+	}
+	return s.streamRows(ctx, entry, fn)
+}
+
+// resolveEntry resolves name, preferring the entry of a table.
+func resolveEntry(name string) (*parse.MibObject, error) {
+	mibObject, _, err := resolveObject(name)
+	if err != nil {
+		return nil, err
+	}
+	if mibObject.Kind == "Table" {
+		if entries := parse.Children(mibObject.OID); len(entries) == 1 {
+			mibObject = entries[0]
+		}
+	}
+	return mibObject, nil
+}
+
+// column is the walk state of one column of a table: the instances fetched
+// but not yet assembled into rows, and where to continue.
+type column struct {
+	obj    *parse.MibObject
+	prefix string
+	cursor string
+	last   []uint32
+	queue  []cell
+	done   bool
+}
+
+type cell struct {
+	index string
+	ids   []uint32
+	pdu   gosnmp.SnmpPDU
+}
+
+// streamRows walks all columns of entry side by side, so rows come out
+// complete and in index order while at most one response worth of
+// instances per column is held.
+func (s *snmp) streamRows(ctx context.Context, entry *parse.MibObject, fn func(Row) error) error {
+	var columns []*column
+	for _, obj := range parse.Children(entry.OID) {
+		if obj.Kind == "Column" && obj.Access != "NotAccessible" {
+			oid := "." + obj.OID
+			columns = append(columns, &column{obj: obj, prefix: oid + ".", cursor: oid})
+		}
+	}
+	if len(columns) == 0 {
+		return fmt.Errorf("%s has no readable columns", entry.Name)
+	}
+
+	var fnErr error
+	err := s.do(ctx, func(client scraper.SNMPScraper) error {
+		delivered := false
+		for {
+			for {
+				row, ok := nextRow(entry, columns)
+				if !ok {
+					break
+				}
+				delivered = true
+				if fnErr = fn(row); fnErr != nil {
+					return nil
+				}
+			}
+
+			var pending []*column
+			for _, c := range columns {
+				if !c.done && len(c.queue) == 0 {
+					pending = append(pending, c)
+				}
+			}
+			if len(pending) == 0 {
+				return nil
+			}
+			if err := s.fetchColumns(ctx, client, pending); err != nil {
+				if delivered {
+					return &noRetryError{err}
+				}
+				return err
+			}
+		}
+	})
+	if errors.Is(fnErr, ErrStopWalk) {
+		return nil
+	}
+	if fnErr != nil {
+		return fnErr
+	}
+	return err
+}
+
+// nextRow assembles the row with the lowest index once every column still
+// being walked has an instance queued, i.e. no column can add to it.
+func nextRow(entry *parse.MibObject, columns []*column) (Row, bool) {
+	var first *cell
+	for _, c := range columns {
+		if len(c.queue) == 0 {
+			if !c.done {
+				return Row{}, false
+			}
+			continue
+		}
+		if first == nil || compareSubIds(c.queue[0].ids, first.ids) < 0 {
+			first = &c.queue[0]
+		}
+	}
+	if first == nil {
+		return Row{}, false
+	}
+
+	index := first.index
+	row := Row{Index: index, IndexValues: decodeRowIndex(entry, index), Values: make(map[string]*Value)}
+	for _, c := range columns {
+		if len(c.queue) > 0 && c.queue[0].index == index {
+			row.Values[c.obj.Name] = newValue(c.obj, index, &c.queue[0].pdu)
+			c.queue[0] = cell{}
+			c.queue = c.queue[1:]
+		}
+	}
+	resolveAddresses(&row)
+	return row, true
+}
+
+// fetchColumns requests the next instances of columns, with GetBulk where
+// the version allows and GetNext otherwise.
+func (s *snmp) fetchColumns(ctx context.Context, client scraper.SNMPScraper, columns []*column) error {
+	var maxOids int
+	var maxReps uint32
+	client.SetOptions(func(snmp *gosnmp.GoSNMP) {
+		maxOids, maxReps = snmp.MaxOids, snmp.MaxRepetitions
+	})
+	if maxOids <= 0 || maxOids > gosnmp.MaxOids {
+		maxOids = gosnmp.MaxOids
+	}
+	if maxReps == 0 {
+		maxReps = defaultMaxRepetitions
+	}
+	isVersion1 := s.config.Version == scraper.Version1
+
+	for len(columns) > 0 {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		n := len(columns)
+		if n > maxOids {
+			n = maxOids
+		}
+		batch := columns[:n]
+		oids := make([]string, n)
+		for i, c := range batch {
+			oids[i] = c.cursor
+		}
+
+		var packet *gosnmp.SnmpPacket
+		var err error
+		if isVersion1 {
+			packet, err = client.GetNext(oids)
+		} else {
+			packet, err = client.GetBulk(oids, 0, maxReps)
+		}
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case packet.Error == gosnmp.NoSuchName && isVersion1 && packet.ErrorIndex > 0 && int(packet.ErrorIndex) <= n:
+			// the column is at the end of the MIB view, ask again without it
+			i := int(packet.ErrorIndex) - 1
+			batch[i].done = true
+			columns = append(append([]*column{}, columns[:i]...), columns[i+1:]...)
+			continue
+		case packet.Error != gosnmp.NoError:
+			return newPacketError(packet, oids)
+		case len(packet.Variables) < n:
+			return fmt.Errorf("response to %d columns carries %d varbinds", n, len(packet.Variables))
+		}
+
+		// bulk responses hold one round of all columns after the other
+		for i, c := range batch {
+			for j := i; j < len(packet.Variables) && !c.done; j += n {
+				if err := c.add(packet.Variables[j]); err != nil {
+					return err
+				}
+			}
+		}
+		columns = columns[n:]
+	}
+	return nil
+}
+
+// add queues pdu if it still belongs to the column and ends the column
+// otherwise.
+func (c *column) add(pdu gosnmp.SnmpPDU) error {
+	if isException(pdu.Type) || !strings.HasPrefix(pdu.Name, c.prefix) {
+		c.done = true
+		return nil
+	}
+
+	index := pdu.Name[len(c.prefix):]
+	ids, err := parseSubIds(index)
+	if err != nil {
+		return err
+	}
+	if c.last != nil && compareSubIds(ids, c.last) <= 0 {
+		return fmt.Errorf("agent returned %s out of order", pdu.Name)
+	}
+	c.queue = append(c.queue, cell{index: index, ids: ids, pdu: pdu})
+	c.cursor, c.last = pdu.Name, ids
+	return nil
+}
+
+func compareSubIds(a, b []uint32) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] != b[i] {
+			if a[i] < b[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return len(a) - len(b)
+}
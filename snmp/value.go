@@ -0,0 +1,155 @@
+package snmp
+
+import (
+	"fmt"
+	"github.com/gosnmp/gosnmp"
+	gosmitypes "github.com/sleepinggenius2/gosmi/types"
+	"log/slog"
+	"math/big"
+	"snmp-test/snmp/parse"
+	"time"
+)
+
+// Value is one decoded varbind. String is what the string API returns;
+// the other fields keep what that rendering throws away.
+type Value struct {
+	// Object is the MIB object the varbind belongs to.
+	Object *parse.MibObject
+	// OID is the full numeric OID of the instance, without leading dot.
+	OID string
+	// Index is the instance suffix after Object.OID.
+	Index string
+	// Type is the ASN.1 type reported by the agent.
+	Type gosnmp.Asn1BER
+	// Raw is the value as decoded by gosnmp.
+	Raw interface{}
+
+	// Number is set for INTEGER, Counter, Gauge, TimeTicks and enum values.
+	Number *big.Int
+	// Bytes is set for OCTET STRING, Opaque and BITS values.
+	Bytes []byte
+	// String is the human readable rendering of the value.
+	String string
+
+	// Bits lists the set bits of a BITS value in bit order, labelled from
+	// the MIB or bit(N) if the MIB names none.
+	Bits []string
+
+	// EnumName and EnumNumber are set for enumerated INTEGER values.
+	// EnumName is empty if the number has no label in the MIB.
+	EnumName   string
+	EnumNumber int
+}
+
+// Row is one conceptual table row, its columns keyed by object name.
+// IndexValues holds the decoded INDEX components when the MIB defines them.
+type Row struct {
+	Index       string
+	IndexValues []IndexValue
+	Values      map[string]*Value
+}
+
+func decodeRowIndex(entry *parse.MibObject, index string) []IndexValue {
+	if entry.Kind != "Row" || len(entry.Index) == 0 {
+		return nil
+	}
+	values, err := DecodeIndex(entry, index)
+	if err != nil {
+		slog.Debug("Failed to decode row index", "entry", entry.Name, "index", index, "err", err)
+		return nil
+	}
+	return values
+}
+
+func newValue(mib *parse.MibObject, index string, pdu *gosnmp.SnmpPDU) *Value {
+	v := &Value{
+		Object: mib,
+		OID:    pdu.Name,
+		Index:  index,
+		Type:   pdu.Type,
+		Raw:    pdu.Value,
+		String: pduValueAsString(mib, pdu),
+	}
+	if len(v.OID) > 0 && v.OID[0] == '.' {
+		v.OID = v.OID[1:]
+	}
+
+	switch pdu.Type {
+	case gosnmp.Integer, gosnmp.Counter32, gosnmp.Gauge32, gosnmp.TimeTicks, gosnmp.Counter64, gosnmp.Uinteger32:
+		v.Number = gosnmp.ToBigInt(pdu.Value)
+	case gosnmp.OctetString, gosnmp.Opaque, gosnmp.BitString:
+		v.Bytes, _ = pdu.Value.([]byte)
+	}
+
+	if mib != nil && gosmitypes.BaseType(mib.SmiType) == gosmitypes.BaseTypeBits {
+		v.Bits = bitLabels(pdu.Value, mib.Syntax)
+	}
+	if mib != nil && gosmitypes.BaseType(mib.SmiType) == gosmitypes.BaseTypeEnum && v.Number != nil {
+		v.EnumNumber = int(v.Number.Int64())
+		v.EnumName = mib.Syntax[v.EnumNumber]
+	}
+	return v
+}
+
+// IsNumber reports whether the value carries a numeric payload.
+func (v *Value) IsNumber() bool {
+	return v.Number != nil
+}
+
+// Uint64 returns the numeric value, or 0 if it is negative or not a number.
+func (v *Value) Uint64() uint64 {
+	if v.Number == nil || v.Number.Sign() < 0 {
+		return 0
+	}
+	return v.Number.Uint64()
+}
+
+// Int64 returns the numeric value, or 0 if it is not a number.
+func (v *Value) Int64() int64 {
+	if v.Number == nil {
+		return 0
+	}
+	return v.Number.Int64()
+}
+
+// Time decodes a DateAndTime value, in the zone the agent reported; see
+// DecodeDateAndTime for values without one.
+func (v *Value) Time() (time.Time, error) {
+	if v.Bytes == nil {
+		return time.Time{}, fmt.Errorf("%s is not an OCTET STRING", v.OID)
+	}
+	return DecodeDateAndTime(v.Bytes)
+}
+
+func valuesAsStrings(values map[string]*Value) map[string]string {
+	if values == nil {
+		return nil
+	}
+	m := make(map[string]string, len(values))
+	for k, v := range values {
+		m[k] = v.String
+	}
+	return m
+}
+
+func rowsAsStrings(rows []Row) []map[string]string {
+	if rows == nil {
+		return nil
+	}
+	results := make([]map[string]string, 0, len(rows))
+	for _, row := range rows {
+		m := make(map[string]string, len(row.Values)+1)
+		for k, v := range row.Values {
+			m[k] = v.String
+		}
+		// not-accessible index columns are only known through the index
+		for _, iv := range row.IndexValues {
+			if _, ok := m[iv.Object.Name]; !ok {
+				m[iv.Object.Name] = iv.Value
+			}
+		}
+		m["index"] = row.Index
+		results = append(results, m)
+	}
+	return results
+}
@@ -0,0 +1,65 @@
+package snmp
+
+import (
+	"github.com/gosnmp/gosnmp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"snmp-test/snmp/parse"
+	"testing"
+)
+
+func TestNewValue(t *testing.T) {
+	loadTestMibs(t)
+
+	for _, c := range []struct {
+		name   string
+		object string
+		typ    gosnmp.Asn1BER
+		raw    interface{}
+
+		number     string
+		bytes      []byte
+		enumName   string
+		enumNumber int
+	}{
+		{name: "negative Integer", object: "testTemperature", typ: gosnmp.Integer, raw: -42, number: "-42"},
+		{name: "enum", object: "testNodeRowStatus", typ: gosnmp.Integer, raw: 2, number: "2", enumName: "notInService", enumNumber: 2},
+		{name: "enum without label", object: "testNodeRowStatus", typ: gosnmp.Integer, raw: 9, number: "9", enumNumber: 9},
+		{name: "Counter32", typ: gosnmp.Counter32, raw: uint(4294967295), number: "4294967295"},
+		{name: "Gauge32", typ: gosnmp.Gauge32, raw: uint(7), number: "7"},
+		{name: "Uinteger32", typ: gosnmp.Uinteger32, raw: uint32(8), number: "8"},
+		{name: "TimeTicks", object: "testUpTime", typ: gosnmp.TimeTicks, raw: uint32(18295586), number: "18295586"},
+		{name: "Counter64 above 2^63", object: "testNodeInOctets", typ: gosnmp.Counter64, raw: uint64(1<<63 + 5), number: "9223372036854775813"},
+		{name: "OctetString", object: "testDescr", typ: gosnmp.OctetString, raw: []byte("CASA"), bytes: []byte("CASA")},
+		{name: "Opaque", typ: gosnmp.Opaque, raw: []byte{0x01, 0x02}, bytes: []byte{0x01, 0x02}},
+		{name: "IPAddress", object: "testNodeAddr", typ: gosnmp.IPAddress, raw: "10.0.0.1"},
+		{name: "ObjectIdentifier", typ: gosnmp.ObjectIdentifier, raw: ".1.3.6.1"},
+		{name: "noSuchInstance", object: "testDescr", typ: gosnmp.NoSuchInstance},
+	} {
+		var mib *parse.MibObject
+		if c.object != "" {
+			var ok bool
+			mib, ok = parse.FindMib(c.object)
+			require.True(t, ok, c.name)
+		}
+
+		v := newValue(mib, "0", &gosnmp.SnmpPDU{Name: ".1.3.6.1.4.1.99999.9.0", Type: c.typ, Value: c.raw})
+		if c.number == "" {
+			assert.Nil(t, v.Number, c.name)
+		} else if assert.NotNil(t, v.Number, c.name) {
+			assert.Equal(t, c.number, v.Number.String(), c.name)
+		}
+		assert.Equal(t, c.bytes, v.Bytes, c.name)
+		assert.Equal(t, c.enumName, v.EnumName, c.name)
+		assert.Equal(t, c.enumNumber, v.EnumNumber, c.name)
+		assert.Equal(t, c.typ, v.Type, c.name)
+		assert.Equal(t, "1.3.6.1.4.1.99999.9.0", v.OID, c.name)
+	}
+
+	// the accessors clamp what does not fit
+	v := newValue(nil, "", &gosnmp.SnmpPDU{Type: gosnmp.Counter64, Value: uint64(1<<63 + 5)})
+	assert.Equal(t, uint64(1<<63+5), v.Uint64())
+	v = newValue(nil, "", &gosnmp.SnmpPDU{Type: gosnmp.Integer, Value: -1})
+	assert.Equal(t, uint64(0), v.Uint64())
+	assert.Equal(t, int64(-1), v.Int64())
+}
@@ -0,0 +1,156 @@
+package snmp
+
+import (
+	"context"
+	"fmt"
+	"github.com/gosnmp/gosnmp"
+	"snmp-test/snmp/parse"
+	"snmp-test/snmp/scraper"
+	"strings"
+)
+
+func (s *snmp) GetNextValues(ctx context.Context, names ...string) ([]*Value, error) {
+	oids := make([]string, 0, len(names))
+	for _, name := range names {
+		oid, _, err := resolveOID(name)
+		if err != nil {
+			return nil, err
+		}
+		oids = append(oids, oid)
+	}
+
+	maxOids := s.config.MaxOIDs
+	if maxOids == 0 || s.config.Version == scraper.Version1 {
+		maxOids = 1
+	} else if maxOids > gosnmp.MaxOids {
+		maxOids = gosnmp.MaxOids
+	}
+
+	var values []*Value
+	err := s.do(ctx, func(client scraper.SNMPScraper) error {
+		for len(oids) > 0 {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			n := len(oids)
+			if n > maxOids {
+				n = maxOids
+			}
+
+			packet, err := client.GetNext(oids[:n])
+			if err != nil {
+				return err
+			}
+			if packet.Error != gosnmp.NoError {
+				return newPacketError(packet, oids[:n])
+			}
+			for i := range packet.Variables {
+				if packet.Variables[i].Type == gosnmp.EndOfMibView {
+					continue
+				}
+				values = append(values, boundValue(&packet.Variables[i]))
+			}
+			oids = oids[n:]
+		}
+		return nil
+	})
+	return values, err
+}
+
+func (s *snmp) WalkValues(ctx context.Context, name string) ([]*Value, error) {
+	return s.walkValues(ctx, name, func(client scraper.SNMPScraper, oid string) ([]gosnmp.SnmpPDU, error) {
+		return walkNext(ctx, client, oid)
+	})
+}
+
+func (s *snmp) BulkWalkValues(ctx context.Context, name string) ([]*Value, error) {
+	return s.walkValues(ctx, name, func(client scraper.SNMPScraper, oid string) ([]gosnmp.SnmpPDU, error) {
+		return client.WalkAll(oid)
+	})
+}
+
+func (s *snmp) walkValues(ctx context.Context, name string, walk func(client scraper.SNMPScraper, oid string) ([]gosnmp.SnmpPDU, error)) ([]*Value, error) {
+	oid, _, err := resolveOID(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var pdus []gosnmp.SnmpPDU
+	err = s.do(ctx, func(client scraper.SNMPScraper) (err error) {
+		pdus, err = walk(client, oid)
+		return
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([]*Value, 0, len(pdus))
+	for i := range pdus {
+		values = append(values, boundValue(&pdus[i]))
+	}
+	return values, nil
+}
+
+// walkNext walks the subtree below root with GetNext requests regardless of
+// the version. Like gosnmp, it ends quietly at an error status or
+// exception, and returns root itself if nothing lies below it.
+func walkNext(ctx context.Context, client scraper.SNMPScraper, root string) ([]gosnmp.SnmpPDU, error) {
+	root = "." + strings.TrimPrefix(root, ".")
+	var pdus []gosnmp.SnmpPDU
+	for oid := root; ; {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		packet, err := client.GetNext([]string{oid})
+		if err != nil {
+			return nil, err
+		}
+		if packet.Error != gosnmp.NoError || len(packet.Variables) == 0 {
+			return pdus, nil
+		}
+
+		pdu := packet.Variables[0]
+		switch {
+		case isException(pdu.Type):
+			return pdus, nil
+		case !strings.HasPrefix(pdu.Name, root+"."):
+			if len(pdus) > 0 {
+				return pdus, nil
+			}
+			packet, err = client.Get([]string{root})
+			if err != nil {
+				return nil, err
+			}
+			if packet.Error == gosnmp.NoError && len(packet.Variables) > 0 && !isException(packet.Variables[0].Type) {
+				pdus = append(pdus, packet.Variables[0])
+			}
+			return pdus, nil
+		case pdu.Name == oid:
+			return nil, fmt.Errorf("agent returned the requested OID %s on GetNext", oid)
+		}
+		pdus = append(pdus, pdu)
+		oid = pdu.Name
+	}
+}
+
+func isException(t gosnmp.Asn1BER) bool {
+	return t == gosnmp.NoSuchObject || t == gosnmp.NoSuchInstance || t == gosnmp.EndOfMibView
+}
+
+// boundValue decodes pdu against the MIB object its OID falls under. OIDs
+// outside the loaded MIBs keep a nil Object and a plain rendering.
+func boundValue(pdu *gosnmp.SnmpPDU) *Value {
+	mib, index, ok := parse.LongestMatch(pdu.Name)
+	if !ok {
+		v := newValue(nil, "", pdu)
+		switch raw := pdu.Value.(type) {
+		case []byte:
+			v.String = formatUnknownOctets(raw)
+		case nil:
+		default:
+			v.String = fmt.Sprint(raw)
+		}
+		return v
+	}
+	return newValue(mib, index, pdu)
+}